@@ -0,0 +1,63 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"os"
+)
+
+// ApplyEnvInto applies overrides from the process's environment variables
+// (prefixed with envPrefix) to config, without reading a configuration file.
+// This suits programs that populate defaults themselves, or have no file at
+// all. Overrides are applied and validated as they are by ReadWithEnvInto,
+// except that variables in a RawSections field cannot be overridden, since
+// there is no file to take them from.
+func ApplyEnvInto(envPrefix string, config interface{}, opts ...Option) error {
+	return applyWithMapInto(mapFromEnviron(os.Environ()), envPrefix, config, opts...)
+}
+
+func applyWithMapInto(env map[string]string, prefix string, config interface{}, opts ...Option) (err error) {
+	defer showErrorCodes(&err, opts)
+	defer recoverPanic(&err, "")
+	ref, err := targetStruct(config)
+	if err != nil {
+		return err
+	}
+	if hasEmbeddedConfig(ref.Type()) {
+		return readComposed(ref, func(config interface{}) error {
+			return applyWithMapInto(env, prefix, config, opts...)
+		})
+	}
+	o := newOptions(opts)
+	prefix, err = o.resolvePrefix(prefix, env)
+	if err != nil {
+		return err
+	}
+	env, err = o.readFileVars(ref.Type(), env, prefix)
+	if err != nil {
+		return err
+	}
+	env = withoutReserved(env, o.reservedPrefixes)
+	env, deprecated := renameInEnv(env, prefix, o.naming, collectRenames(ref.Type()))
+	st := newApplyState(o, prefix, env)
+	err = st.applyEnv(ref)
+	if err != nil {
+		return err
+	}
+	fillSubsectionOrder(ref, nil)
+	err = st.finish(ref)
+	if err != nil {
+		return err
+	}
+	err = st.fillReport(ref, nil)
+	if err != nil {
+		return err
+	}
+	var upstreamErr error
+	upstreamErr = appendWarnings(upstreamErr, deprecated)
+	if o.strict {
+		upstreamErr = appendWarnings(upstreamErr, st.warnings(ref, prefix))
+	}
+	return upstreamErr
+}
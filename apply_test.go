@@ -0,0 +1,48 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"os"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestApplyEnvInto(c *check.C) {
+	type pool struct {
+		Size int
+	}
+	type config struct {
+		Server struct {
+			Listen string
+			Tags   []string
+			TLS    bool
+			Key    string `gcfgenv:"required_if=TLS"`
+		}
+		Pool map[string]*pool
+	}
+
+	os.Setenv("APPLYTEST_SERVER_LISTEN", ":81")
+	os.Setenv("APPLYTEST_SERVER_TAGS", "b")
+	os.Setenv("APPLYTEST_POOL_main_SIZE", "4")
+	defer func() {
+		os.Unsetenv("APPLYTEST_SERVER_LISTEN")
+		os.Unsetenv("APPLYTEST_SERVER_TAGS")
+		os.Unsetenv("APPLYTEST_POOL_main_SIZE")
+	}()
+
+	// Defaults are populated programmatically.
+	cfg := config{}
+	cfg.Server.Listen = ":80"
+	cfg.Server.Tags = []string{"a"}
+	err := ApplyEnvInto("APPLYTEST", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Listen, check.Equals, ":81")
+	c.Check(cfg.Server.Tags, check.DeepEquals, []string{"a", "b"})
+	c.Check(cfg.Pool["main"].Size, check.Equals, 4)
+
+	// Validation still applies.
+	err = applyWithMapInto(map[string]string{"APP_SERVER_TLS": "true"}, "APP", &cfg)
+	c.Check(err, check.ErrorMatches, "Server.Key is required when TLS is set")
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// WithStrictBooleans restricts the values accepted for boolean fields, in the
+// file or the environment, to the given spellings (by default,
+// StrictBoolValues), instead of everything gcfg accepts (TrueValues and
+// FalseValues, in any case).
+// The comparison is case-sensitive, and variables given without a value are
+// rejected. The allowed values must still be ones that gcfg understands.
+func WithStrictBooleans(allowed ...string) Option {
+	if len(allowed) == 0 {
+		allowed = StrictBoolValues()
+	}
+	return func(o *options) {
+		o.boolValues = allowed
+	}
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isBoolType reports whether t is a boolean that is not converted by its own
+// UnmarshalText method.
+func isBoolType(t reflect.Type) bool {
+	return t.Kind() == reflect.Bool && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// derefType returns the type t points to, or t itself if it is not a
+// pointer.
+func derefType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// checkBools checks val, to be converted to t, against WithStrictBooleans.
+// Slice entries are separated by delim.
+func (o *options) checkBools(t reflect.Type, val, delim string) error {
+	if o.boolValues == nil {
+		return nil
+	}
+	switch {
+	case isBoolType(t):
+		return o.checkBool(val)
+	case t.Kind() == reflect.Ptr:
+		return o.checkBools(t.Elem(), val, delim)
+	case t.Kind() == reflect.Slice && isBoolType(derefType(t.Elem())):
+		for _, part := range splitEntries(val, delim) {
+			if err := o.checkBool(part); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (o *options) checkBool(val string) error {
+	for _, v := range o.boolValues {
+		if val == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid boolean %q: must be one of %s", val,
+		strings.Join(o.boolValues, ", "))
+}
+
+// checkFileBools checks the boolean variables in src against
+// WithStrictBooleans.
+func (o *options) checkFileBools(refType reflect.Type, src []byte) error {
+	if o.boolValues == nil {
+		return nil
+	}
+	for _, e := range scanGcfg(src) {
+		if e.Name == "" {
+			continue
+		}
+		i := sectionFieldIndex(refType, e.Section)
+		if i < 0 {
+			continue
+		}
+		secType := sectionStructType(refType.Field(i).Type)
+		if secType == nil {
+			continue
+		}
+		j := sectionFieldIndex(secType, e.Name)
+		if j < 0 {
+			continue
+		}
+		t := secType.Field(j).Type
+		if t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if !isBoolType(derefType(t)) {
+			continue
+		}
+		if e.Blank {
+			return fmt.Errorf("missing value for boolean %s.%s", e.Section, e.Name)
+		}
+		if err := o.checkBool(e.Value); err != nil {
+			return fmt.Errorf("%s.%s: %v", e.Section, e.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestStrictBooleans(c *check.C) {
+	type sec struct {
+		Enabled bool
+		Flags   []bool
+		Debug   *bool
+	}
+	type config struct {
+		Sec    sec
+		Subsec map[string]*sec
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader("[sec]\nenabled = on\n[subsec \"a\"]\ndebug\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	cfg = config{}
+	r = strings.NewReader("[sec]\nenabled = true\nflags = false\n[subsec \"a\"]\ndebug = false\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SEC_FLAGS":      "true,false",
+		"APPNAME_SUBSEC_b_DEBUG": "true",
+	}, "APPNAME", &cfg, WithStrictBooleans())
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Sec.Flags, check.DeepEquals, []bool{false, true, false})
+
+	cfg = config{}
+	r = strings.NewReader("[sec]\nenabled = on\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg, WithStrictBooleans())
+	c.Check(err, check.ErrorMatches, `sec.enabled: invalid boolean "on": must be one of true, false`)
+
+	cfg = config{}
+	r = strings.NewReader("[subsec \"a\"]\ndebug\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg, WithStrictBooleans())
+	c.Check(err, check.ErrorMatches, `missing value for boolean subsec.debug`)
+
+	cfg = config{}
+	r = strings.NewReader("")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SEC_FLAGS": "true,TRUE",
+	}, "APPNAME", &cfg, WithStrictBooleans())
+	c.Check(err, check.ErrorMatches, `cannot set Sec\.Flags from APPNAME_SEC_FLAGS="true,TRUE": invalid boolean "TRUE": must be one of true, false`)
+
+	cfg = config{}
+	r = strings.NewReader("[sec]\nenabled = yes\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SUBSEC_a_DEBUG": "no",
+	}, "APPNAME", &cfg, WithStrictBooleans("yes", "no"))
+	c.Check(err, check.IsNil)
+	c.Check(*cfg.Subsec["a"].Debug, check.Equals, false)
+}
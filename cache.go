@@ -0,0 +1,167 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"reflect"
+	"sort"
+	"sync"
+
+	"gopkg.in/gcfg.v1"
+)
+
+// DefaultCacheSize is the number of results a Cache from NewCache holds.
+const DefaultCacheSize = 64
+
+// A Cache holds the results of previous reads, for WithCache. Once it is full,
+// the least recently used result is evicted to make room for each new one. It
+// is safe for concurrent use.
+type Cache struct {
+	mu    sync.Mutex
+	limit int
+	// entries maps keys to elements of lru, which holds *cacheEntry values
+	// from the most to the least recently used.
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+}
+
+// cacheKey identifies the inputs to a read, other than the options and the
+// initial contents of the config struct.
+type cacheKey struct {
+	t   reflect.Type
+	sum [sha256.Size]byte
+}
+
+type cacheEntry struct {
+	key cacheKey
+	// in and out are copies of the config struct before and after the
+	// read.
+	in, out reflect.Value
+	err     error
+	// report is nil if the read did not use WithReport.
+	report []FieldReport
+}
+
+// NewCache returns an empty Cache that holds up to DefaultCacheSize results.
+func NewCache() *Cache {
+	return NewCacheSize(DefaultCacheSize)
+}
+
+// NewCacheSize returns an empty Cache that holds up to n results (or one, if n
+// is less than one), e.g. for programs that read many distinct files or
+// prefixes.
+func NewCacheSize(n int) *Cache {
+	if n < 1 {
+		n = 1
+	}
+	return &Cache{limit: n, entries: make(map[cacheKey]*list.Element), lru: list.New()}
+}
+
+// WithCache makes ReadWithEnvInto and ReadFileWithEnvInto return a copy of a
+// previous result from c, instead of parsing the file and applying the
+// environment again, if the file's contents, the environment, the prefix, the
+// type of config, and its initial contents are all unchanged. This suits
+// programs such as job runners that load the same configuration for every
+// task.
+//
+// The other options are not part of the cache key, so a Cache should only be
+// used with one set of options. Values from WithGenerators are reused
+// along with the rest of the result.
+func WithCache(c *Cache) Option {
+	return func(o *options) {
+		o.cache = c
+	}
+}
+
+// Reset removes all of the cached results.
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[cacheKey]*list.Element)
+	c.lru.Init()
+}
+
+// get returns the entry for key, if any, marking it as the most recently
+// used.
+func (c *Cache) get(key cacheKey) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el := c.entries[key]
+	if el == nil {
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*cacheEntry)
+}
+
+// put adds e, replacing any entry with the same key, and evicts the least
+// recently used entries beyond the limit.
+func (c *Cache) put(e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el := c.entries[e.key]; el != nil {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	for c.lru.Len() > c.limit {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// read sets ref (and report, if it is not nil) from a cached result for src,
+// env, and prefix, or by calling read and caching the result if it is not
+// fatal.
+func (c *Cache) read(ref reflect.Value, src []byte, env map[string]string, prefix string, report *Report, read func() error) error {
+	key := cacheKey{t: ref.Type(), sum: cacheSum(src, env, prefix)}
+	e := c.get(key)
+	if e != nil && (report == nil || e.report != nil) &&
+		reflect.DeepEqual(e.in.Interface(), ref.Interface()) {
+		ref.Set(deepCopy(e.out))
+		if report != nil {
+			report.Fields = append([]FieldReport(nil), e.report...)
+		}
+		return e.err
+	}
+	in := deepCopy(ref)
+	err := read()
+	if gcfg.FatalOnly(err) != nil {
+		return err
+	}
+	e = &cacheEntry{key: key, in: in, out: deepCopy(ref), err: err}
+	if report != nil {
+		e.report = append([]FieldReport{}, report.Fields...)
+	}
+	c.put(e)
+	return err
+}
+
+// cacheSum returns a hash of src, env, and prefix.
+func cacheSum(src []byte, env map[string]string, prefix string) [sha256.Size]byte {
+	names := make([]string, 0, len(env))
+	for k := range env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	// NUL cannot appear in environment variables, so it separates them
+	// unambiguously.
+	h.Write([]byte(prefix))
+	h.Write([]byte{0})
+	for _, k := range names {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(env[k]))
+		h.Write([]byte{0})
+	}
+	h.Write(src)
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
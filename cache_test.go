@@ -0,0 +1,90 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+var cacheTestReads int
+
+func init() {
+	RegisterTransform("count-reads", func(s string) (string, error) {
+		cacheTestReads++
+		return s, nil
+	})
+}
+
+func (s *Suite) TestCache(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string `gcfgenv:"transform=count-reads"`
+			List  []string
+		}
+	}
+	src := "[sec]\nfield = file\nlist = a\n"
+	env := map[string]string{"APP_SEC_FIELD": "env"}
+	cache := NewCache()
+	read := func(cfg *config, src string, env map[string]string, opts ...Option) error {
+		opts = append(opts, WithCache(cache))
+		return readWithMapInto(strings.NewReader(src), env, "APP", cfg, opts...)
+	}
+	cacheTestReads = 0
+
+	var first config
+	c.Assert(read(&first, src, env), check.IsNil)
+	c.Check(first.Sec.Field, check.Equals, "env")
+	c.Check(cacheTestReads, check.Equals, 2)
+
+	// The same inputs give a copy of the same result.
+	var second config
+	c.Assert(read(&second, src, env), check.IsNil)
+	c.Check(second, check.DeepEquals, first)
+	c.Check(cacheTestReads, check.Equals, 2)
+	second.Sec.List[0] = "changed"
+	c.Check(first.Sec.List[0], check.Equals, "a")
+
+	// Any change to the inputs misses the cache.
+	var other config
+	c.Assert(read(&other, src, map[string]string{"APP_SEC_FIELD": "other"}), check.IsNil)
+	c.Check(other.Sec.Field, check.Equals, "other")
+	other = config{}
+	c.Assert(read(&other, src+"list = b\n", env), check.IsNil)
+	c.Check(other.Sec.List, check.DeepEquals, []string{"a", "b"})
+	other = config{}
+	other.Sec.List = []string{"default"}
+	c.Assert(read(&other, src, env), check.IsNil)
+	c.Check(other.Sec.List, check.DeepEquals, []string{"default", "a"})
+	c.Check(cacheTestReads, check.Equals, 8)
+
+	// Reports are cached too.
+	var report Report
+	other = config{}
+	c.Assert(read(&other, src, env, WithReport(&report)), check.IsNil)
+	c.Check(report.Fields, check.HasLen, 2)
+	report = Report{}
+	other = config{}
+	c.Assert(read(&other, src, env, WithReport(&report)), check.IsNil)
+	c.Check(report.Fields, check.HasLen, 2)
+
+	// Fatal errors are not cached.
+	cache.Reset()
+	c.Check(read(&other, "[sec", env), check.NotNil)
+	c.Check(cache.entries, check.HasLen, 0)
+
+	// The least recently used results are evicted.
+	cache = NewCacheSize(2)
+	cacheTestReads = 0
+	for _, v := range []string{"a", "b", "a", "c", "a", "b"} {
+		other = config{}
+		c.Assert(read(&other, src, map[string]string{"APP_SEC_FIELD": v}), check.IsNil)
+		c.Check(other.Sec.Field, check.Equals, v)
+	}
+	// "a" stays cached throughout, but "b" is evicted by "c".
+	c.Check(cacheTestReads, check.Equals, 8)
+	c.Check(cache.entries, check.HasLen, 2)
+	c.Check(cache.lru.Len(), check.Equals, 2)
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/warnings.v0"
+)
+
+// A Code is a stable identifier for a kind of error, such as "GCFGENV-0002"
+// for a value that cannot be converted to the type of its field. Codes are
+// never reused or renumbered, so that support documentation and log-based
+// alerting can rely on them instead of on the text of error messages.
+type Code string
+
+// The error codes. Errors from the gcfg package other than those reported as
+// CodeFile, and errors returned by the operating system (e.g. when opening a
+// file), have no code.
+const (
+	// CodeInvalidTarget is returned with ErrInvalidTarget.
+	CodeInvalidTarget Code = "GCFGENV-0001"
+	// CodeInvalidValue is returned when an environment variable's value
+	// cannot be transformed or converted to the type of its field.
+	CodeInvalidValue Code = "GCFGENV-0002"
+	// CodeRequired is returned for missing gcfgenv:"required" and
+	// gcfgenv:"required_if=..." fields.
+	CodeRequired Code = "GCFGENV-0003"
+	// CodeExclusive is returned when more than one field of a
+	// gcfgenv:"exclusive=..." group is set.
+	CodeExclusive Code = "GCFGENV-0004"
+	// CodeItems is returned when a slice breaks a minitems, maxitems or
+	// unique directive.
+	CodeItems Code = "GCFGENV-0005"
+	// CodeReference is returned for a gcfgenv:"ref=..." field that names
+	// a subsection that does not exist.
+	CodeReference Code = "GCFGENV-0006"
+	// CodeUnknownEnvVar is the code of *UnknownEnvVarError.
+	CodeUnknownEnvVar Code = "GCFGENV-0007"
+	// CodeNewSubsection is the code of *NewSubsectionError.
+	CodeNewSubsection Code = "GCFGENV-0008"
+	// CodeDeprecatedName is the code of *DeprecatedNameError.
+	CodeDeprecatedName Code = "GCFGENV-0009"
+	// CodeNameCollision is the code of *NameCollisionError.
+	CodeNameCollision Code = "GCFGENV-0010"
+	// CodeEmptyPrefix is the code of *EmptyPrefixError.
+	CodeEmptyPrefix Code = "GCFGENV-0011"
+	// CodePanic is the code of *PanicError.
+	CodePanic Code = "GCFGENV-0012"
+	// CodeFile is returned when gcfg cannot parse the configuration file,
+	// or cannot set a field from it.
+	CodeFile Code = "GCFGENV-0013"
+	// CodeEncoding is the code of *EncodingError.
+	CodeEncoding Code = "GCFGENV-0014"
+	// CodeTooManySubsections is returned when environment variables would
+	// create more new subsections than WithMaxNewSubsections allows.
+	CodeTooManySubsections Code = "GCFGENV-0015"
+	// CodeValidate is returned for errors from a Validator, unless they
+	// have a code of their own.
+	CodeValidate Code = "GCFGENV-0016"
+	// CodeUnknownName is the code of *UnknownNameError.
+	CodeUnknownName Code = "GCFGENV-0017"
+)
+
+// codedError attaches a Code to an error without changing its message.
+type codedError struct {
+	code Code
+	err  error
+	// show includes the code in the message, for WithErrorCodes.
+	show bool
+}
+
+func (e *codedError) Error() string {
+	if e.show {
+		return string(e.code) + ": " + e.err.Error()
+	}
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// Code returns the error's code.
+func (e *codedError) Code() Code {
+	return e.code
+}
+
+// Code returns CodeUnknownEnvVar.
+func (e *UnknownEnvVarError) Code() Code { return CodeUnknownEnvVar }
+
+// Code returns CodeNewSubsection.
+func (e *NewSubsectionError) Code() Code { return CodeNewSubsection }
+
+// Code returns CodeDeprecatedName.
+func (e *DeprecatedNameError) Code() Code { return CodeDeprecatedName }
+
+// Code returns CodeNameCollision.
+func (e *NameCollisionError) Code() Code { return CodeNameCollision }
+
+// Code returns CodeEmptyPrefix.
+func (e *EmptyPrefixError) Code() Code { return CodeEmptyPrefix }
+
+// Code returns CodePanic.
+func (e *PanicError) Code() Code { return CodePanic }
+
+// Code returns CodeUnknownName.
+func (e *UnknownNameError) Code() Code { return CodeUnknownName }
+
+// ErrorCode returns the code of err, or of the first error it wraps that has
+// one, or "" if there is none. For a warnings.List, use ErrorCode on its
+// Fatal and Warnings individually.
+func ErrorCode(err error) Code {
+	var coded interface{ Code() Code }
+	if errors.As(err, &coded) {
+		return coded.Code()
+	}
+	return ""
+}
+
+// withCode attaches code to err. Errors that already have a code, such as a
+// *PanicError from a walk, keep it.
+func withCode(code Code, err error) error {
+	if err == nil || ErrorCode(err) != "" {
+		return err
+	}
+	return &codedError{code: code, err: err}
+}
+
+// fileError attaches CodeFile to a fatal error from gcfg, keeping it in its
+// warnings.List so that gcfg.FatalOnly still works.
+func fileError(err error) error {
+	list, ok := err.(warnings.List)
+	if !ok {
+		return withCode(CodeFile, err)
+	}
+	list.Fatal = withCode(CodeFile, list.Fatal)
+	return list
+}
+
+// WithErrorCodes prefixes the message of each error and warning that has a
+// code with it, e.g. `GCFGENV-0002: cannot set Sec.Port from
+// APP_SEC_PORT="x": ...`. The code is available from ErrorCode either way.
+func WithErrorCodes() Option {
+	return func(o *options) {
+		o.errorCodes = true
+	}
+}
+
+// showErrorCodes applies WithErrorCodes to err, if it is among opts. It is
+// deferred by each of the Read functions.
+func showErrorCodes(err *error, opts []Option) {
+	if *err == nil || !newOptions(opts).errorCodes {
+		return
+	}
+	list, ok := (*err).(warnings.List)
+	if !ok {
+		*err = showErrorCode(*err)
+		return
+	}
+	if list.Fatal != nil {
+		list.Fatal = showErrorCode(list.Fatal)
+	}
+	warns := make([]error, len(list.Warnings))
+	for i, w := range list.Warnings {
+		warns[i] = showErrorCode(w)
+	}
+	list.Warnings = warns
+	*err = list
+}
+
+func showErrorCode(err error) error {
+	if errs, ok := err.(Errors); ok {
+		out := make(Errors, len(errs))
+		for i := range errs {
+			out[i] = showErrorCode(errs[i])
+		}
+		return out
+	}
+	code := ErrorCode(err)
+	if code == "" || strings.HasPrefix(err.Error(), string(code)+": ") {
+		return err
+	}
+	return &codedError{code: code, err: err, show: true}
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/warnings.v0"
+)
+
+func (s *Suite) TestErrorCodes(c *check.C) {
+	type config struct {
+		Sec struct {
+			Port int
+			Key  string `gcfgenv:"required_if=TLS"`
+			TLS  bool
+		}
+	}
+	cases := []struct {
+		file string
+		env  map[string]string
+		code Code
+	}{
+		{"", map[string]string{"APP_SEC_PORT": "x"}, CodeInvalidValue},
+		{"", map[string]string{"APP_SEC_TLS": "true"}, CodeRequired},
+		{"[sec\n", nil, CodeFile},
+		{"[sec]\nport = x\n", nil, CodeFile},
+	}
+	for _, tc := range cases {
+		var cfg config
+		err := readWithMapInto(strings.NewReader(tc.file), tc.env, "APP", &cfg)
+		if list, ok := err.(warnings.List); ok {
+			err = list.Fatal
+		}
+		c.Check(ErrorCode(err), check.Equals, tc.code)
+		c.Check(strings.HasPrefix(err.Error(), string(tc.code)), check.Equals, false)
+	}
+
+	err := readWithMapInto(strings.NewReader(""), nil, "APP", nil)
+	c.Check(ErrorCode(err), check.Equals, CodeInvalidTarget)
+	c.Check(errors.Is(err, ErrInvalidTarget), check.Equals, true)
+	c.Check(ErrorCode(errors.New("other")), check.Equals, Code(""))
+	c.Check(ErrorCode(nil), check.Equals, Code(""))
+}
+
+func (s *Suite) TestWithErrorCodes(c *check.C) {
+	type config struct {
+		Sec struct{ Port int }
+	}
+	var cfg config
+	env := map[string]string{"APP_SEC_PORT": "x"}
+	err := readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithErrorCodes())
+	c.Check(err, check.ErrorMatches, `GCFGENV-0002: cannot set Sec\.Port from APP_SEC_PORT="x": failed to parse "x" as int: expected integer`)
+	c.Check(ErrorCode(err), check.Equals, CodeInvalidValue)
+
+	// Warnings keep their types, and are prefixed individually.
+	env = map[string]string{"APP_SEC_PROT": "80"}
+	err = readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithStrict(), WithErrorCodes())
+	c.Check(gcfg.FatalOnly(err), check.IsNil)
+	c.Assert(err, check.FitsTypeOf, warnings.List{})
+	warns := err.(warnings.List).Warnings
+	c.Assert(warns, check.HasLen, 1)
+	c.Check(warns[0], check.ErrorMatches, `GCFGENV-0007: unknown environment variable APP_SEC_PROT for section "Sec"`)
+	var unknown *UnknownEnvVarError
+	c.Check(errors.As(warns[0], &unknown), check.Equals, true)
+
+	// The code is only added once.
+	err = readWithMapIntoEach(strings.NewReader(""), map[string]string{"APP_SEC_PORT": "x"}, "APP",
+		[]interface{}{&cfg}, nil, WithErrorCodes())
+	c.Check(err, check.ErrorMatches, `GCFGENV-0002: cannot set Sec\.Port .*`)
+}
@@ -0,0 +1,130 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A NameCollisionError reports fields of the same struct that map to the same
+// environment variable name, such as AnotherName (tagged
+// `gcfg:"another-name"`) and Another_Name. Only the first of them, in
+// declaration order, is set from the environment.
+type NameCollisionError struct {
+	// Name is the colliding part of the environment variable name.
+	Name string
+	// Fields are the paths of the colliding fields, in declaration order.
+	Fields []string
+}
+
+func (e *NameCollisionError) Error() string {
+	return fmt.Sprintf("fields %s all map to environment variable name %s; only %s is set from the environment",
+		strings.Join(e.Fields, ", "), e.Name, e.Fields[0])
+}
+
+// CheckStruct reports problems with the config struct that config points to
+// which would not otherwise be noticed until the wrong environment variable
+// is applied. Name collisions are reported as *NameCollisionError warnings,
+// in a gcfg-style warnings list, using the naming rules given by opts.
+func CheckStruct(config interface{}, opts ...Option) (err error) {
+	defer showErrorCodes(&err, opts)
+	ref, err := targetStruct(config)
+	if err != nil {
+		return err
+	}
+	ref, err = flattenEmbedded(ref)
+	if err != nil {
+		return err
+	}
+	return appendWarnings(nil, collisions(ref.Type(), newOptions(opts).naming))
+}
+
+// collisions returns the name collisions between the sections of the config
+// struct type t, and between the variables of each section.
+func collisions(t reflect.Type, n naming) []error {
+	out := structCollisions(t, n, "")
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		secType := sectionStructType(sf.Type)
+		if !sf.IsExported() || secType == nil || envDisabled(sf) {
+			continue
+		}
+		out = append(out, structCollisions(secType, n, fieldSectionName(sf)+".")...)
+	}
+	return out
+}
+
+func structCollisions(t reflect.Type, n naming, path string) []error {
+	var out []error
+	byName := make(map[string]*NameCollisionError)
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || envDisabled(sf) {
+			continue
+		}
+		name := n.field(sf)
+		if c, found := byName[name]; found {
+			if len(c.Fields) == 1 {
+				out = append(out, c)
+			}
+			c.Fields = append(c.Fields, path+fieldSectionName(sf))
+			continue
+		}
+		byName[name] = &NameCollisionError{Name: name, Fields: []string{path + fieldSectionName(sf)}}
+	}
+	return out
+}
+
+type shadowKey struct {
+	t reflect.Type
+	n naming
+}
+
+// shadowedFields caches the results of shadowed.
+var shadowedFields sync.Map
+
+// shadowed reports, for each field of the struct type t, whether an earlier
+// field maps to the same environment variable name, and so takes precedence
+// over it. Fields tagged gcfgenv:"-" keep their names from all other fields,
+// so that their variables cannot set a field with a similar name instead. It
+// returns nil if there are no such fields.
+func (n naming) shadowed(t reflect.Type) []bool {
+	key := shadowKey{t, n}
+	if v, ok := shadowedFields.Load(key); ok {
+		return v.([]bool)
+	}
+	var out []bool
+	seen := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.IsExported() && envDisabled(sf) {
+			seen[n.field(sf)] = true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || envDisabled(sf) {
+			continue
+		}
+		name := n.field(sf)
+		if seen[name] {
+			if out == nil {
+				out = make([]bool, t.NumField())
+			}
+			out[i] = true
+		}
+		seen[name] = true
+	}
+	shadowedFields.Store(key, out)
+	return out
+}
+
+// isShadowed reports whether the ith field of t is shadowed by an earlier
+// one.
+func (n naming) isShadowed(t reflect.Type, i int) bool {
+	s := n.shadowed(t)
+	return s != nil && s[i]
+}
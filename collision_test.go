@@ -0,0 +1,60 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/warnings.v0"
+)
+
+func (s *Suite) TestNameCollisions(c *check.C) {
+	type sec struct {
+		Another_Name string
+		AnotherName  string `gcfg:"another-name"`
+		Other        string
+	}
+	type config struct {
+		Sec    sec
+		Subsec map[string]*sec
+		My_Sec sec
+		MySec  sec `gcfg:"my-sec"`
+	}
+
+	err := CheckStruct(&config{})
+	c.Assert(err, check.FitsTypeOf, warnings.List{})
+	warns := err.(warnings.List).Warnings
+	c.Assert(warns, check.HasLen, 5)
+	c.Check(warns[0], check.ErrorMatches,
+		"fields My_Sec, my-sec all map to environment variable name MY_SEC; only My_Sec is set from the environment")
+	c.Check(warns[1], check.DeepEquals, &NameCollisionError{
+		Name:   "ANOTHER_NAME",
+		Fields: []string{"Sec.Another_Name", "Sec.another-name"},
+	})
+	c.Check(warns[2].(*NameCollisionError).Fields[0], check.Equals, "Subsec.Another_Name")
+
+	// Dash replacement avoids the collisions.
+	c.Check(CheckStruct(&config{}, WithDashReplacement("-")), check.IsNil)
+	c.Check(CheckStruct(config{}), check.ErrorMatches, "config must be .*")
+
+	// The first field wins.
+	env := map[string]string{
+		"APP_SEC_ANOTHER_NAME":      "env",
+		"APP_SUBSEC_a_ANOTHER_NAME": "env",
+		"APP_SUBSEC_b_ANOTHER_NAME": "env",
+		"APP_MY_SEC_OTHER":          "env",
+	}
+	cfg := config{}
+	src := "[subsec \"a\"]\nother = file\n"
+	err = readWithMapInto(strings.NewReader(src), env, "APP", &cfg, WithStrict())
+	c.Check(err, check.ErrorMatches, "(?s).*only My_Sec is set.*")
+	c.Check(gcfg.FatalOnly(err), check.IsNil)
+	c.Check(cfg.Sec, check.Equals, sec{Another_Name: "env"})
+	c.Check(*cfg.Subsec["a"], check.Equals, sec{Another_Name: "env", Other: "file"})
+	c.Check(*cfg.Subsec["b"], check.Equals, sec{Another_Name: "env"})
+	c.Check(cfg.My_Sec.Other, check.Equals, "env")
+	c.Check(cfg.MySec.Other, check.Equals, "")
+}
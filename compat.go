@@ -0,0 +1,127 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import "strings"
+
+// iniCompat rewrites src into a form that gcfg accepts, translating the
+// following constructs found in other INI dialects:
+//
+//   - Inline comments are only recognised when ';' or '#' is preceded by
+//     whitespace, so that values like "http://host/#anchor" survive.
+//   - Values may be wrapped in single quotes, which are taken literally.
+//   - Double-quoted values accept the additional escape \', and keep unknown
+//     escapes as-is.
+//   - Unquoted backslashes (e.g. in Windows paths) are taken literally.
+//   - Keys may be separated from values with ':' as well as '='.
+//   - Underscores in keys are treated as dashes.
+//
+// As in gcfg, a value continues on the next line if its line ends with a
+// backslash. Continued lines are joined before they are rewritten.
+//
+// Each input line produces exactly one output line, so that positions in
+// any errors reported by gcfg still refer to the original input. The lines
+// a continued value takes up after the first are left blank.
+func iniCompat(src []byte) []byte {
+	lines := strings.Split(string(src), "\n")
+	for i := 0; i < len(lines); i++ {
+		start, line := i, lines[i]
+		for isContinued(line) && i+1 < len(lines) {
+			i++
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\r"), `\`) + lines[i]
+			lines[i] = ""
+		}
+		lines[start] = iniCompatLine(line)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// isContinued reports whether line holds a variable whose value continues on
+// the next line.
+func isContinued(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#' || trimmed[0] == '[' {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimSuffix(line, "\r"), `\`)
+}
+
+func iniCompatLine(line string) string {
+	trimmed := strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+	if trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#' ||
+		trimmed[0] == '[' {
+		return line
+	}
+	sep := strings.IndexAny(trimmed, "=:")
+	if sep == -1 {
+		// A blank value, which gcfg can handle on its own.
+		return line
+	}
+	key := strings.TrimSpace(trimmed[:sep])
+	key = strings.ReplaceAll(key, "_", "-")
+	val := iniCompatValue(strings.TrimSpace(trimmed[sep+1:]))
+	return key + " = " + quoteGcfgValue(val)
+}
+
+// iniCompatValue extracts the value from the text that follows the separator,
+// removing any quoting and trailing comment.
+func iniCompatValue(s string) string {
+	var b strings.Builder
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		quote := s[0]
+		i := 1
+		for ; i < len(s) && s[i] != quote; i++ {
+			if quote == '"' && s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					b.WriteByte('\n')
+				case 't':
+					b.WriteByte('\t')
+				case '\\', '"', '\'':
+					b.WriteByte(s[i])
+				default:
+					// Keep unknown escapes as-is.
+					b.WriteByte('\\')
+					b.WriteByte(s[i])
+				}
+				continue
+			}
+			b.WriteByte(s[i])
+		}
+		if i < len(s) {
+			b.WriteString(stripInlineComment(s[i+1:]))
+			return b.String()
+		}
+		// There is no closing quote, so treat the whole thing as
+		// unquoted.
+		b.Reset()
+	}
+	b.WriteString(stripInlineComment(s))
+	return b.String()
+}
+
+// stripInlineComment removes a trailing comment, which must be introduced by
+// whitespace followed by ';' or '#'.
+func stripInlineComment(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] != ';' && s[i] != '#' {
+			continue
+		}
+		if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+			s = s[:i]
+			break
+		}
+	}
+	return strings.TrimRight(s, " \t")
+}
+
+var gcfgValueEscaper = strings.NewReplacer(
+	`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`,
+)
+
+// quoteGcfgValue returns s as a double-quoted gcfg value.
+func quoteGcfgValue(s string) string {
+	return `"` + gcfgValueEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+var iniCompatCases = []struct {
+	line string
+	want string
+}{
+	// Lines that gcfg already understands are left alone.
+	{"", ""},
+	{"[sec]", "[sec]"},
+	{`[sec "sub"]`, `[sec "sub"]`},
+	{"; comment", "; comment"},
+	{"  # comment", "  # comment"},
+	{"blank", "blank"},
+	// Values are always re-quoted.
+	{"field = value", `field = "value"`},
+	{"field=", `field = ""`},
+	{"field: value", `field = "value"`},
+	{"my_field = value", `my-field = "value"`},
+	// Inline comments require preceding whitespace.
+	{"field = value ; comment", `field = "value"`},
+	{"field = value\t# comment", `field = "value"`},
+	{"field = http://host/#anchor", `field = "http://host/#anchor"`},
+	{"field = a;b", `field = "a;b"`},
+	// Quoting.
+	{`field = 'single "quoted" ; value'`, `field = "single \"quoted\" ; value"`},
+	{`field = "a\'b\"c\\d"`, `field = "a'b\"c\\d"`},
+	{`field = "tab\there" ; comment`, `field = "tab\there"`},
+	{`field = "unknown \q escape"`, `field = "unknown \\q escape"`},
+	{`field = "quoted" suffix`, `field = "quoted suffix"`},
+	{`field = 'unterminated`, `field = "'unterminated"`},
+	// Backslashes outside of quotes are literal.
+	{`field = C:\dir\file`, `field = "C:\\dir\\file"`},
+	// Trailing carriage returns are dropped.
+	{"field = value\r", `field = "value"`},
+}
+
+func (s *Suite) TestIniCompatLine(c *check.C) {
+	for i, tc := range iniCompatCases {
+		c.Check(iniCompatLine(tc.line), check.Equals, tc.want,
+			check.Commentf("test case %d", i))
+	}
+}
+
+func (s *Suite) TestIniCompat(c *check.C) {
+	type sec struct {
+		Path    string
+		URL     string
+		My_Name string
+	}
+	type config struct {
+		Sec sec
+	}
+
+	var err error
+	var cfg config
+	configString := `[sec]
+path = C:\Program Files\app ; install location
+url: http://host/#anchor
+my_name = 'semi ; colon'
+`
+	r := strings.NewReader(configString)
+	err = readWithMapInto(r, map[string]string{}, "", &cfg)
+	c.Check(err, check.ErrorMatches, ".*unquoted '\\\\' must be followed.*")
+
+	cfg = config{}
+	r = strings.NewReader(configString)
+	err = readWithMapInto(r, map[string]string{}, "", &cfg, WithIniCompat())
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{Sec: sec{
+		Path:    `C:\Program Files\app`,
+		URL:     "http://host/#anchor",
+		My_Name: "semi ; colon",
+	}})
+
+	// Continued values are joined as gcfg joins them, and the following
+	// variables are still read.
+	cfg = config{}
+	r = strings.NewReader("[sec]\r\npath = C:\\dir \\\r\n  more ; comment\r\nurl = x\r\n")
+	err = readWithMapInto(r, map[string]string{}, "", &cfg, WithIniCompat())
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Sec.Path, check.Equals, `C:\dir   more`)
+	c.Check(cfg.Sec.URL, check.Equals, "x")
+	c.Check(string(iniCompat([]byte("[sec]\na = b\\\n c\nd = e\n"))), check.Equals,
+		"[sec]\na = \"b c\"\n\nd = \"e\"\n")
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// maxScale is the largest Scale of a Decimal.
+const maxScale = 18
+
+// A Decimal is an exact decimal number, such as a price, for values where the
+// rounding of a float64 is unacceptable. It is read from the file or
+// environment in plain notation, e.g. "12.34" or "-0.5", and keeps the number
+// of decimal places it was given.
+//
+// Fields with a gcfgenv:"scale=n" directive are stored with exactly n decimal
+// places, so that Units is in minor units (e.g. cents for scale=2), and it is
+// an error for their values to have more. Types such as shopspring/decimal's
+// Decimal, which implement encoding.TextUnmarshaler, can also be used.
+type Decimal struct {
+	// Units is the value in units of 10^-Scale, e.g. 1234 for "12.34".
+	Units int64
+	// Scale is the number of decimal places.
+	Scale int
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Decimal) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	digits := strings.TrimLeft(whole, "+-")
+	if len(whole)-len(digits) > 1 || digits+frac == "" || len(frac) > maxScale ||
+		strings.ContainsAny(digits+frac, "+-") {
+		return fmt.Errorf("invalid decimal %q", text)
+	}
+	units, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid decimal %q", text)
+	}
+	*d = Decimal{Units: units, Scale: len(frac)}
+	return nil
+}
+
+func (d Decimal) String() string {
+	s := strconv.FormatInt(d.Units, 10)
+	if d.Scale <= 0 {
+		return s
+	}
+	sign := ""
+	if d.Units < 0 {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= d.Scale {
+		s = strings.Repeat("0", d.Scale-len(s)+1) + s
+	}
+	return sign + s[:len(s)-d.Scale] + "." + s[len(s)-d.Scale:]
+}
+
+// MinorUnits returns d in units of 10^-scale, e.g. cents for a scale of 2. It
+// is an error for d to have more than scale decimal places that are not zero,
+// or for the result to be out of range.
+func (d Decimal) MinorUnits(scale int) (int64, error) {
+	if scale < 0 || scale > maxScale {
+		return 0, fmt.Errorf("invalid scale %d", scale)
+	}
+	units := d.Units
+	for i := d.Scale; i > scale; i-- {
+		if units%10 != 0 {
+			return 0, fmt.Errorf("%s has more than %d decimal places", d, scale)
+		}
+		units /= 10
+	}
+	for i := d.Scale; i < scale; i++ {
+		if units > (1<<63-1)/10 || units < -1<<63/10 {
+			return 0, fmt.Errorf("%s is out of range for %d decimal places", d, scale)
+		}
+		units *= 10
+	}
+	return units, nil
+}
+
+// Float64 returns the nearest float64 to d.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+var decimalType = reflect.TypeOf(Decimal{})
+
+// fillDecimalScales stores Decimal fields (and slices of them) that have a
+// gcfgenv:"scale=n" directive with n decimal places.
+func fillDecimalScales(ref reflect.Value) error {
+	return walkFields(ref, func(path []string, sf reflect.StructField, f reflect.Value) error {
+		v, ok := parseFieldTag(sf)["scale"]
+		if !ok {
+			return nil
+		}
+		scale, err := strconv.Atoi(v)
+		if err != nil || scale < 0 || scale > maxScale {
+			return fmt.Errorf("invalid scale directive on %s: %q",
+				strings.Join(path, "."), v)
+		}
+		if err := setScale(f, scale); err != nil {
+			return fmt.Errorf("%s: %v", strings.Join(path, "."), err)
+		}
+		return nil
+	})
+}
+
+func setScale(f reflect.Value, scale int) error {
+	switch {
+	case f.Type() == decimalType:
+		d := f.Addr().Interface().(*Decimal)
+		units, err := d.MinorUnits(scale)
+		if err != nil {
+			return err
+		}
+		*d = Decimal{Units: units, Scale: scale}
+	case f.Kind() == reflect.Ptr:
+		if !f.IsNil() {
+			return setScale(f.Elem(), scale)
+		}
+	case f.Kind() == reflect.Slice:
+		for i := 0; i < f.Len(); i++ {
+			if err := setScale(f.Index(i), scale); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestDecimal(c *check.C) {
+	for text, want := range map[string]Decimal{
+		"12.34":  {1234, 2},
+		" 12.30": {1230, 2},
+		"-0.5":   {-5, 1},
+		"+7":     {7, 0},
+		".25":    {25, 2},
+		"-.05":   {-5, 2},
+	} {
+		var d Decimal
+		c.Check(d.UnmarshalText([]byte(text)), check.IsNil)
+		c.Check(d, check.Equals, want, check.Commentf("%q", text))
+	}
+	for _, text := range []string{"", "-", ".", "1e3", "1.2.3", "--1", "1-2", "12,34", "99999999999999999999"} {
+		var d Decimal
+		c.Check(d.UnmarshalText([]byte(text)), check.ErrorMatches, `invalid decimal ".*"`, check.Commentf("%q", text))
+	}
+
+	c.Check(Decimal{1234, 2}.String(), check.Equals, "12.34")
+	c.Check(Decimal{-5, 2}.String(), check.Equals, "-0.05")
+	c.Check(Decimal{7, 0}.String(), check.Equals, "7")
+	c.Check(Decimal{-5, 1}.Float64(), check.Equals, -0.5)
+
+	units, err := Decimal{1230, 2}.MinorUnits(1)
+	c.Check(err, check.IsNil)
+	c.Check(units, check.Equals, int64(123))
+	units, err = Decimal{5, 0}.MinorUnits(3)
+	c.Check(err, check.IsNil)
+	c.Check(units, check.Equals, int64(5000))
+	_, err = Decimal{1234, 2}.MinorUnits(1)
+	c.Check(err, check.ErrorMatches, `12.34 has more than 1 decimal places`)
+	_, err = Decimal{1 << 62, 0}.MinorUnits(2)
+	c.Check(err, check.ErrorMatches, `.* is out of range for 2 decimal places`)
+
+	type config struct {
+		Billing struct {
+			Price    Decimal `gcfgenv:"scale=2"`
+			Discount Decimal
+			Tiers    []Decimal `gcfgenv:"scale=2"`
+		}
+	}
+	cfg := config{}
+	err = readWithMapInto(strings.NewReader(`[billing]
+price = 9.9
+discount = 0.125
+tiers = 10
+`), map[string]string{
+		"APPNAME_BILLING_TIERS": "20.5,30.25",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Billing.Price, check.Equals, Decimal{990, 2})
+	c.Check(cfg.Billing.Discount, check.Equals, Decimal{125, 3})
+	c.Check(cfg.Billing.Tiers, check.DeepEquals, []Decimal{{1000, 2}, {2050, 2}, {3025, 2}})
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader("[billing]\nprice = 9.999\n"), nil, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Billing.Price: 9.999 has more than 2 decimal places`)
+
+	type badConfig struct {
+		Sec struct {
+			Amount Decimal `gcfgenv:"scale=two"`
+		}
+	}
+	err = readWithMapInto(strings.NewReader("[sec]\namount = 1\n"), nil, "APPNAME", &badConfig{})
+	c.Check(err, check.ErrorMatches, `invalid scale directive on Sec.Amount: "two"`)
+}
@@ -0,0 +1,318 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/gcfg.v1/scanner"
+	"gopkg.in/gcfg.v1/token"
+)
+
+// A Document is a configuration file as a list of sections and variables,
+// which can be inspected and modified before being applied to a config struct
+// (e.g. by tools that lint or migrate configuration files). Comments and
+// formatting are kept, so that writing out an unmodified Document gives the
+// original file.
+type Document struct {
+	// Sections are in the order they appear in the file. A section may
+	// appear more than once.
+	Sections []*DocSection
+	// trailing holds the comments and blank lines after the last section
+	// or variable.
+	trailing []string
+	bom      bool
+	// eol is false if the file does not end with a newline.
+	eol bool
+	// cr is "\r" if the file has Windows line endings.
+	cr string
+}
+
+// A DocSection is a section header in a Document, along with the variables
+// that follow it.
+type DocSection struct {
+	Name       string
+	Subsection string
+	// Comments holds the comment and blank lines immediately before the
+	// header, as written (e.g. "; Listen on all interfaces").
+	Comments []string
+	// Line is the line number of the header, or 0 if it was added.
+	Line      int
+	Variables []*DocVariable
+	// raw holds the lines of the header as written, for the values of Name
+	// and Subsection in orig.
+	raw  []string
+	orig [2]string
+}
+
+// A DocVariable is a variable in a Document.
+type DocVariable struct {
+	Name  string
+	Value string
+	// Blank is true for variables given without a value, which gcfg
+	// treats as true for booleans.
+	Blank bool
+	// Comments holds the comment and blank lines immediately before the
+	// variable, as written.
+	Comments []string
+	// Line is the line number of the variable, or 0 if it was added.
+	Line int
+	// raw holds the lines of the variable as written, for the values of
+	// the other fields in orig.
+	raw  []string
+	orig docValue
+}
+
+// docValue holds the parts of a DocVariable that determine how it is written.
+type docValue struct {
+	name, value string
+	blank       bool
+}
+
+// ParseDocument reads the gcfg-formatted data in r into a Document.
+func ParseDocument(r io.Reader) (*Document, error) {
+	src, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := &Document{bom: bytes.HasPrefix(src, utf8BOM)}
+	src, _ = skipBOM(src)
+	err = gcfg.FatalOnly(gcfg.ReadInto(&struct{}{}, bytes.NewReader(src)))
+	if err != nil {
+		return nil, fileError(err)
+	}
+	d.parse(string(src))
+	return d, nil
+}
+
+// docToken is a token of a section header or variable.
+type docToken struct {
+	tok token.Token
+	lit string
+}
+
+// parse fills in d from src, which must be valid gcfg syntax.
+func (d *Document) parse(src string) {
+	d.eol = src == "" || strings.HasSuffix(src, "\n")
+	var lines []string
+	if src != "" {
+		lines = strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	}
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+	// next is the index of the first line not yet assigned to a section
+	// or variable.
+	var toks []docToken
+	start, next := 0, 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok != token.EOL && tok != token.EOF {
+			if len(toks) == 0 {
+				if tok == token.COMMENT {
+					continue
+				}
+				start = file.Position(pos).Line
+			}
+			toks = append(toks, docToken{tok, lit})
+			continue
+		}
+		if len(toks) > 0 {
+			end := len(lines)
+			if tok == token.EOL {
+				end = file.Position(pos).Line
+			}
+			d.add(toks, lines[next:start-1], lines[start-1:end], start)
+			next = end
+			toks = nil
+		}
+		if tok == token.EOF {
+			break
+		}
+	}
+	d.trailing = lines[next:]
+	d.cr = lineEnd(lines)
+}
+
+// add adds the section header or variable made up of toks, with the given
+// comment lines, lines as written, and line number.
+func (d *Document) add(toks []docToken, comments, raw []string, line int) {
+	if toks[0].tok == token.LBRACK {
+		sec := &DocSection{Comments: comments, Line: line, raw: raw}
+		if len(toks) > 1 {
+			sec.Name = toks[1].lit
+		}
+		if len(toks) > 2 && toks[2].tok == token.STRING {
+			sec.Subsection = unquote(toks[2].lit)
+		}
+		sec.orig = [2]string{sec.Name, sec.Subsection}
+		d.Sections = append(d.Sections, sec)
+		return
+	}
+	v := &DocVariable{Name: toks[0].lit, Blank: true, Comments: comments, Line: line, raw: raw}
+	if len(toks) > 1 && toks[1].tok == token.ASSIGN {
+		v.Blank = false
+		if len(toks) > 2 && toks[2].tok == token.STRING {
+			v.Value = unquote(toks[2].lit)
+		}
+	}
+	v.orig = v.value()
+	if len(d.Sections) == 0 {
+		// gcfg does not allow variables outside of sections.
+		return
+	}
+	sec := d.Sections[len(d.Sections)-1]
+	sec.Variables = append(sec.Variables, v)
+}
+
+func (v *DocVariable) value() docValue {
+	return docValue{v.Name, v.Value, v.Blank}
+}
+
+// Section returns the first section in d with the given name (which, as in
+// gcfg, is case-insensitive) and subsection, or nil if there is none.
+func (d *Document) Section(name, subsection string) *DocSection {
+	for _, s := range d.Sections {
+		if strings.EqualFold(s.Name, name) && s.Subsection == subsection {
+			return s
+		}
+	}
+	return nil
+}
+
+// AddSection adds a section with the given name and subsection to the end of
+// d, and returns it.
+func (d *Document) AddSection(name, subsection string) *DocSection {
+	s := &DocSection{Name: name, Subsection: subsection}
+	d.Sections = append(d.Sections, s)
+	return s
+}
+
+// RemoveSection removes every section with the given name and subsection
+// from d, along with their variables and comments.
+func (d *Document) RemoveSection(name, subsection string) {
+	out := d.Sections[:0]
+	for _, s := range d.Sections {
+		if !strings.EqualFold(s.Name, name) || s.Subsection != subsection {
+			out = append(out, s)
+		}
+	}
+	d.Sections = out
+}
+
+// Variable returns the last variable in s with the given name (which is
+// case-insensitive), since gcfg uses the last value of variables that are
+// given more than once, or nil if there is none.
+func (s *DocSection) Variable(name string) *DocVariable {
+	for i := len(s.Variables) - 1; i >= 0; i-- {
+		if strings.EqualFold(s.Variables[i].Name, name) {
+			return s.Variables[i]
+		}
+	}
+	return nil
+}
+
+// Values returns every value of the variable with the given name in s, for
+// multi-valued variables. Blank variables have the value "true".
+func (s *DocSection) Values(name string) []string {
+	var out []string
+	for _, v := range s.Variables {
+		if !strings.EqualFold(v.Name, name) {
+			continue
+		}
+		if v.Blank {
+			out = append(out, "true")
+			continue
+		}
+		out = append(out, v.Value)
+	}
+	return out
+}
+
+// Set sets the value of the last variable in s with the given name, or adds
+// a new one if there is none.
+func (s *DocSection) Set(name, value string) {
+	if v := s.Variable(name); v != nil {
+		v.Value, v.Blank = value, false
+		return
+	}
+	s.Add(name, value)
+}
+
+// Add adds a variable to the end of s, e.g. another value for a multi-valued
+// variable.
+func (s *DocSection) Add(name, value string) {
+	s.Variables = append(s.Variables, &DocVariable{Name: name, Value: value})
+}
+
+// Remove removes every variable in s with the given name, along with their
+// comments.
+func (s *DocSection) Remove(name string) {
+	out := s.Variables[:0]
+	for _, v := range s.Variables {
+		if !strings.EqualFold(v.Name, name) {
+			out = append(out, v)
+		}
+	}
+	s.Variables = out
+}
+
+// Bytes returns d in gcfg syntax. Sections and variables that have not been
+// changed are written as they were read, and those that have keep their
+// formatting and comments as far as possible (see UpdateValue). The lines
+// before each of them are written from its Comments.
+func (d *Document) Bytes() []byte {
+	var lines []string
+	for _, s := range d.Sections {
+		lines = append(lines, s.Comments...)
+		lines = append(lines, s.lines(d.cr)...)
+		indent := s.indent()
+		for _, v := range s.Variables {
+			lines = append(lines, v.Comments...)
+			lines = append(lines, v.lines(indent, d.cr)...)
+		}
+	}
+	lines = append(lines, d.trailing...)
+	var buf bytes.Buffer
+	if d.bom {
+		buf.Write(utf8BOM)
+	}
+	buf.WriteString(strings.Join(lines, "\n"))
+	if d.eol && len(lines) > 0 {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// ApplyEnv sets the values from d in the corresponding fields of config, then
+// applies overrides from env, as Parsed.ApplyEnv does.
+func (d *Document) ApplyEnv(env map[string]string, envPrefix string, config interface{}, opts ...Option) error {
+	return readWithMapInto(bytes.NewReader(d.Bytes()), env, envPrefix, config, opts...)
+}
+
+func formatSectionHeader(name, subsection string) string {
+	if subsection == "" {
+		return "[" + name + "]"
+	}
+	return "[" + name + " " + quoteGcfgValue(subsection) + "]"
+}
+
+func formatVariable(v *DocVariable) string {
+	if v.Blank {
+		return v.Name
+	}
+	return v.Name + " = " + formatGcfgValue(v.Value)
+}
+
+// formatGcfgValue returns s as a gcfg value, only quoting it if necessary.
+func formatGcfgValue(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, "\";#\\\n\t") {
+		return quoteGcfgValue(s)
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+const documentSrc = `; Example configuration.
+
+[server] ; the main server
+	# Listen on all interfaces.
+	listen = 0.0.0.0:8080
+	verbose
+	motd = hello, \
+world
+
+; Backends.
+[backend "a.example.com"]
+	weight = 2
+	tag = x
+	tag = y
+
+; End of file.
+`
+
+func (s *Suite) TestDocumentRoundTrip(c *check.C) {
+	for _, src := range []string{
+		documentSrc,
+		strings.TrimSuffix(documentSrc, "\n"),
+		strings.ReplaceAll(documentSrc, "\n", "\r\n"),
+		"\ufeff" + documentSrc,
+		"",
+		"; only a comment\n",
+		"[sec]",
+	} {
+		d, err := ParseDocument(strings.NewReader(src))
+		c.Assert(err, check.IsNil, check.Commentf("%q", src))
+		c.Check(string(d.Bytes()), check.Equals, src)
+	}
+
+	_, err := ParseDocument(strings.NewReader("[sec\n"))
+	c.Check(ErrorCode(err), check.Equals, CodeFile)
+}
+
+func (s *Suite) TestDocumentModel(c *check.C) {
+	d, err := ParseDocument(strings.NewReader(documentSrc))
+	c.Assert(err, check.IsNil)
+	c.Assert(d.Sections, check.HasLen, 2)
+
+	server := d.Section("Server", "")
+	c.Assert(server, check.NotNil)
+	c.Check(server.Line, check.Equals, 3)
+	c.Check(server.Comments, check.DeepEquals, []string{"; Example configuration.", ""})
+	c.Assert(server.Variables, check.HasLen, 3)
+	c.Check(server.Variable("LISTEN").Value, check.Equals, "0.0.0.0:8080")
+	c.Check(server.Variable("listen").Comments, check.DeepEquals, []string{"\t# Listen on all interfaces."})
+	c.Check(server.Variable("verbose").Blank, check.Equals, true)
+	c.Check(server.Values("verbose"), check.DeepEquals, []string{"true"})
+	c.Check(server.Variable("motd").Value, check.Equals, "hello, world")
+	c.Check(server.Variable("missing"), check.IsNil)
+
+	backend := d.Section("backend", "a.example.com")
+	c.Assert(backend, check.NotNil)
+	c.Check(backend.Line, check.Equals, 11)
+	c.Check(backend.Values("tag"), check.DeepEquals, []string{"x", "y"})
+	c.Check(backend.Variable("tag").Line, check.Equals, 14)
+	c.Check(d.Section("backend", "b"), check.IsNil)
+
+	// Changed and added entries are formatted afresh; the rest are kept as
+	// they were.
+	server.Set("listen", "127.0.0.1:8080")
+	server.Remove("verbose")
+	server.Set("banner", "; not a comment")
+	backend.Name = "Backend"
+	d.RemoveSection("nonexistent", "")
+	d.AddSection("cache", "").Add("size", "10")
+	c.Check(string(d.Bytes()), check.Equals, `; Example configuration.
+
+[server] ; the main server
+	# Listen on all interfaces.
+	listen = 127.0.0.1:8080
+	motd = hello, \
+world
+	banner = "; not a comment"
+
+; Backends.
+[Backend "a.example.com"]
+	weight = 2
+	tag = x
+	tag = y
+[cache]
+	size = 10
+
+; End of file.
+`)
+
+	type config struct {
+		Server struct {
+			Listen string
+			Motd   string
+			Banner string
+		}
+		Backend map[string]*struct {
+			Weight int
+			Tag    []string
+		}
+		Cache struct{ Size int }
+	}
+	var cfg config
+	err = d.ApplyEnv(map[string]string{"APP_CACHE_SIZE": "20"}, "APP", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Listen, check.Equals, "127.0.0.1:8080")
+	c.Check(cfg.Server.Banner, check.Equals, "; not a comment")
+	c.Check(cfg.Backend["a.example.com"].Tag, check.DeepEquals, []string{"x", "y"})
+	c.Check(cfg.Cache.Size, check.Equals, 20)
+
+	d.RemoveSection("BACKEND", "a.example.com")
+	c.Check(d.Sections, check.HasLen, 2)
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+type serverCore struct {
+	Server struct {
+		Listen string
+	}
+	Log struct {
+		Level string
+	}
+}
+
+func (s *Suite) TestEmbeddedConfig(c *check.C) {
+	type billing struct {
+		serverCore
+		Billing struct {
+			Currency string
+		}
+	}
+	env := map[string]string{
+		"APPNAME_SERVER_LISTEN":    ":81",
+		"APPNAME_BILLING_CURRENCY": "EUR",
+	}
+
+	cfg := billing{}
+	err := readWithMapInto(strings.NewReader("[server]\nlisten = :80\n[log]\nlevel = debug\n"), env, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Listen, check.Equals, ":81")
+	c.Check(cfg.Log.Level, check.Equals, "debug")
+	c.Check(cfg.Billing.Currency, check.Equals, "EUR")
+
+	cfg = billing{}
+	err = applyWithMapInto(env, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Listen, check.Equals, ":81")
+
+	env["APPNAME_SERVER_LISTEN"] = ":82"
+	err = reapplyEnvSectionWithMap(&cfg, "server", env, "APPNAME")
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Listen, check.Equals, ":82")
+
+	specs, err := EnvVarsFor(&billing{}, "APPNAME")
+	c.Assert(err, check.IsNil)
+	c.Assert(specs, check.HasLen, 3)
+	c.Check(specs[0].Name, check.Equals, "APPNAME_SERVER_LISTEN")
+	c.Check(specs[2].Name, check.Equals, "APPNAME_BILLING_CURRENCY")
+
+	// Each section may only come from one of the structs.
+	type clash struct {
+		serverCore
+		Log struct {
+			Format string
+		}
+	}
+	err = readWithMapInto(strings.NewReader(""), nil, "APPNAME", &clash{})
+	c.Check(err, check.ErrorMatches, `section "Log" is used by more than one destination`)
+}
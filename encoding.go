@@ -0,0 +1,149 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// An EncodingError is a warning about the encoding of a configuration file,
+// reported when WithStrict is used. Edits to a file saved with an unexpected
+// encoding can appear to have no effect, as gcfg may fail to recognise the
+// sections or variables in it.
+type EncodingError struct {
+	// Encoding is "UTF-8" for a file that begins with a UTF-8 byte order
+	// mark (which is skipped), "UTF-16LE" or "UTF-16BE" for a file that
+	// appears to be UTF-16, or "" for a file that is otherwise not valid
+	// UTF-8.
+	Encoding string
+	// Offset is the offset of the first byte that is not valid UTF-8, or
+	// -1 if there is none.
+	Offset int
+}
+
+func (e *EncodingError) Error() string {
+	switch e.Encoding {
+	case "UTF-8":
+		return "configuration file begins with a UTF-8 byte order mark, which was skipped"
+	case "":
+		return fmt.Sprintf("configuration file is not valid UTF-8 (at offset %d)", e.Offset)
+	}
+	return fmt.Sprintf("configuration file appears to be encoded as %s, not UTF-8", e.Encoding)
+}
+
+// Code returns CodeEncoding.
+func (e *EncodingError) Code() Code { return CodeEncoding }
+
+var (
+	utf8BOM    = []byte("\ufeff")
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// skipBOM removes any UTF-8 byte order mark from src, and returns an
+// *EncodingError if it had one or does not appear to be UTF-8.
+func skipBOM(src []byte) ([]byte, *EncodingError) {
+	if bytes.HasPrefix(src, utf8BOM) {
+		src = src[len(utf8BOM):]
+		if invalid := invalidUTF8(src); invalid >= 0 {
+			return src, &EncodingError{Offset: invalid + len(utf8BOM)}
+		}
+		return src, &EncodingError{Encoding: "UTF-8", Offset: -1}
+	}
+	invalid := invalidUTF8(src)
+	switch {
+	case bytes.HasPrefix(src, utf16LEBOM):
+		return src, &EncodingError{Encoding: "UTF-16LE", Offset: invalid}
+	case bytes.HasPrefix(src, utf16BEBOM):
+		return src, &EncodingError{Encoding: "UTF-16BE", Offset: invalid}
+	}
+	// UTF-16 without a byte order mark is valid UTF-8, but mostly ASCII
+	// text in UTF-16 has a NUL in every other byte.
+	if nul := bytes.IndexByte(src, 0); nul >= 0 {
+		encoding := "UTF-16BE"
+		if nul%2 == 1 {
+			encoding = "UTF-16LE"
+		}
+		return src, &EncodingError{Encoding: encoding, Offset: invalid}
+	}
+	if invalid >= 0 {
+		return src, &EncodingError{Offset: invalid}
+	}
+	return src, nil
+}
+
+// invalidUTF8 returns the offset of the first byte of src that is not valid
+// UTF-8, or -1.
+func invalidUTF8(src []byte) int {
+	if utf8.Valid(src) {
+		return -1
+	}
+	for i := 0; i < len(src); {
+		r, size := utf8.DecodeRune(src[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// A UTF8Mode says what to do with values in the file or environment that are
+// not valid UTF-8, e.g. corrupted by a templating system.
+type UTF8Mode int
+
+const (
+	// UTF8Unchecked passes the values of environment variables on as they
+	// are. This is the default.
+	UTF8Unchecked UTF8Mode = iota
+	// UTF8Reject makes invalid values an error: an *EncodingError for the
+	// file, or a *ValueError for an environment variable.
+	UTF8Reject
+	// UTF8Replace replaces each invalid sequence with the Unicode
+	// replacement character, U+FFFD.
+	UTF8Replace
+)
+
+// WithUTF8Values checks that the values of environment variables are valid
+// UTF-8, so that corrupted bytes do not reach e.g. HTTP headers. gcfg already
+// rejects configuration files that are not valid UTF-8, with a syntax error;
+// with UTF8Reject they are reported as an *EncodingError instead, and with
+// UTF8Replace they are read with the invalid sequences replaced. The whole
+// file is checked, including comments.
+func WithUTF8Values(mode UTF8Mode) Option {
+	return func(o *options) {
+		o.utf8Mode = mode
+	}
+}
+
+// checkFileUTF8 applies the UTF8Mode to src, from which any byte order mark
+// has been removed. warn is the result of skipBOM.
+func (o *options) checkFileUTF8(src []byte, warn *EncodingError) ([]byte, error) {
+	switch o.utf8Mode {
+	case UTF8Reject:
+		if warn != nil && warn.Offset >= 0 {
+			return nil, warn
+		}
+	case UTF8Replace:
+		if warn != nil && warn.Offset >= 0 {
+			return bytes.ToValidUTF8(src, []byte(string(utf8.RuneError))), nil
+		}
+	}
+	return src, nil
+}
+
+// checkUTF8 applies the UTF8Mode to val, the value of an environment
+// variable.
+func (o *options) checkUTF8(val string) (string, error) {
+	if o.utf8Mode == UTF8Unchecked || utf8.ValidString(val) {
+		return val, nil
+	}
+	if o.utf8Mode == UTF8Replace {
+		return strings.ToValidUTF8(val, string(utf8.RuneError)), nil
+	}
+	return "", fmt.Errorf("value is not valid UTF-8")
+}
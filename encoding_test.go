@@ -0,0 +1,115 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/warnings.v0"
+)
+
+func utf16LE(s string) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+func (s *Suite) TestSkipBOM(c *check.C) {
+	cases := []struct {
+		src      []byte
+		want     []byte
+		encoding string
+		offset   int
+		ok       bool
+	}{
+		{[]byte("[sec]\n"), []byte("[sec]\n"), "", 0, true},
+		{[]byte("\ufeff[sec]\n"), []byte("[sec]\n"), "UTF-8", -1, false},
+		{[]byte("\ufeff[sec]\n\xe9"), []byte("[sec]\n\xe9"), "", 9, false},
+		{[]byte("[sec]\nfield = caf\xe9\n"), []byte("[sec]\nfield = caf\xe9\n"), "", 17, false},
+		{append([]byte{0xff, 0xfe}, utf16LE("[sec]")...), nil, "UTF-16LE", 0, false},
+		{utf16LE("[sec]"), nil, "UTF-16LE", -1, false},
+		{[]byte("\x00[\x00s"), nil, "UTF-16BE", -1, false},
+	}
+	for _, tc := range cases {
+		got, warn := skipBOM(tc.src)
+		if tc.want != nil {
+			c.Check(got, check.DeepEquals, tc.want)
+		}
+		if tc.ok {
+			c.Check(warn, check.IsNil)
+			continue
+		}
+		c.Assert(warn, check.NotNil, check.Commentf("%q", tc.src))
+		c.Check(warn.Encoding, check.Equals, tc.encoding)
+		c.Check(warn.Offset, check.Equals, tc.offset)
+	}
+}
+
+func (s *Suite) TestEncodingWarnings(c *check.C) {
+	type config struct {
+		Sec struct{ Field string }
+	}
+	src := []byte("\ufeff[sec]\nfield = value\n")
+
+	// Without WithStrict, the BOM is skipped silently.
+	var cfg config
+	err := readWithMapInto(bytes.NewReader(src), nil, "APP", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Sec.Field, check.Equals, "value")
+
+	cfg = config{}
+	err = readWithMapInto(bytes.NewReader(src), nil, "APP", &cfg, WithStrict())
+	c.Check(gcfg.FatalOnly(err), check.IsNil)
+	c.Check(err, check.ErrorMatches, "(?s).*configuration file begins with a UTF-8 byte order mark, which was skipped.*")
+	c.Check(cfg.Sec.Field, check.Equals, "value")
+
+	// The warning accompanies any error parsing the file.
+	err = readWithMapInto(bytes.NewReader(utf16LE("[sec]\nfield = value\n")), nil, "APP", &cfg, WithStrict())
+	c.Check(gcfg.FatalOnly(err), check.NotNil)
+	c.Assert(err, check.FitsTypeOf, warnings.List{})
+	warns := err.(warnings.List).Warnings
+	c.Assert(warns, check.HasLen, 1)
+	c.Check(warns[0], check.ErrorMatches, "configuration file appears to be encoded as UTF-16LE, not UTF-8")
+	c.Check(ErrorCode(warns[0]), check.Equals, CodeEncoding)
+}
+
+func (s *Suite) TestUTF8Values(c *check.C) {
+	type config struct {
+		Sec struct {
+			Header string
+			Tags   []string
+		}
+	}
+	file := "[sec]\nheader = caf\xe9\n"
+	env := map[string]string{"APPNAME_SEC_TAGS": "a,b\xff"}
+
+	// Environment variables are passed on as they are by default, but gcfg
+	// rejects the file.
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(""), env, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Tags, check.DeepEquals, []string{"a", "b\xff"})
+	err = readWithMapInto(strings.NewReader(file), nil, "APPNAME", &config{})
+	c.Check(err, check.ErrorMatches, `2:13: illegal UTF-8 encoding`)
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(file), env, "APPNAME", &cfg, WithUTF8Values(UTF8Replace))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Header, check.Equals, "caf\ufffd")
+	c.Check(cfg.Sec.Tags, check.DeepEquals, []string{"a", "b\ufffd"})
+
+	err = readWithMapInto(strings.NewReader(file), nil, "APPNAME", &config{}, WithUTF8Values(UTF8Reject))
+	c.Check(err, check.DeepEquals, &EncodingError{Offset: 18})
+	c.Check(ErrorCode(err), check.Equals, CodeEncoding)
+
+	err = readWithMapInto(strings.NewReader(""), env, "APPNAME", &config{}, WithUTF8Values(UTF8Reject))
+	c.Check(err, check.ErrorMatches, `.*APPNAME_SEC_TAGS.*: value is not valid UTF-8`)
+	c.Check(ErrorCode(err), check.Equals, CodeInvalidValue)
+}
@@ -0,0 +1,121 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SubsectionPlaceholder stands in for subsection names (and the keys of
+// map[string]string sections) in the results of EnvVarsFor.
+const SubsectionPlaceholder = "<name>"
+
+// An EnvVarSpec describes an environment variable accepted for a config
+// struct.
+type EnvVarSpec struct {
+	// Name is the name of the variable. For subsections, it contains
+	// SubsectionPlaceholder (once for each level of subsection) in place
+	// of the subsection name, e.g. "APPNAME_POOL_<name>_SIZE".
+	Name string
+	// Type is the Go type of the field, e.g. "[]string".
+	Type string
+	// Path is the path of the field, e.g. "Pool.<name>.Size", made up of
+	// field names (or their gcfg tags) and placeholders.
+	Path string
+	// Roles holds the roles in the field's gcfgenv:"role=..." directive,
+	// if any, e.g. for admin UIs to hide the field from other viewers.
+	Roles []string
+}
+
+// EnvVarsFor returns every environment variable that overrides a field of
+// the config struct that config points to, in the same order as the struct,
+// for generating help text and documentation. This includes the variables
+// that set sections implementing encoding.TextUnmarshaler as a whole, and
+// those that override the components of DSN fields. The naming options in
+// opts are taken into account.
+func EnvVarsFor(config interface{}, prefix string, opts ...Option) ([]EnvVarSpec, error) {
+	ref, err := targetStruct(config)
+	if err != nil {
+		return nil, err
+	}
+	ref, err = flattenEmbedded(ref)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	prefix, err = o.resolvePrefix(prefix, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	n := o.naming
+	var out []EnvVarSpec
+	t := ref.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Name == rawSectionsField || n.isShadowed(t, i) || envDisabled(sf) {
+			continue
+		}
+		if isStringMapSection(sf.Type) {
+			out = append(out, EnvVarSpec{
+				Name:  n.envVarName(prefix, fieldSectionName(sf)) + Separator + SubsectionPlaceholder,
+				Type:  sf.Type.Elem().String(),
+				Path:  fieldSectionName(sf) + "." + SubsectionPlaceholder,
+				Roles: fieldRoles(sf),
+			})
+			continue
+		}
+		secType := sectionStructType(sf.Type)
+		if secType == nil {
+			continue
+		}
+		path := []string{fieldSectionName(sf)}
+		switch {
+		case isSubsectionMap(sf.Type):
+			path = append(path, SubsectionPlaceholder)
+		case isNestedSubsectionMap(sf.Type):
+			path = append(path, SubsectionPlaceholder, SubsectionPlaceholder)
+		}
+		// Sections that implement encoding.TextUnmarshaler can also be
+		// set as a whole.
+		if len(path) == 1 && reflect.PtrTo(sf.Type).Implements(textUnmarshalerType) {
+			out = append(out, EnvVarSpec{
+				Name:  n.envVarName(prefix, path[0]),
+				Type:  sf.Type.String(),
+				Path:  path[0],
+				Roles: fieldRoles(sf),
+			})
+		}
+		// Variables for the fields of the section follow its name, and
+		// those of any subsections, e.g. "APPNAME_POOL_<name>_".
+		secPrefix := n.envVarName(prefix, append(path, "")...)
+		for _, ef := range n.envFields(secType) {
+			fieldPath := append(path[:len(path):len(path)], ef.groups...)
+			fieldPath = append(fieldPath, fieldSectionName(ef.sf))
+			name := secPrefix + ef.name
+			if pinned := pinnedEnvVar(ef.sf); pinned != "" && len(path) == 1 && len(ef.groups) == 0 {
+				name = pinned
+			}
+			out = append(out, EnvVarSpec{
+				Name:  name,
+				Type:  ef.sf.Type.String(),
+				Path:  strings.Join(fieldPath, "."),
+				Roles: fieldRoles(ef.sf),
+			})
+			if !isDSNType(ef.sf.Type) {
+				continue
+			}
+			// See applyDSNOverrides.
+			for _, c := range dsnComponents {
+				out = append(out, EnvVarSpec{
+					Name:  secPrefix + ef.name + Separator + n.name(c),
+					Type:  "string",
+					Path:  strings.Join(fieldPath, ".") + "." + c,
+					Roles: fieldRoles(ef.sf),
+				})
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"gopkg.in/check.v1"
+)
+
+type envVarsCommon struct {
+	Region string
+}
+
+func (s *Suite) TestEnvVarsFor(c *check.C) {
+	type pool struct {
+		Size  int
+		Hosts []string `gcfg:"host-list"`
+		size  int
+	}
+	type config struct {
+		Server struct {
+			envVarsCommon
+			Listen  string
+			Timeout *Duration
+			Limits  struct {
+				Conns int
+			}
+		}
+		Admin struct {
+			Token string `gcfgenv:"role=admin,ops"`
+			DB    *DSN   `gcfgenv:"role=admin"`
+		}
+		Upstream    endpointSection
+		Pool        map[string]*pool
+		Zone        map[string]map[string]*pool
+		RawSections map[string]map[string]string
+		Other       string
+		private     pool
+	}
+	specs, err := EnvVarsFor(&config{}, "APP")
+	c.Assert(err, check.IsNil)
+	c.Check(specs, check.DeepEquals, []EnvVarSpec{
+		{"APP_SERVER_LISTEN", "string", "Server.Listen", nil},
+		{"APP_SERVER_TIMEOUT", "*gcfgenv.Duration", "Server.Timeout", nil},
+		{"APP_SERVER_LIMITS_CONNS", "int", "Server.Limits.Conns", nil},
+		{"APP_SERVER_REGION", "string", "Server.Region", nil},
+		{"APP_ADMIN_TOKEN", "string", "Admin.Token", []string{"admin", "ops"}},
+		{"APP_ADMIN_DB", "*gcfgenv.DSN", "Admin.DB", []string{"admin"}},
+		{"APP_ADMIN_DB_USER", "string", "Admin.DB.User", []string{"admin"}},
+		{"APP_ADMIN_DB_PASSWORD", "string", "Admin.DB.Password", []string{"admin"}},
+		{"APP_ADMIN_DB_HOST", "string", "Admin.DB.Host", []string{"admin"}},
+		{"APP_ADMIN_DB_DATABASE", "string", "Admin.DB.Database", []string{"admin"}},
+		{"APP_UPSTREAM", "gcfgenv.endpointSection", "Upstream", nil},
+		{"APP_UPSTREAM_HOST", "string", "Upstream.Host", nil},
+		{"APP_UPSTREAM_PORT", "int", "Upstream.Port", nil},
+		{"APP_POOL_<name>_SIZE", "int", "Pool.<name>.Size", nil},
+		{"APP_POOL_<name>_HOST_LIST", "[]string", "Pool.<name>.host-list", nil},
+		{"APP_ZONE_<name>_<name>_SIZE", "int", "Zone.<name>.<name>.Size", nil},
+		{"APP_ZONE_<name>_<name>_HOST_LIST", "[]string", "Zone.<name>.<name>.host-list", nil},
+	})
+
+	specs, err = EnvVarsFor(&config{}, "app", WithLowercaseNames(), WithDashReplacement("-"))
+	c.Assert(err, check.IsNil)
+	c.Check(specs[14].Name, check.Equals, "app_pool_<name>_host-list")
+	c.Check(specs[7].Name, check.Equals, "app_admin_db_password")
+
+	_, err = EnvVarsFor(config{}, "APP")
+	c.Check(err, check.ErrorMatches, "config must be .*")
+}
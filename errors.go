@@ -0,0 +1,106 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// A ValueError reports an environment variable whose value could not be
+// converted to the type of its field (or transformed or checked).
+type ValueError struct {
+	// Name is the name of the environment variable.
+	Name string
+	// Path is the field being set, made up of field names (or their gcfg
+	// tags) and subsection names, e.g. "Server.main.Port".
+	Path string
+	// Value is the value of the environment variable. It is omitted from
+	// the error message for fields tagged gcfgenv:"secret".
+	Value string
+	// Secret is true for fields tagged gcfgenv:"secret".
+	Secret bool
+	// Err is the underlying error.
+	Err error
+}
+
+// redacted replaces the values of secret fields in error messages.
+const redacted = "<redacted>"
+
+func (e *ValueError) Error() string {
+	msg := e.Err.Error()
+	value := fmt.Sprintf("%q", e.Value)
+	if e.Secret {
+		if e.Value != "" {
+			msg = strings.ReplaceAll(msg, e.Value, redacted)
+		}
+		value = redacted
+	}
+	return fmt.Sprintf("cannot set %s from %s=%s: %s", e.Path, e.Name, value, msg)
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// Code returns CodeInvalidValue.
+func (e *ValueError) Code() Code { return CodeInvalidValue }
+
+// Errors is returned when more than one environment variable cannot be
+// applied, so that they can all be fixed at once rather than one deployment
+// at a time. errors.Is and errors.As match any of the errors in the list.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is reports whether any of the errors matches target.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, and if so, sets
+// target to it and returns true.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendError adds err, which may be nil or another Errors, to e.
+func (e Errors) appendError(err error) Errors {
+	if more, ok := err.(Errors); ok {
+		return append(e, more...)
+	}
+	if err != nil {
+		return append(e, err)
+	}
+	return e
+}
+
+// err returns nil if e is empty, its only error if it has one, and e
+// otherwise.
+func (e Errors) err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return e[0]
+	}
+	return e
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestConversionErrors(c *check.C) {
+	type sub struct {
+		Count int
+	}
+	type config struct {
+		Sec struct {
+			Port    int
+			Enabled bool
+			Name    string
+		}
+		Sub   map[string]*sub
+		Other struct{ Size uint8 }
+	}
+	var cfg config
+	cfg.Sub = map[string]*sub{"a": {}}
+	env := map[string]string{
+		"APP_SEC_PORT":    "x",
+		"APP_SEC_ENABLED": "maybe",
+		"APP_SEC_NAME":    "set",
+		"APP_SUB_a_COUNT": "1.5",
+		"APP_OTHER_SIZE":  "300",
+		"APP_SUB_b_COUNT": "y",
+	}
+	err := readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithErrorCodes())
+	c.Assert(err, check.FitsTypeOf, Errors{})
+	errs := err.(Errors)
+	c.Check(errs, check.HasLen, 5)
+	for _, e := range errs {
+		c.Check(e, check.ErrorMatches, "GCFGENV-0002: .*")
+	}
+	c.Check(strings.Count(err.Error(), "\n"), check.Equals, 4)
+	// Valid variables are still applied.
+	c.Check(cfg.Sec.Name, check.Equals, "set")
+}
+
+func (s *Suite) TestErrorsUnwrap(c *check.C) {
+	errA := errors.New("a")
+	pe := &PanicError{Value: "b"}
+	var errs Errors
+	errs = errs.appendError(nil)
+	c.Check(errs.err(), check.IsNil)
+	errs = errs.appendError(errA)
+	c.Check(errs.err(), check.Equals, errA)
+	errs = errs.appendError(Errors{pe})
+	c.Check(errs, check.HasLen, 2)
+	c.Check(errs, check.ErrorMatches, "a\npanic while reading configuration: b")
+
+	var err error = errs
+	c.Check(errors.Is(err, errA), check.Equals, true)
+	c.Check(errors.Is(err, ErrInvalidTarget), check.Equals, false)
+	var got *PanicError
+	c.Check(errors.As(err, &got), check.Equals, true)
+	c.Check(got, check.Equals, pe)
+	c.Check(ErrorCode(err), check.Equals, CodePanic)
+}
+
+func (s *Suite) TestValueErrors(c *check.C) {
+	type sub struct {
+		Count int
+		Token string `gcfgenv:"secret transform=strip-slash"`
+	}
+	type config struct {
+		Sec struct {
+			Password int `gcfgenv:"secret"`
+		}
+		Sub      map[string]*sub
+		Endpoint endpointSection
+	}
+	cases := []struct {
+		env  map[string]string
+		want string
+	}{
+		{
+			map[string]string{"APP_SEC_PASSWORD": "hunter2"},
+			`cannot set Sec\.Password from APP_SEC_PASSWORD=<redacted>: failed to parse "<redacted>" as int: expected integer`,
+		},
+		{
+			map[string]string{"APP_SUB_a_COUNT": "x"},
+			`cannot set Sub\.a\.Count from APP_SUB_a_COUNT="x": failed to parse "x" as int: .*`,
+		},
+		{
+			map[string]string{"APP_SUB_new_COUNT": "y"},
+			`cannot set Sub\.new\.Count from APP_SUB_new_COUNT="y": failed to parse "y" as int: .*`,
+		},
+		{
+			map[string]string{"APP_SUB_a_TOKEN": "/"},
+			`cannot set Sub\.a\.Token from APP_SUB_a_TOKEN=<redacted>: refusing to strip root path`,
+		},
+		{
+			map[string]string{"APP_ENDPOINT": "nope"},
+			`cannot set Endpoint from APP_ENDPOINT="nope": address nope: missing port in address`,
+		},
+	}
+	for _, tc := range cases {
+		cfg := config{Sub: map[string]*sub{"a": {}}}
+		err := readWithMapInto(strings.NewReader(""), tc.env, "APP", &cfg)
+		c.Check(err, check.ErrorMatches, tc.want)
+		var ve *ValueError
+		c.Assert(errors.As(err, &ve), check.Equals, true)
+		c.Check(ErrorCode(err), check.Equals, CodeInvalidValue)
+		c.Check(ve.Err, check.NotNil)
+	}
+}
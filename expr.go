@@ -0,0 +1,114 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Duration is a time.Duration that can be given as a simple expression in
+// the file or environment, such as "2*24h", "1h30m", or "90*1s + 500ms".
+// Expressions may use * and +, with the usual precedence; at most one operand of each product may have a unit,
+// and the result must have one (unless it is zero).
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, unit, err := evalExpr(string(text), parseDurationOperand)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %v", text, err)
+	}
+	if !unit && v != 0 {
+		return fmt.Errorf("invalid duration %q: missing unit", text)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func parseDurationOperand(s string) (int64, bool, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, false, nil
+	}
+	d, err := time.ParseDuration(s)
+	return int64(d), true, err
+}
+
+// A Size is a count, such as a number of bytes, that can be given as a simple
+// expression in the file or environment, such as "512*1024". Expressions may
+// use * and +, with the usual precedence.
+type Size int64
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Size) UnmarshalText(text []byte) error {
+	v, _, err := evalExpr(string(text), func(s string) (int64, bool, error) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid number %q", s)
+		}
+		return n, false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("invalid size %q: %v", text, err)
+	}
+	*s = Size(v)
+	return nil
+}
+
+// evalExpr evaluates a sum of products of operands, which are parsed by
+// parse. It also reports whether the result has a unit.
+func evalExpr(s string, parse func(string) (int64, bool, error)) (int64, bool, error) {
+	s = strings.Join(strings.Fields(s), "")
+	if s == "" {
+		return 0, false, fmt.Errorf("empty expression")
+	}
+	var sum int64
+	sumUnit := false
+	for i, term := range strings.Split(s, "+") {
+		product, unit := int64(1), false
+		for _, operand := range strings.Split(term, "*") {
+			if operand == "" {
+				return 0, false, fmt.Errorf("missing operand")
+			}
+			v, u, err := parse(operand)
+			if err != nil {
+				return 0, false, err
+			}
+			if u && unit {
+				return 0, false, fmt.Errorf("cannot multiply two values with units")
+			}
+			unit = unit || u
+			if mulOverflows(product, v) {
+				return 0, false, fmt.Errorf("value out of range")
+			}
+			product *= v
+		}
+		if i > 0 && unit != sumUnit {
+			return 0, false, fmt.Errorf("cannot add values with and without units")
+		}
+		sumUnit = unit
+		if (product > 0 && sum > 1<<63-1-product) || (product < 0 && sum < -1<<63-product) {
+			return 0, false, fmt.Errorf("value out of range")
+		}
+		sum += product
+	}
+	return sum, sumUnit, nil
+}
+
+// mulOverflows reports whether a*b does not fit in an int64. Dividing the
+// product by b does not catch math.MinInt64 * -1, since the division wraps
+// as well.
+func mulOverflows(a, b int64) bool {
+	if a == math.MinInt64 && b == -1 || a == -1 && b == math.MinInt64 {
+		return true
+	}
+	return b != 0 && a*b/b != a
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestDurationExpressions(c *check.C) {
+	cases := []struct {
+		in  string
+		out time.Duration
+		err string
+	}{
+		{in: "1h30m", out: 90 * time.Minute},
+		{in: "2*24h", out: 48 * time.Hour},
+		{in: "24h * 2", out: 48 * time.Hour},
+		{in: "90*1s + 500ms", out: 90*time.Second + 500*time.Millisecond},
+		{in: "0", out: 0},
+		{in: "30", err: `invalid duration "30": missing unit`},
+		{in: "2h*3h", err: `.*cannot multiply two values with units`},
+		{in: "1h+1", err: `.*cannot add values with and without units`},
+		{in: "2**1h", err: `.*missing operand`},
+		{in: "", err: `.*empty expression`},
+		{in: "9223372036*10s", err: `.*value out of range`},
+	}
+	for _, tc := range cases {
+		var d Duration
+		err := d.UnmarshalText([]byte(tc.in))
+		if tc.err != "" {
+			c.Check(err, check.ErrorMatches, tc.err, check.Commentf("%q", tc.in))
+			continue
+		}
+		c.Check(err, check.IsNil, check.Commentf("%q", tc.in))
+		c.Check(time.Duration(d), check.Equals, tc.out, check.Commentf("%q", tc.in))
+	}
+}
+
+func (s *Suite) TestSizeExpressions(c *check.C) {
+	var size Size
+	c.Check(size.UnmarshalText([]byte("512*1024")), check.IsNil)
+	c.Check(size, check.Equals, Size(512*1024))
+	c.Check(size.UnmarshalText([]byte("1024 + 2*512")), check.IsNil)
+	c.Check(size, check.Equals, Size(2048))
+	c.Check(size.UnmarshalText([]byte("1m")), check.ErrorMatches,
+		`invalid size "1m": invalid number "1m"`)
+	for _, in := range []string{"-9223372036854775808*-1", "-1*-9223372036854775808", "4294967296*4294967296"} {
+		c.Check(size.UnmarshalText([]byte(in)), check.ErrorMatches, `.*value out of range`, check.Commentf("%q", in))
+	}
+	c.Check(size.UnmarshalText([]byte("-9223372036854775808*1")), check.IsNil)
+	c.Check(size, check.Equals, Size(math.MinInt64))
+
+	type config struct {
+		Cache struct {
+			TTL      Duration `gcfg:"ttl"`
+			MaxBytes Size     `gcfg:"max-bytes"`
+			Retries  []Duration
+		}
+	}
+	cfg := config{}
+	r := strings.NewReader("[cache]\nttl = 2*24h\nmax-bytes = 64*1024*1024\n")
+	err := readWithMapInto(r, map[string]string{
+		"APPNAME_CACHE_RETRIES": "1s,2*1s,4s",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(time.Duration(cfg.Cache.TTL), check.Equals, 48*time.Hour)
+	c.Check(cfg.Cache.MaxBytes, check.Equals, Size(64<<20))
+	c.Check(cfg.Cache.Retries, check.DeepEquals, []Duration{
+		Duration(time.Second), Duration(2 * time.Second), Duration(4 * time.Second),
+	})
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/gcfg.v1/scanner"
+	"gopkg.in/gcfg.v1/token"
+)
+
+// rewriteFileValues replaces the values in src of the variables of the config
+// struct type refType whose fields match, with the result of rewrite, before
+// gcfg reads them. Each replaced value takes up the same number of lines, so
+// that positions in any errors reported by gcfg still refer to the original
+// input.
+func rewriteFileValues(refType reflect.Type, src []byte, match func(sf reflect.StructField) bool, rewrite func(sf reflect.StructField, val string) (string, error)) ([]byte, error) {
+	if !anySectionField(refType, match) {
+		return src, nil
+	}
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	var buf []byte
+	last := 0
+	var secType reflect.Type
+	var secPath []string
+	pos, tok, lit := s.Scan()
+	for tok != token.EOF {
+		switch tok {
+		case token.LBRACK:
+			_, tok, lit = s.Scan()
+			secType = nil
+			if tok != token.IDENT {
+				continue
+			}
+			i := sectionFieldIndex(refType, lit)
+			if i < 0 {
+				break
+			}
+			secType = sectionStructType(refType.Field(i).Type)
+			secPath = []string{refType.Field(i).Name}
+			_, tok, lit = s.Scan()
+			if tok == token.STRING {
+				secPath = append(secPath, unquote(lit))
+			} else if tok != token.RBRACK {
+				continue
+			}
+		case token.IDENT:
+			if secType == nil {
+				break
+			}
+			sf, found := fileField(secType, lit)
+			for tok != token.EOL && tok != token.EOF && tok != token.STRING {
+				pos, tok, lit = s.Scan()
+			}
+			if !found || tok != token.STRING || !match(sf) {
+				continue
+			}
+			val := unquote(lit)
+			path := strings.Join(append(secPath[:len(secPath):len(secPath)], sf.Name), ".")
+			out, err := rewriteValue(rewrite, sf, val, path)
+			if err != nil {
+				return nil, err
+			}
+			if out == val {
+				continue
+			}
+			off := file.Offset(pos)
+			buf = append(buf, src[last:off]...)
+			buf = append(buf, quoteGcfgValue(out)...)
+			buf = append(buf, strings.Repeat("\n", strings.Count(lit, "\n"))...)
+			last = off + len(lit)
+		}
+		pos, tok, lit = s.Scan()
+	}
+	if buf == nil {
+		return src, nil
+	}
+	return append(buf, src[last:]...), nil
+}
+
+// rewriteValue calls rewrite for the field sf, reporting any panic as a
+// *PanicError for path.
+func rewriteValue(rewrite func(sf reflect.StructField, val string) (string, error), sf reflect.StructField, val, path string) (out string, err error) {
+	defer recoverPanic(&err, path)
+	return rewrite(sf, val)
+}
+
+// fileField returns the field of the section struct type t that the variable
+// name sets in the file. As with gcfg, fields promoted from embedded structs
+// are set by the same names as those of the section itself, which take
+// precedence. Fields in groups cannot be set from the file.
+func fileField(t reflect.Type, name string) (reflect.StructField, bool) {
+	if j := sectionFieldIndex(t, name); j >= 0 && !isEmbedded(t.Field(j)) {
+		return t.Field(j), true
+	}
+	for j := 0; j < t.NumField(); j++ {
+		if sf := t.Field(j); isEmbedded(sf) {
+			if f, found := fileField(sf.Type, name); found {
+				return f, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// anySectionField returns true if match is true for any field of a section of
+// the config struct type refType, including promoted fields.
+func anySectionField(refType reflect.Type, match func(sf reflect.StructField) bool) bool {
+	for i := 0; i < refType.NumField(); i++ {
+		secType := sectionStructType(refType.Field(i).Type)
+		if secType != nil && anyField(secType, match) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyField returns true if match is true for any field of the struct type t,
+// or of the structs embedded in it.
+func anyField(t reflect.Type, match func(sf reflect.StructField) bool) bool {
+	for j := 0; j < t.NumField(); j++ {
+		sf := t.Field(j)
+		if isEmbedded(sf) && anyField(sf.Type, match) || match(sf) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteEntries applies f to val, which is to be converted to the field sf,
+// or to each of its entries, separated by delim, if sf is a slice.
+func rewriteEntries(sf reflect.StructField, val, delim string, f func(string) string) string {
+	if sf.Type.Kind() != reflect.Slice {
+		return f(val)
+	}
+	entries := splitEntries(val, delim)
+	for i := range entries {
+		entries[i] = f(entries[i])
+	}
+	return strings.Join(entries, delim)
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+// fileVarSuffix marks environment variables that name a file holding the
+// value, e.g. "APPNAME_DB_PASSWORD_FILE=/run/secrets/db-password".
+const fileVarSuffix = Separator + "FILE"
+
+// WithFileVars follows the Docker and Kubernetes convention for secrets: an
+// environment variable such as APPNAME_DB_PASSWORD_FILE=/run/secrets/pw sets
+// the field that APPNAME_DB_PASSWORD would, to the contents of the named file
+// (without any trailing newlines). This keeps secrets out of the process's
+// environment. It is an error to set both variables.
+//
+// A variable ending in _FILE is only taken as a reference to a file if the
+// name without the suffix would set a field of the config struct, and the
+// name itself would not. So fields whose own names end in "-file" or "_file",
+// such as tlsconf's cert-file, are still set directly. With an empty prefix,
+// no variables are taken as references, since any variable in the
+// environment (such as SSL_CERT_FILE) could match.
+func WithFileVars() Option {
+	return func(o *options) {
+		o.fileVars = true
+	}
+}
+
+// ReadFileVars returns a copy of env in which each variable with envPrefix
+// that ends in _FILE has been replaced by the variable without the suffix,
+// set to the contents of the named file, as WithFileVars does for the config
+// struct that config points to. Daemons that drop privileges (see
+// ParseConfigFile) can use it to read secrets that only root can read
+// beforehand, then pass the result to Parsed.ApplyEnv.
+func ReadFileVars(env map[string]string, envPrefix string, config interface{}, opts ...Option) (map[string]string, error) {
+	ref, err := targetStruct(config)
+	if err != nil {
+		return nil, err
+	}
+	ref, err = flattenEmbedded(ref)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	prefix, err := o.resolvePrefix(envPrefix, env)
+	if err != nil {
+		return nil, err
+	}
+	return readFileVars(ref.Type(), env, prefix, o.naming)
+}
+
+// readFileVars does the work of ReadFileVars once the prefix is resolved. env
+// is returned as-is if it has no file variables.
+func readFileVars(t reflect.Type, env map[string]string, prefix string, n naming) (map[string]string, error) {
+	if prefix == "" {
+		return env, nil
+	}
+	suffix := n.name(fileVarSuffix)
+	var out map[string]string
+	for k, path := range env {
+		if !strings.HasPrefix(k, prefix) || !strings.HasSuffix(k, suffix) || len(k) == len(prefix)+len(suffix) {
+			continue
+		}
+		name := strings.TrimSuffix(k, suffix)
+		if !setsField(t, prefix, n, name) || setsField(t, prefix, n, k) {
+			continue
+		}
+		if _, ok := env[name]; ok {
+			return nil, fmt.Errorf("both %s and %s are set", name, k)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s from %s: %w", name, k, err)
+		}
+		if out == nil {
+			out = make(map[string]string, len(env))
+			for k, v := range env {
+				out[k] = v
+			}
+		}
+		delete(out, k)
+		out[name] = strings.TrimRight(string(b), "\r\n")
+	}
+	if out == nil {
+		return env, nil
+	}
+	return out, nil
+}
+
+// readFileVars replaces file variables in env for the config struct type t, if
+// WithFileVars was given.
+func (o *options) readFileVars(t reflect.Type, env map[string]string, prefix string) (map[string]string, error) {
+	if !o.fileVars {
+		return env, nil
+	}
+	return readFileVars(t, env, prefix, o.naming)
+}
+
+// setsField reports whether the environment variable name would set a field
+// of the config struct type t: a field of a plain section, a field of any
+// subsection of a section with subsections, a key of a map[string]string
+// section, or a component of a DSN field.
+func setsField(t reflect.Type, prefix string, n naming, name string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Name == rawSectionsField || n.isShadowed(t, i) || envDisabled(sf) {
+			continue
+		}
+		secPrefix := prefix + n.field(sf) + Separator
+		if isStringMapSection(sf.Type) {
+			if strings.HasPrefix(name, secPrefix) && len(name) > len(secPrefix) {
+				return true
+			}
+			continue
+		}
+		secType := sectionStructType(sf.Type)
+		if secType == nil {
+			continue
+		}
+		plain := sf.Type.Kind() == reflect.Struct
+		if plain && name+Separator == secPrefix && reflect.PtrTo(secType).Implements(textUnmarshalerType) {
+			return true
+		}
+		for _, ef := range n.envFields(secType) {
+			if plain && len(ef.groups) == 0 && name == pinnedEnvVar(ef.sf) {
+				return true
+			}
+			for _, tail := range fieldVarTails(n, ef) {
+				if plain && name == secPrefix+tail {
+					return true
+				}
+				// Subsection names may contain anything, but not be empty.
+				if !plain && len(name) > len(secPrefix)+len(tail)+1 &&
+					strings.HasPrefix(name, secPrefix) && strings.HasSuffix(name, Separator+tail) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// fieldVarTails returns the endings of the variables that set the field ef:
+// its own name, and for DSN fields, the names of the components.
+func fieldVarTails(n naming, ef envField) []string {
+	tails := []string{ef.name}
+	if isDSNType(ef.sf.Type) {
+		for _, c := range dsnComponents {
+			tails = append(tails, ef.name+Separator+n.name(c))
+		}
+	}
+	return tails
+}
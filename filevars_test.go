@@ -0,0 +1,119 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestFileVars(c *check.C) {
+	type config struct {
+		DB struct {
+			User     string
+			Password string
+			Hosts    []string
+		}
+		Backend map[string]*struct {
+			Token string
+		}
+	}
+	dir := c.MkDir()
+	write := func(name, contents string) string {
+		filename := filepath.Join(dir, name)
+		c.Assert(ioutil.WriteFile(filename, []byte(contents), 0o600), check.IsNil)
+		return filename
+	}
+	env := map[string]string{
+		"APPNAME_DB_USER":               "app",
+		"APPNAME_DB_PASSWORD_FILE":      write("pw", "s3cret\n"),
+		"APPNAME_DB_HOSTS_FILE":         write("hosts", "a,b\r\n\r\n"),
+		"APPNAME_BACKEND_b1_TOKEN_FILE": write("token", "t0ken"),
+		"OTHER_PASSWORD_FILE":           "/nonexistent",
+	}
+
+	var err error
+	cfg := config{}
+	// The file variables do not count as unknown in strict mode.
+	err = readWithMapInto(strings.NewReader("[backend \"b1\"]\n"), env, "APPNAME", &cfg, WithFileVars(), WithStrict())
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.DB.User, check.Equals, "app")
+	c.Check(cfg.DB.Password, check.Equals, "s3cret")
+	c.Check(cfg.DB.Hosts, check.DeepEquals, []string{"a", "b"})
+	c.Assert(cfg.Backend["b1"], check.NotNil)
+	c.Check(cfg.Backend["b1"].Token, check.Equals, "t0ken")
+
+	// Without the option, the variables are left alone.
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), env, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.DB.Password, check.Equals, "")
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{
+		"APPNAME_DB_PASSWORD":      "s3cret",
+		"APPNAME_DB_PASSWORD_FILE": env["APPNAME_DB_PASSWORD_FILE"],
+	}, "APPNAME", &cfg, WithFileVars())
+	c.Check(err, check.ErrorMatches, `both APPNAME_DB_PASSWORD and APPNAME_DB_PASSWORD_FILE are set`)
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{
+		"APPNAME_DB_PASSWORD_FILE": filepath.Join(dir, "missing"),
+	}, "APPNAME", &cfg, WithFileVars())
+	c.Check(err, check.ErrorMatches, `cannot read APPNAME_DB_PASSWORD from APPNAME_DB_PASSWORD_FILE: .*`)
+	c.Check(errors.Is(err, os.ErrNotExist), check.Equals, true)
+
+	// Files can be read ahead of time, e.g. before dropping privileges.
+	resolved, err := ReadFileVars(map[string]string{
+		"appname_db_password_file": env["APPNAME_DB_PASSWORD_FILE"],
+	}, "APPNAME", &config{}, WithLowercaseNames())
+	c.Assert(err, check.IsNil)
+	c.Check(resolved, check.DeepEquals, map[string]string{"appname_db_password": "s3cret"})
+}
+
+func (s *Suite) TestFileVarsOnlyForFields(c *check.C) {
+	type config struct {
+		TLS struct {
+			CertFile string `gcfg:"cert-file"`
+			Key      string
+		}
+		DB struct {
+			URL *DSN
+		}
+	}
+	dir := c.MkDir()
+	key := filepath.Join(dir, "key")
+	c.Assert(ioutil.WriteFile(key, []byte("k3y\n"), 0o600), check.IsNil)
+
+	// Fields whose own names end in _FILE are set directly, and variables
+	// that would not set a field without the suffix are left alone, even
+	// if they name files that do not exist.
+	var cfg config
+	err := readWithMapInto(strings.NewReader(""), map[string]string{
+		"APPNAME_TLS_CERT_FILE":        "/etc/app/cert.pem",
+		"APPNAME_TLS_KEY_FILE":         key,
+		"APPNAME_TLS_OTHER_FILE":       "/nonexistent",
+		"APPNAME_DB_URL":               "postgres://app@db/app",
+		"APPNAME_DB_URL_PASSWORD_FILE": key,
+	}, "APPNAME", &cfg, WithFileVars())
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.TLS.CertFile, check.Equals, "/etc/app/cert.pem")
+	c.Check(cfg.TLS.Key, check.Equals, "k3y")
+	c.Assert(cfg.DB.URL, check.NotNil)
+	c.Check(cfg.DB.URL.Password, check.Equals, "k3y")
+
+	// With an empty prefix, no variables are taken as references to files.
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{
+		"SSL_CERT_FILE": "/nonexistent",
+		"TLS_KEY_FILE":  key,
+	}, "", &cfg, WithFileVars())
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.TLS.Key, check.Equals, "")
+}
@@ -0,0 +1,22 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import "io/fs"
+
+// ReadFSFileWithEnvInto is like ReadFileWithEnvInto, but reads the file name
+// from fsys, e.g. a default configuration embedded in the program with
+// embed.FS, or a testing/fstest.MapFS. Files for WithEnvironmentFiles are
+// also read from fsys, and WithMmap has no effect.
+func ReadFSFileWithEnvInto(fsys fs.FS, name string, envPrefix string, config interface{}, opts ...Option) error {
+	opts = append(opts[:len(opts):len(opts)], withFS(fsys))
+	return ReadFileWithEnvInto(name, envPrefix, config, opts...)
+}
+
+// withFS makes openConfigFile read files from fsys.
+func withFS(fsys fs.FS) Option {
+	return func(o *options) {
+		o.fsys = fsys
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"os"
+	"testing/fstest"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestReadFSFile(c *check.C) {
+	type config struct {
+		Server struct {
+			Listen string
+			Debug  bool
+		}
+	}
+	fsys := fstest.MapFS{
+		"conf/app.gcfg":         {Data: []byte("\ufeff[server]\nlisten = :80\n")},
+		"conf/app.staging.gcfg": {Data: []byte("[server]\ndebug = true\n")},
+	}
+	os.Setenv("GCFGENVFS_SERVER_LISTEN", ":81")
+	defer os.Unsetenv("GCFGENVFS_SERVER_LISTEN")
+
+	cfg := config{}
+	err := ReadFSFileWithEnvInto(fsys, "conf/app.gcfg", "GCFGENVFS", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Listen, check.Equals, ":81")
+	c.Check(cfg.Server.Debug, check.Equals, false)
+
+	// Environment files are read from the same file system.
+	os.Setenv("GCFGENVFS_ENV", "staging")
+	defer os.Unsetenv("GCFGENVFS_ENV")
+	cfg = config{}
+	err = ReadFSFileWithEnvInto(fsys, "conf/app.gcfg", "GCFGENVFS", &cfg, WithEnvironmentFiles("GCFGENVFS_ENV"))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Debug, check.Equals, true)
+
+	err = ReadFSFileWithEnvInto(fsys, "conf/missing.gcfg", "GCFGENVFS", &config{})
+	c.Check(os.IsNotExist(err), check.Equals, true)
+}
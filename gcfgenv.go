@@ -14,6 +14,8 @@ import (
 	"os"
 	"reflect"
 	"strings"
+	"sync"
+	"time"
 
 	"gopkg.in/gcfg.v1"
 	"gopkg.in/gcfg.v1/types"
@@ -21,42 +23,113 @@ import (
 
 // ReadFileWithEnvInto reads the gcfg-formatted file at filename, injects any
 // overrides from the process's environment variables (prefixed with envPrefix),
-// and sets these values in the corresponding fields of config.
-func ReadFileWithEnvInto(filename string, envPrefix string, config interface{}) error {
-	f, err := os.Open(filename)
+// and sets these values in the corresponding fields of config. If filename is
+// "-", the file is read from the standard input instead, e.g. for
+// "render-template | app --check-config -".
+func ReadFileWithEnvInto(filename string, envPrefix string, config interface{}, opts ...Option) (err error) {
+	o := newOptions(opts)
+	if o.envFilesVar != "" && (filename != "-" || o.fsys != nil) {
+		defer showErrorCodes(&err, opts)
+		env := mapFromEnviron(os.Environ())
+		src, err := readLayeredFiles(filename, env[o.envFilesVar], o)
+		if err != nil {
+			return err
+		}
+		return readWithMapInto(bytes.NewReader(src), env, envPrefix, config, opts...)
+	}
+	f, closeFile, err := openConfigFile(filename, o)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	maybeSkipBOM(f)
-	return ReadWithEnvInto(f, envPrefix, config)
+	defer closeFile()
+	return ReadWithEnvInto(f, envPrefix, config, opts...)
+}
+
+// ReadFilesWithEnvInto is like ReadFileWithEnvInto, but reads each of the
+// files in filenames in order, e.g. a base configuration shipped with a
+// product followed by a customer's overrides. Later files override the values
+// of earlier ones (and add to multi-valued variables), as if they were
+// appended to the first, and environment variables are applied last. Each
+// file's syntax is checked separately, so that errors name the file they are
+// in. Every file must exist.
+func ReadFilesWithEnvInto(filenames []string, envPrefix string, config interface{}, opts ...Option) (err error) {
+	defer showErrorCodes(&err, opts)
+	src, err := readFiles(filenames, false, newOptions(opts))
+	if err != nil {
+		return err
+	}
+	return ReadWithEnvInto(bytes.NewReader(src), envPrefix, config, opts...)
 }
 
 // ReadWithEnvInto reads gcfg-formatted data from r, injects any overrides from
 // the process's environment variables (prefixed with envPrefix), and sets these
 // values in the corresponding fields of config.
-func ReadWithEnvInto(r io.Reader, envPrefix string, config interface{}) error {
+func ReadWithEnvInto(r io.Reader, envPrefix string, config interface{}, opts ...Option) error {
 	env := mapFromEnviron(os.Environ())
-	return readWithMapInto(r, env, envPrefix, config)
+	return readWithMapInto(r, env, envPrefix, config, opts...)
 }
 
-var utf8BOM = []byte("\ufeff")
+// ReadStringWithEnvInto is like ReadWithEnvInto, but reads the configuration
+// from str, as gcfg.ReadStringInto does, e.g. for tests and small programs.
+func ReadStringWithEnvInto(str string, envPrefix string, config interface{}, opts ...Option) error {
+	return ReadWithEnvInto(strings.NewReader(str), envPrefix, config, opts...)
+}
 
-func maybeSkipBOM(r io.ReadSeeker) {
-	b := make([]byte, len(utf8BOM))
-	read, err := r.Read(b)
-	if err == nil && read == len(utf8BOM) {
-		// If we don't find a BOM, we need to seek back over the bytes
-		// we've read.
-		if !bytes.Equal(b, utf8BOM) {
-			// We can ignore errors here, they will resurface later
-			// when reading.
-			r.Seek(int64(-read), 1)
-		}
-		return
+// ReadBytesWithEnvInto is like ReadStringWithEnvInto, but reads the
+// configuration from b.
+func ReadBytesWithEnvInto(b []byte, envPrefix string, config interface{}, opts ...Option) error {
+	return ReadWithEnvInto(bytes.NewReader(b), envPrefix, config, opts...)
+}
+
+// ReadWithEnvMapInto is like ReadWithEnvInto, but takes overrides from env
+// (keyed by variable name) instead of the process's environment, e.g. for
+// tests, or for programs that receive their environment from a job scheduler.
+// The map is not modified.
+func ReadWithEnvMapInto(r io.Reader, env map[string]string, envPrefix string, config interface{}, opts ...Option) error {
+	return readWithMapInto(r, env, envPrefix, config, opts...)
+}
+
+// ReapplyEnvSection re-applies overrides from the process's environment
+// variables (prefixed with envPrefix) to a single section of an existing
+// config, leaving all other sections untouched. This is useful for refreshing
+// e.g. credentials at runtime. The section name is given as it would appear
+// in the configuration file.
+//
+// Note that slice fields are appended to as usual, so re-applying a section
+// repeatedly will accumulate values in them.
+func ReapplyEnvSection(config interface{}, section string, envPrefix string, opts ...Option) error {
+	env := mapFromEnviron(os.Environ())
+	return reapplyEnvSectionWithMap(config, section, env, envPrefix, opts...)
+}
+
+func reapplyEnvSectionWithMap(config interface{}, section string, env map[string]string, prefix string, opts ...Option) (err error) {
+	defer showErrorCodes(&err, opts)
+	defer recoverPanic(&err, "")
+	ref, err := targetStruct(config)
+	if err != nil {
+		return err
+	}
+	if hasEmbeddedConfig(ref.Type()) {
+		return readComposed(ref, func(config interface{}) error {
+			return reapplyEnvSectionWithMap(config, section, env, prefix, opts...)
+		})
+	}
+	i := sectionFieldIndex(ref.Type(), section)
+	if i < 0 {
+		return fmt.Errorf("no such section: %q", section)
+	}
+	o := newOptions(opts)
+	prefix, err = o.resolvePrefix(prefix, env)
+	if err != nil {
+		return err
+	}
+	env, err = o.readFileVars(ref.Type(), env, prefix)
+	if err != nil {
+		return err
 	}
-	// We can ignore errors here, they will resurface later while reading.
-	return
+	env = withoutReserved(env, o.reservedPrefixes)
+	st := newApplyState(o, prefix, env)
+	return setSectionWithEnvMap(st, ref, i, prefix, st.index)
 }
 
 func mapFromEnviron(environ []string) map[string]string {
@@ -68,179 +141,662 @@ func mapFromEnviron(environ []string) map[string]string {
 	return out
 }
 
-func readWithMapInto(r io.Reader, env map[string]string, prefix string, config interface{}) error {
+// withoutReserved returns a copy of env without any variables that begin with
+// one of the reserved prefixes.
+func withoutReserved(env map[string]string, reserved []string) map[string]string {
+	if len(reserved) == 0 {
+		return env
+	}
+	out := make(map[string]string, len(env))
+outer:
+	for k, v := range env {
+		for _, p := range reserved {
+			if strings.HasPrefix(k, p) {
+				continue outer
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func readWithMapInto(r io.Reader, env map[string]string, prefix string, config interface{}, opts ...Option) (err error) {
+	defer showErrorCodes(&err, opts)
+	defer recoverPanic(&err, "")
+	ref, err := targetStruct(config)
+	if err != nil {
+		return err
+	}
+	if hasEmbeddedConfig(ref.Type()) {
+		return readComposed(ref, func(config interface{}) error {
+			return readWithMapInto(r, env, prefix, config, opts...)
+		})
+	}
+	o := newOptions(opts)
+	prefix, err = o.resolvePrefix(prefix, env)
+	if err != nil {
+		return err
+	}
+	env, err = o.readFileVars(ref.Type(), env, prefix)
+	if err != nil {
+		return err
+	}
+	src, err := readAll(r)
+	if err != nil {
+		return err
+	}
+	if o.cache != nil {
+		return o.cache.read(ref, src, env, prefix, o.report, func() error {
+			return readSrcInto(ref, config, src, env, prefix, o)
+		})
+	}
+	return readSrcInto(ref, config, src, env, prefix, o)
+}
+
+// readSrcInto does the work of readWithMapInto once src has been read.
+func readSrcInto(ref reflect.Value, config interface{}, src []byte, env map[string]string, prefix string, o *options) (err error) {
+	var fileWarns []error
+	src, encodingWarn := skipBOM(src)
+	src, err = o.checkFileUTF8(src, encodingWarn)
+	if err != nil {
+		return err
+	}
+	if o.strict && encodingWarn != nil {
+		fileWarns = append(fileWarns, encodingWarn)
+	}
+	if o.iniCompat {
+		src = iniCompat(src)
+	}
+	src, err = o.expandValues(src, env)
+	if err != nil {
+		return err
+	}
+	if o.versionKey != "" {
+		src, err = migrate(src, o.versionKey, o.migrations)
+		if err != nil {
+			return err
+		}
+	}
+	target := config
+	renames := collectRenames(ref.Type())
+	src, deprecated := renameInFile(src, renames)
+	src, err = o.localFileTimes(ref.Type(), src)
+	if err != nil {
+		return err
+	}
+	src, err = filePercents(ref.Type(), src)
+	if err != nil {
+		return err
+	}
+	env = withoutReserved(env, o.reservedPrefixes)
+	src, err = transformFileValues(ref.Type(), src, env)
+	if err != nil {
+		return err
+	}
+	streamIndex := -1
+	var streamBlocks []streamBlock
+	if o.streamFunc != nil {
+		streamIndex, err = streamedSectionIndex(ref.Type(), o.streamSection)
+		if err != nil {
+			return err
+		}
+		src, streamBlocks, err = splitStreamedSection(src, ref.Type().Field(streamIndex))
+		if err != nil {
+			return err
+		}
+	}
+	shadow, restore := shadowNested(ref)
+	if shadow.IsValid() {
+		target = shadow.Addr().Interface()
+	}
 	var upstreamErr error
-	upstreamErr = gcfg.ReadInto(config, r)
+	upstreamErr = gcfg.ReadInto(target, bytes.NewReader(src))
+	upstreamErr = structureWarnings(upstreamErr, src)
+	upstreamErr = appendWarnings(upstreamErr, fileWarns)
+	if restore != nil {
+		restore()
+	}
 	if gcfg.FatalOnly(upstreamErr) != nil {
-		return upstreamErr
+		return fileError(upstreamErr)
 	}
-	if prefix != "" && !strings.HasSuffix(prefix, "_") {
-		prefix = prefix + "_"
+	env, envDeprecated := renameInEnv(env, prefix, o.naming, renames)
+	deprecated = append(deprecated, envDeprecated...)
+	err = o.checkFileBools(ref.Type(), src)
+	if err != nil {
+		return err
 	}
-	// We can assert that config is a pointer to a struct at this point.
-	ref := reflect.ValueOf(config).Elem()
-	err := setGcfgWithEnvMap(ref, prefix, env)
-	if err == nil {
+	st := newApplyState(o, prefix, env)
+	upstreamErr = fillRawSections(st, ref, src, prefix, env, upstreamErr)
+	upstreamErr = fillStringMaps(ref, src, upstreamErr)
+	if gcfg.FatalOnly(upstreamErr) != nil {
 		return upstreamErr
 	}
-	return err
+	err = st.applyEnv(ref)
+	if err != nil {
+		return err
+	}
+	fillSubsectionOrder(ref, src)
+	err = st.finish(ref)
+	if err != nil {
+		return err
+	}
+	err = st.fillReport(ref, src)
+	if err != nil {
+		return err
+	}
+	if streamIndex >= 0 {
+		upstreamErr = st.streamSection(ref, streamIndex, streamBlocks, prefix, env, upstreamErr)
+		if gcfg.FatalOnly(upstreamErr) != nil {
+			return upstreamErr
+		}
+	}
+	upstreamErr = appendWarnings(upstreamErr, deprecated)
+	if o.strict {
+		upstreamErr = appendWarnings(upstreamErr, st.warnings(ref, prefix))
+	}
+	return upstreamErr
 }
 
-func fieldToEnvVar(field reflect.StructField) string {
+// applyEnv applies the environment overrides to the config struct ref, once
+// any file has been read into it.
+func (st *applyState) applyEnv(ref reflect.Value) error {
+	err := setGcfgWithEnvMap(st, ref, st.prefix, st.index)
+	if err != nil {
+		return err
+	}
+	return st.applyDSNOverrides(ref, st.prefix, st.env)
+}
+
+// finish fills in generated and default values, then validates the result.
+func (st *applyState) finish(ref reflect.Value) error {
+	err := st.opts.generateValues(ref)
+	if err != nil {
+		return err
+	}
+	err = fillDefaultPorts(ref)
+	if err != nil {
+		return err
+	}
+	err = fillDecimalScales(ref)
+	if err != nil {
+		return err
+	}
+	err = fillSameAs(ref, ref)
+	if err != nil {
+		return err
+	}
+	return st.validate(ref)
+}
+
+// EnvVarName returns the name of the environment variable that overrides the
+// variable at fieldPath, using the same rules as ReadWithEnvInto. The path
+// uses names as they appear in the configuration file: a section name, an
+// optional subsection name, and a variable name. For example,
+//
+//	EnvVarName("APPNAME", "sec", "k1", "other-field")
+//
+// returns "APPNAME_SEC_k1_OTHER_FIELD".
+func EnvVarName(prefix string, fieldPath ...string) string {
+	return defaultNaming.envVarName(prefix, fieldPath...)
+}
+
+func normalizePrefix(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, Separator) {
+		prefix = prefix + Separator
+	}
+	return prefix
+}
+
+// envName returns the default environment variable form of a section or
+// variable name, which is also used to compare names from the file.
+func envName(name string) string {
+	return defaultNaming.name(name)
+}
+
+// gcfgTagName returns the name given in the field's gcfg tag, if any. gcfg
+// tags may carry options after the name, e.g. "name,int=dh".
+func gcfgTagName(field reflect.StructField) string {
 	t := field.Tag.Get("gcfg")
-	if t != "" {
-		// we need to replace dashes with underscores for consistency
-		// with field.Name, which uses this convention automatically
-		return strings.ToUpper(strings.ReplaceAll(t, "-", "_"))
+	if i := strings.IndexByte(t, ','); i >= 0 {
+		t = t[:i]
 	}
-	return strings.ToUpper(field.Name)
+	return t
 }
 
-func setGcfgWithEnvMap(ref reflect.Value, prefix string, env map[string]string) error {
-	refType := ref.Type()
-	for i := 0; i < refType.NumField(); i++ {
-		sec := ref.Field(i)
-		secStructField := refType.Field(i)
-		secType := sec.Type()
-		secPrefix := prefix + fieldToEnvVar(secStructField)
+func fieldToEnvVar(field reflect.StructField) string {
+	t := gcfgTagName(field)
+	if t != "" {
+		return envName(t)
+	}
+	return envName(field.Name)
+}
 
-		if !sec.CanSet() || !secStructField.IsExported() {
+// sectionFieldIndex returns the index of the field in the struct type t that
+// gcfg would store the section name in, or -1 if there is no such field.
+func sectionFieldIndex(t reflect.Type, name string) int {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
 			continue
 		}
-
-		// Sections can be either structs or map[string]*struct.
-		if sec.Kind() == reflect.Struct {
-			for j := 0; j < secType.NumField(); j++ {
-				f := sec.Field(j)
-				sf := secType.Field(j)
-				envVar := secPrefix + "_" + fieldToEnvVar(sf)
-				if !f.CanSet() || !sf.IsExported() {
-					continue
-				}
-				val, found := env[envVar]
-				if !found {
-					continue
-				}
-				newRef, err := valFromEnvVar(f.Type(), val)
-				if err != nil {
-					return err
-				}
-				if f.Kind() == reflect.Slice {
-					f.Set(reflect.AppendSlice(f, newRef))
-				} else {
-					f.Set(newRef)
-				}
+		tag := gcfgTagName(sf)
+		if tag != "" {
+			if strings.EqualFold(tag, name) {
+				return i
 			}
 			continue
 		}
-		if sec.Kind() == reflect.Map {
-			subsecType := secType.Elem().Elem()
-			// We don't know in advance what the subsections might
-			// be named -- or if they will be present in the
-			// existing map.
-			matchingEnv := make(map[string]string)
-			for e := range env {
-				if !strings.HasPrefix(e, secPrefix+"_") {
-					continue
-				}
-				newKey := strings.Replace(e, secPrefix+"_", "", 1)
-				if newKey == "" {
-					continue
-				}
-				matchingEnv[newKey] = env[e]
-			}
+		if strings.EqualFold(sf.Name, strings.ReplaceAll(name, "-", "_")) {
+			return i
+		}
+	}
+	return -1
+}
 
-			// First, handle overrides for existing keys in the map.
-			iter := sec.MapRange()
-			for iter.Next() {
-				key := iter.Key().Interface().(string) + "_"
-				if key == "_" {
-					key = ""
-				}
-				subsec := iter.Value().Elem()
-				for j := 0; j < subsecType.NumField(); j++ {
-					f := subsec.Field(j)
-					sf := subsecType.Field(j)
-					envVar := key + fieldToEnvVar(sf)
-					if !f.CanSet() || !sf.IsExported() {
-						continue
-					}
-					val, found := matchingEnv[envVar]
-					if !found {
-						continue
-					}
-					delete(matchingEnv, envVar)
-					newRef, err := valFromEnvVar(f.Type(), val)
-					if err != nil {
-						return err
-					}
-					if f.Kind() == reflect.Slice {
-						f.Set(reflect.AppendSlice(f.Elem(), newRef))
-					} else {
-						f.Set(newRef)
+// applyState holds bookkeeping for a single pass of applying environment
+// overrides.
+type applyState struct {
+	opts   *options
+	prefix string
+	env    map[string]string
+	// used records the environment variables that have been applied.
+	used map[string]bool
+	// newSubsections records the environment variables that created new
+	// subsections.
+	newSubsections []*NewSubsectionError
+	// index indexes the variables in env that begin with prefix.
+	index *envIndex
+	// pinned maps the paths of fields with an "env" tag to the
+	// environment variables they are set from.
+	pinned map[string]string
+}
+
+func newApplyState(o *options, prefix string, env map[string]string) *applyState {
+	return &applyState{
+		opts: o, prefix: prefix, env: env, index: newEnvIndex(env, prefix),
+		used: make(map[string]bool), pinned: make(map[string]string),
+	}
+}
+
+// fieldEnvVar returns the name of the environment variable for the field at
+// path, which holds a section name, any subsection names, and a field name.
+func (st *applyState) fieldEnvVar(path []string) string {
+	if e, ok := st.pinned[strings.Join(path, ".")]; ok {
+		return e
+	}
+	return st.opts.naming.envVarName(st.prefix, path...)
+}
+
+// setGcfgWithEnvMap applies overrides from env to each section of ref. Since
+// sections are independent, an error in one does not prevent the others from
+// being set, and all of the errors are returned together.
+func setGcfgWithEnvMap(st *applyState, ref reflect.Value, prefix string, env *envIndex) error {
+	var errs Errors
+	for i := 0; i < ref.NumField(); i++ {
+		errs = errs.appendError(setSectionWithEnvMap(st, ref, i, prefix, env))
+	}
+	return errs.err()
+}
+
+// setSectionWithEnvMap applies overrides from env to the section stored in the
+// ith field of ref.
+func setSectionWithEnvMap(st *applyState, ref reflect.Value, i int, prefix string, env *envIndex) (err error) {
+	refType := ref.Type()
+	sec := ref.Field(i)
+	secStructField := refType.Field(i)
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Value: r}
+		}
+		err = withPath(err, fieldSectionName(secStructField))
+	}()
+	secType := sec.Type()
+	secPrefix := prefix + st.opts.naming.field(secStructField)
+
+	if !sec.CanSet() || !secStructField.IsExported() {
+		return nil
+	}
+	if st.opts.naming.isShadowed(refType, i) || envDisabled(secStructField) {
+		return nil
+	}
+	if secStructField.Name == rawSectionsField {
+		return nil
+	}
+	if st.opts.streamFunc != nil && sectionFieldIndex(refType, st.opts.streamSection) == i {
+		// Streamed sections are handled separately.
+		return nil
+	}
+
+	// Sections can be either structs or map[string]*struct (or
+	// map[string]map[string]*struct, see nested.go, or map[string]string,
+	// see stringmap.go).
+	if sec.Kind() == reflect.Struct {
+		// Sections that implement encoding.TextUnmarshaler can be set as
+		// a whole from a single variable, before any field overrides.
+		if u, ok := sec.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if val, found := env.vars[secPrefix]; found {
+				st.used[secPrefix] = true
+				if err := u.UnmarshalText([]byte(val)); err != nil {
+					return &ValueError{
+						Name: secPrefix, Value: val,
+						Secret: isSecret(secStructField), Err: err,
 					}
 				}
 			}
-			if len(matchingEnv) == 0 {
+		}
+		var errs Errors
+		for _, ef := range st.opts.naming.envFields(secType) {
+			f := sec.FieldByIndex(ef.index)
+			sf := ef.sf
+			envVar := secPrefix + "_" + ef.name
+			if !f.CanSet() {
+				continue
+			}
+			if pinned := pinnedEnvVar(sf); pinned != "" && len(ef.groups) == 0 {
+				envVar = pinned
+				st.pinned[fieldSectionName(secStructField)+"."+fieldSectionName(sf)] = pinned
+			}
+			val, found := env.vars[envVar]
+			if !found {
 				continue
 			}
+			st.used[envVar] = true
+			newRef, err := st.convert(sf, f.Type(), envVar, val)
+			if err != nil {
+				errs = errs.appendError(ef.withGroups(err))
+				continue
+			}
+			errs = errs.appendError(st.setField(sf, f, newRef))
+		}
+		return errs.err()
+	}
+	if sec.Kind() == reflect.Map && isSubsectionMap(secType) {
+		defaults := ref.FieldByName("Default_" + secStructField.Name)
+		return setSubsectionsWithEnvMap(st, sec, secPrefix, defaults, env)
+	}
+	if isNestedSubsectionMap(secType) {
+		defaults := ref.FieldByName("Default_" + secStructField.Name)
+		return setNestedSubsectionsWithEnvMap(st, sec, secPrefix, defaults, env)
+	}
+	if isStringMapSection(secType) {
+		return setStringMapWithEnvMap(st, sec, secPrefix, env)
+	}
+
+	// Non-section fields do not cause gcfg to error, so we can
+	// ignore them here as well.
+	return nil
+}
+
+// isSubsectionMap reports whether t is a map[string]*struct, which gcfg uses
+// for sections with subsections.
+func isSubsectionMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		t.Elem().Kind() == reflect.Ptr &&
+		t.Elem().Elem().Kind() == reflect.Struct
+}
+
+// setExistingSubsections applies overrides from matchingEnv, keyed without
+// the section prefix secPrefix, to the existing subsections in sec. It returns the keys
+// of matchingEnv that were used. Since subsections are independent, large maps
+// are split between goroutines when WithParallelism is used.
+func (st *applyState) setExistingSubsections(sec reflect.Value, secPrefix string, matchingEnv map[string]string) ([]string, error) {
+	keysBuf := getStringSlice()
+	defer putStringSlice(keysBuf)
+	iter := sec.MapRange()
+	for iter.Next() {
+		*keysBuf = append(*keysBuf, iter.Key().String())
+	}
+	keys := *keysBuf
+	n := st.opts.parallelism
+	if n > len(keys)/minKeysPerShard {
+		n = len(keys) / minKeysPerShard
+	}
+	if n <= 1 {
+		return st.setSubsections(sec, secPrefix, keys, matchingEnv)
+	}
+	used := make([][]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Panics must be recovered on the goroutine they occur on.
+			defer recoverPanic(&errs[i], "")
+			shard := keys[i*len(keys)/n : (i+1)*len(keys)/n]
+			used[i], errs[i] = st.setSubsections(sec, secPrefix, shard, matchingEnv)
+		}(i)
+	}
+	wg.Wait()
+	var out []string
+	for i := range used {
+		out = append(out, used[i]...)
+	}
+	var all Errors
+	for _, err := range errs {
+		all = all.appendError(err)
+	}
+	return out, all.err()
+}
+
+// minKeysPerShard is the smallest number of subsections worth handing to a
+// separate goroutine.
+const minKeysPerShard = 64
 
-			// Second, handle environment variables that will create
-			// new subsections. We also need to account for when
-			// there is a "default value" struct for these new
-			// subsections.
-			defaults := ref.FieldByName(
-				"Default_" + secStructField.Name)
-			if defaults == (reflect.Value{}) {
-				defaults = reflect.Zero(subsecType)
+// setSubsections applies overrides from matchingEnv to the subsections of sec
+// with the given keys, without modifying matchingEnv or st.
+func (st *applyState) setSubsections(sec reflect.Value, secPrefix string, keys []string, matchingEnv map[string]string) ([]string, error) {
+	fields := st.opts.naming.envFields(sec.Type().Elem().Elem())
+	var used []string
+	var errs Errors
+	for _, k := range keys {
+		key := k + "_"
+		if key == "_" {
+			key = ""
+		}
+		subsec := sec.MapIndex(reflect.ValueOf(k)).Elem()
+		for _, ef := range fields {
+			f := subsec.FieldByIndex(ef.index)
+			sf := ef.sf
+			envVar := key + ef.name
+			if !f.CanSet() {
+				continue
 			}
-			for j := 0; j < subsecType.NumField(); j++ {
-				sf := subsecType.Field(j)
-				if !sf.IsExported() {
+			val, found := matchingEnv[envVar]
+			if !found {
+				continue
+			}
+			used = append(used, envVar)
+			newRef, err := st.convert(sf, f.Type(), secPrefix+"_"+envVar, val)
+			if err != nil {
+				errs = errs.appendError(withPath(ef.withGroups(err), k))
+				continue
+			}
+			errs = errs.appendError(st.setField(sf, f, newRef))
+		}
+	}
+	return used, errs.err()
+}
+
+// setSubsectionsWithEnvMap applies overrides from env to sec, a
+// map[string]*struct, creating new subsections as needed. New subsections are
+// initialised with a copy of defaults, if it is valid.
+func setSubsectionsWithEnvMap(st *applyState, sec reflect.Value, secPrefix string, defaults reflect.Value, env *envIndex) error {
+	subsecType := sec.Type().Elem().Elem()
+	// We don't know in advance what the subsections might
+	// be named -- or if they will be present in the
+	// existing map.
+	matchingEnv := getStringMap()
+	defer putStringMap(matchingEnv)
+	for _, e := range env.withPrefix(secPrefix + "_") {
+		newKey := strings.Replace(e, secPrefix+"_", "", 1)
+		if newKey == "" {
+			continue
+		}
+		matchingEnv[newKey] = env.vars[e]
+	}
+
+	// First, handle overrides for existing keys in the map.
+	used, err := st.setExistingSubsections(sec, secPrefix, matchingEnv)
+	for _, envVar := range used {
+		delete(matchingEnv, envVar)
+		st.used[secPrefix+"_"+envVar] = true
+	}
+	var errs Errors
+	errs = errs.appendError(err)
+	if len(matchingEnv) == 0 {
+		return errs.err()
+	}
+
+	// Second, handle environment variables that will create
+	// new subsections. We also need to account for when
+	// there is a "default value" struct for these new
+	// subsections.
+	if !defaults.IsValid() {
+		defaults = reflect.Zero(subsecType)
+	}
+	// Variables that override the components of DSN fields are left for
+	// applyDSNOverrides, but still create their subsections. They are
+	// matched first, since they may also end with the name of a field.
+	for _, ef := range st.opts.naming.envFields(subsecType) {
+		if !isDSNType(ef.sf.Type) {
+			continue
+		}
+		for _, tail := range fieldVarTails(st.opts.naming, ef)[1:] {
+			suf := "_" + tail
+			for e := range matchingEnv {
+				if !strings.HasSuffix(e, suf) {
 					continue
 				}
-				suf := "_" + fieldToEnvVar(sf)
-				for e, v := range matchingEnv {
-					if !strings.HasSuffix(e, suf) {
-						continue
-					}
-					k := strings.Replace(e, suf, "", 1)
-					key := reflect.ValueOf(k)
-					if sec.IsNil() {
-						m := reflect.MakeMap(sec.Type())
-						sec.Set(m)
-					}
-					f := sec.MapIndex(key)
-					if f == (reflect.Value{}) {
-						f = reflect.New(subsecType)
-						f.Elem().Set(defaults)
-						sec.SetMapIndex(key, f)
-					}
-					newRef, err := valFromEnvVar(sf.Type, v)
-					if err != nil {
-						return err
-					}
-					if f.Elem().Field(j).Kind() == reflect.Slice {
-						f.Elem().Field(j).Set(reflect.AppendSlice(f.Elem().Field(j).Elem(), newRef))
-					} else {
-						f.Elem().Field(j).Set(newRef)
-					}
-					// TODO: Does this have any unfortunate
-					// side-effects?
-					delete(matchingEnv, e)
+				_, err := st.subsection(sec, secPrefix, e, strings.TrimSuffix(e, suf), defaults)
+				if err != nil {
+					return errs.appendError(err).err()
 				}
+				delete(matchingEnv, e)
+			}
+		}
+	}
+	for _, ef := range groupedFirst(st.opts.naming.envFields(subsecType)) {
+		sf := ef.sf
+		suf := "_" + ef.name
+		for e, v := range matchingEnv {
+			if !strings.HasSuffix(e, suf) {
+				continue
+			}
+			k := strings.Replace(e, suf, "", 1)
+			f, err := st.subsection(sec, secPrefix, e, k, defaults)
+			if err != nil {
+				return errs.appendError(err).err()
 			}
+			st.used[secPrefix+"_"+e] = true
+			// TODO: Does this have any unfortunate
+			// side-effects?
+			delete(matchingEnv, e)
+			newRef, err := st.convert(sf, sf.Type, secPrefix+"_"+e, v)
+			if err != nil {
+				errs = errs.appendError(withPath(ef.withGroups(err), k))
+				continue
+			}
+			errs = errs.appendError(st.setField(sf, f.Elem().FieldByIndex(ef.index), newRef))
+		}
+	}
+	return errs.err()
+}
 
-			continue
+// subsection returns the subsection k of sec, a map[string]*struct, creating
+// it from a copy of defaults if the map does not have it. The environment
+// variable e, without the section prefix secPrefix, is the one that needs it.
+func (st *applyState) subsection(sec reflect.Value, secPrefix, e, k string, defaults reflect.Value) (reflect.Value, error) {
+	key := reflect.ValueOf(k)
+	if sec.IsNil() {
+		sec.Set(reflect.MakeMap(sec.Type()))
+	}
+	if f := sec.MapIndex(key); f.IsValid() {
+		return f, nil
+	}
+	created := &NewSubsectionError{
+		Name:       secPrefix + "_" + e,
+		Subsection: k,
+	}
+	if st.opts.strictSubsections {
+		return reflect.Value{}, created
+	}
+	if st.opts.limitSubsections && len(st.newSubsections) >= st.opts.maxNewSubsections {
+		return reflect.Value{}, withCode(CodeTooManySubsections, fmt.Errorf(
+			"environment variable %s would create more than %d new subsections",
+			created.Name, st.opts.maxNewSubsections))
+	}
+	st.newSubsections = append(st.newSubsections, created)
+	f := reflect.New(sec.Type().Elem().Elem())
+	f.Elem().Set(deepCopy(defaults))
+	sec.SetMapIndex(key, f)
+	return f, nil
+}
+
+// convert transforms and checks the value of the environment variable name,
+// then converts it to t, the type of the field sf.
+func (st *applyState) convert(sf reflect.StructField, t reflect.Type, name, val string) (v reflect.Value, err error) {
+	// Transforms and UnmarshalText methods are outside our control.
+	defer recoverPanic(&err, fieldSectionName(sf))
+	v, err = st.convertValue(sf, t, val)
+	if err != nil {
+		// The rest of the path is filled in by withPath.
+		return v, &ValueError{
+			Name: name, Path: fieldSectionName(sf), Value: val,
+			Secret: isSecret(sf), Err: err,
 		}
+	}
+	return v, nil
+}
+
+// isSecret reports whether the field sf is tagged gcfgenv:"secret", so that
+// its value is left out of error messages.
+func isSecret(sf reflect.StructField) bool {
+	_, ok := parseFieldTag(sf)["secret"]
+	return ok
+}
 
-		// Non-section fields do not cause gcfg to error, so we can
-		// ignore them here as well.
+func (st *applyState) convertValue(sf reflect.StructField, t reflect.Type, val string) (reflect.Value, error) {
+	val, err := st.opts.checkUTF8(val)
+	if err != nil {
+		return reflect.Value{}, err
 	}
-	return nil
+	val, err = applyTransforms(sf, val, st.env)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	delim := st.opts.fieldDelimiter(sf)
+	val, err = st.opts.localTimes(sf, val, delim)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	val, err = percentValues(sf, val, delim)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err := st.opts.checkBools(t, val, delim); err != nil {
+		return reflect.Value{}, err
+	}
+	whole, err := wholeValue(sf)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if whole {
+		return valFromWholeEnvVar(t, val)
+	}
+	return valFromEnvVarDelim(t, val, delim)
 }
 
+var timeDurationType = reflect.TypeOf(time.Duration(0))
+
+// valFromEnvVar converts env to t, splitting slices on SliceDelimiter.
 func valFromEnvVar(t reflect.Type, env string) (reflect.Value, error) {
+	return valFromEnvVarDelim(t, env, SliceDelimiter)
+}
+
+// valFromEnvVarDelim converts env to t, splitting slices on delim (or not at
+// all, if it is empty).
+func valFromEnvVarDelim(t reflect.Type, env, delim string) (reflect.Value, error) {
 	kind := t.Kind()
 
 	// Try encoding.TextUnmarshaler first. We need to handle both values
@@ -253,7 +809,7 @@ func valFromEnvVar(t reflect.Type, env string) (reflect.Value, error) {
 		if ok {
 			// Slice types have to be unmarshalled per entry.
 			if ptr.Elem().Kind() == reflect.Slice {
-				parts := strings.Split(env, ",")
+				parts := splitEntries(env, delim)
 				for i := range parts {
 					err := unmarshaller.UnmarshalText([]byte(parts[i]))
 					// Stop unmarshalling and return on an error.
@@ -272,7 +828,7 @@ func valFromEnvVar(t reflect.Type, env string) (reflect.Value, error) {
 		if ok {
 			// Slice types have to be unmarshalled per entry.
 			if t.Kind() == reflect.Slice {
-				parts := strings.Split(env, ",")
+				parts := splitEntries(env, delim)
 				for i := range parts {
 					err := unmarshaller.UnmarshalText([]byte(parts[i]))
 					// Stop unmarshalling and return on an error.
@@ -287,9 +843,16 @@ func valFromEnvVar(t reflect.Type, env string) (reflect.Value, error) {
 		}
 	}
 
+	// gcfg reads time.Duration values as plain integers (nanoseconds), but
+	// environment variables can use Go's duration syntax, e.g. "1h30m".
+	if t == timeDurationType {
+		d, err := time.ParseDuration(strings.TrimSpace(env))
+		return reflect.ValueOf(d), err
+	}
+
 	switch t.Kind() {
 	case reflect.Ptr:
-		ref, err := valFromEnvVar(t.Elem(), env)
+		ref, err := valFromEnvVarDelim(t.Elem(), env, delim)
 		ptr := reflect.New(t.Elem())
 		ptr.Elem().Set(ref)
 		return ptr, err
@@ -349,10 +912,10 @@ func valFromEnvVar(t reflect.Type, env string) (reflect.Value, error) {
 		err := types.ScanFully(&f, env, 'v')
 		return reflect.ValueOf(f), err
 	case reflect.Slice:
-		parts := strings.Split(env, ",")
+		parts := splitEntries(env, delim)
 		out := reflect.MakeSlice(t, len(parts), len(parts))
 		for i := range parts {
-			elt, err := valFromEnvVar(t.Elem(), parts[i])
+			elt, err := valFromEnvVarDelim(t.Elem(), parts[i], delim)
 			if err != nil {
 				return reflect.Zero(t), err
 			}
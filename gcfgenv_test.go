@@ -4,10 +4,13 @@
 package gcfgenv
 
 import (
+	"net"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/check.v1"
 	"gopkg.in/gcfg.v1"
@@ -83,6 +86,15 @@ var conversionCases = []struct {
 	{reflect.TypeOf([]string{}), "v1", reflect.ValueOf([]string{"v1"}), ""},
 	{reflect.TypeOf([]string{}), "v1,v2,v3", reflect.ValueOf([]string{"v1", "v2", "v3"}), ""},
 	{reflect.TypeOf([]int8{}), "34,0x1a", reflect.ValueOf([]int8{34, 0x1a}), ""},
+	{reflect.TypeOf([]*int{}), "1,2", reflect.ValueOf([]*int{intPtr(1), intPtr(2)}), ""},
+	{reflect.TypeOf([]*lowerString{}), "A", reflect.ValueOf([]*lowerString{&lowerStringValue2}), ""},
+	{reflect.TypeOf([]*int{}), "1,x", zeroOf([]*int{}), ".*failed to parse.*"},
+	// Durations.
+	{reflect.TypeOf(time.Duration(0)), "1h30m", reflect.ValueOf(90 * time.Minute), ""},
+	{reflect.TypeOf(time.Duration(0)), " 500ms ", reflect.ValueOf(500 * time.Millisecond), ""},
+	{reflect.TypeOf(new(time.Duration)), "30s", reflect.ValueOf(30 * time.Second), ""},
+	{reflect.TypeOf([]time.Duration{}), "1s,5m", reflect.ValueOf([]time.Duration{time.Second, 5 * time.Minute}), ""},
+	{reflect.TypeOf(time.Duration(0)), "30", reflect.ValueOf(time.Duration(0)), ".*missing unit in duration.*"},
 	// TextUnmarshaler.
 	{reflect.TypeOf(lowerStringValue), "VALUE", reflect.ValueOf(lowerStringValue), ""},
 	{reflect.TypeOf(new(lowerString)), "VALUE", reflect.ValueOf(lowerStringValue), ""},
@@ -104,6 +116,12 @@ var conversionCases = []struct {
 	{reflect.TypeOf([][3]int{}), "", zeroOf([][3]int{}), "unsupported type.*"},
 }
 
+var lowerStringValue2 = lowerString("a")
+
+func intPtr(i int) *int {
+	return &i
+}
+
 func zeroOf(i interface{}) reflect.Value {
 	return reflect.Zero(reflect.TypeOf(i))
 }
@@ -251,6 +269,57 @@ func (s *Suite) TestEnvAndPrefixes(c *check.C) {
 	c.Check(cfg, check.DeepEquals, configFilledWithEnvVars)
 }
 
+func (s *Suite) TestReadString(c *check.C) {
+	type sec struct {
+		Field string
+		Other string
+	}
+	type config struct {
+		Sec1 sec
+	}
+
+	os.Setenv("APPNAME_SEC1_FIELD", "set")
+	defer os.Unsetenv("APPNAME_SEC1_FIELD")
+
+	var cfg config
+	err := ReadStringWithEnvInto("[sec1]\nfield = file\nother = file\n", "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{Sec1: sec{"set", "file"}})
+
+	cfg = config{}
+	err = ReadBytesWithEnvInto([]byte("\ufeff[sec1]\nother = file\n"), "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{Sec1: sec{"set", "file"}})
+
+	err = ReadStringWithEnvInto("[sec1", "APPNAME", &cfg)
+	c.Check(ErrorCode(err), check.Equals, CodeFile)
+}
+
+func (s *Suite) TestReservedPrefixes(c *check.C) {
+	type sec struct {
+		Field string
+	}
+	type config struct {
+		Sec      sec
+		Internal map[string]*sec
+	}
+	var err error
+	var cfg config
+	configEnvVars := map[string]string{
+		"APPNAME_SEC_FIELD":            "set",
+		"APPNAME_INTERNAL_k1_FIELD":    "noset",
+		"APPNAME_INTERNAL_WRAPPER_PID": "1234",
+	}
+
+	r := strings.NewReader("")
+	err = readWithMapInto(r, configEnvVars, "APPNAME", &cfg,
+		WithReservedPrefixes("APPNAME_INTERNAL_"))
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{Sec: sec{"set"}})
+	// The caller's map is left untouched.
+	c.Check(configEnvVars, check.HasLen, 3)
+}
+
 func (s *Suite) TestSections(c *check.C) {
 	type sec1 struct {
 		F1 string
@@ -292,7 +361,41 @@ f1 = value
 
 	configEnvVars["SEC2_F2"] = "notanumber"
 	err = readWithMapInto(r, configEnvVars, "", &cfg)
-	c.Check(err, check.ErrorMatches, "failed to parse.*")
+	c.Check(err, check.ErrorMatches, "cannot set Sec2\\..*F[23] from SEC2_.*F[23]=\"notanumber\": failed to parse.*")
+}
+
+func (s *Suite) TestReapplyEnvSection(c *check.C) {
+	type sec struct {
+		Field string
+	}
+	type config struct {
+		Sec1 sec
+		Sec2 map[string]*sec `gcfg:"sec-two"`
+	}
+
+	var err error
+	cfg := config{
+		Sec1: sec{"value"},
+		Sec2: map[string]*sec{"k1": {"value"}},
+	}
+	configEnvVars := map[string]string{
+		"APPNAME_SEC1_FIELD":       "noset",
+		"APPNAME_SEC_TWO_k1_FIELD": "set",
+		"APPNAME_SEC_TWO_k2_FIELD": "set",
+	}
+
+	err = reapplyEnvSectionWithMap(&cfg, "sec-two", configEnvVars, "APPNAME")
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{
+		Sec1: sec{"value"},
+		Sec2: map[string]*sec{"k1": {"set"}, "k2": {"set"}},
+	})
+
+	err = reapplyEnvSectionWithMap(&cfg, "sec3", configEnvVars, "APPNAME")
+	c.Check(err, check.ErrorMatches, "no such section: \"sec3\"")
+
+	err = reapplyEnvSectionWithMap(cfg, "sec1", configEnvVars, "APPNAME")
+	c.Check(err, check.ErrorMatches, "config must be a non-nil pointer to a struct, not .*")
 }
 
 func (s *Suite) TestSkipPrivate(c *check.C) {
@@ -439,12 +542,68 @@ f1 = three
 
 	configEnvVars["SEC2_k1_F3"] = "notanumber"
 	err = readWithMapInto(r, configEnvVars, "", &cfg)
-	c.Check(err, check.ErrorMatches, "failed to parse.*")
+	c.Check(err, check.ErrorMatches, "cannot set Sec2\\..*F[23] from SEC2_.*F[23]=\"notanumber\": failed to parse.*")
 
 	configEnvVars["SEC2_k1_F3"] = "1"
 	configEnvVars["SEC2_k3_F3"] = "notanumber"
 	err = readWithMapInto(r, configEnvVars, "", &cfg)
-	c.Check(err, check.ErrorMatches, "failed to parse.*")
+	c.Check(err, check.ErrorMatches, "cannot set Sec2\\..*F[23] from SEC2_.*F[23]=\"notanumber\": failed to parse.*")
+}
+
+func (s *Suite) TestSubsectionDefaultsAreCopied(c *check.C) {
+	type sec struct {
+		Hosts []string
+		Port  *int
+	}
+	type config struct {
+		Sec         map[string]*sec
+		Default_Sec sec
+	}
+
+	var err error
+	port := 80
+	configEnvVars := map[string]string{
+		"SEC_k1_HOSTS": "a",
+		"SEC_k2_HOSTS": "b,c",
+		"SEC_k3_PORT":  "8080",
+		"SEC_k4_HOSTS": "d",
+	}
+	cfg := config{
+		Default_Sec: sec{Hosts: make([]string, 1, 10), Port: &port},
+	}
+	cfg.Default_Sec.Hosts[0] = "default"
+
+	r := strings.NewReader("[sec \"k4\"]\nhosts = file\n")
+	err = readWithMapInto(r, configEnvVars, "", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Sec["k1"].Hosts, check.DeepEquals, []string{"default", "a"})
+	c.Check(cfg.Sec["k2"].Hosts, check.DeepEquals, []string{"default", "b", "c"})
+	c.Check(cfg.Sec["k3"].Hosts, check.DeepEquals, []string{"default"})
+	c.Check(cfg.Sec["k4"].Hosts, check.DeepEquals, []string{"default", "file", "d"})
+	c.Check(*cfg.Sec["k1"].Port, check.Equals, 80)
+	c.Check(*cfg.Sec["k3"].Port, check.Equals, 8080)
+	c.Check(port, check.Equals, 80)
+	c.Check(cfg.Default_Sec.Hosts, check.DeepEquals, []string{"default"})
+}
+
+func (s *Suite) TestReadWithEnvMapInto(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string
+			List  []string
+		}
+	}
+	env := map[string]string{
+		"APPNAME_SEC_FIELD": "env",
+		"APPNAME_SEC_LIST":  "b,c",
+	}
+	var cfg config
+	r := strings.NewReader("[sec]\nfield = file\nlist = a\n")
+	err := ReadWithEnvMapInto(r, env, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Field, check.Equals, "env")
+	c.Check(cfg.Sec.List, check.DeepEquals, []string{"a", "b", "c"})
+	c.Check(env, check.HasLen, 2)
 }
 
 func (s *Suite) TestGcfgTags(c *check.C) {
@@ -482,6 +641,35 @@ another-name = value
 	c.Check(cfg, check.DeepEquals, configFilledWithEnvVars)
 }
 
+func (s *Suite) TestEnvVarName(c *check.C) {
+	c.Check(EnvVarName("APPNAME", "sec", "field"), check.Equals,
+		"APPNAME_SEC_FIELD")
+	c.Check(EnvVarName("APPNAME_", "sec", "field"), check.Equals,
+		"APPNAME_SEC_FIELD")
+	c.Check(EnvVarName("", "sec", "another-name"), check.Equals,
+		"SEC_ANOTHER_NAME")
+	c.Check(EnvVarName("APPNAME", "sec", "k1", "other-field"), check.Equals,
+		"APPNAME_SEC_k1_OTHER_FIELD")
+	c.Check(EnvVarName("APPNAME", "sec", "env|overwrite- Rich", "f3"),
+		check.Equals, "APPNAME_SEC_env|overwrite- Rich_F3")
+	c.Check(EnvVarName("APPNAME", "sec", "", "field"), check.Equals,
+		"APPNAME_SEC_FIELD")
+
+	// Options following the name in gcfg tags are ignored.
+	type sec struct {
+		F1 int `gcfg:"another-name,int=dh"`
+	}
+	type config struct {
+		Sec sec
+	}
+	var cfg config
+	r := strings.NewReader("")
+	env := map[string]string{EnvVarName("", "sec", "another-name"): "12"}
+	err := readWithMapInto(r, env, "", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{Sec: sec{F1: 12}})
+}
+
 func (s *Suite) TestSliceEnvVars(c *check.C) {
 	type sec struct {
 		Field []string
@@ -569,6 +757,136 @@ func (s *Suite) TestSlicePointerEnvVars(c *check.C) {
 	c.Check(*cfg.Sec.Custom, check.DeepEquals, StringSliceType{"x", "y", "z"})
 }
 
+// endpointSection is a section that can also be set from a single
+// "host:port" value.
+type endpointSection struct {
+	Host string
+	Port int
+}
+
+func (e *endpointSection) UnmarshalText(text []byte) error {
+	host, port, err := net.SplitHostPort(string(text))
+	if err != nil {
+		return err
+	}
+	e.Host = host
+	e.Port, err = strconv.Atoi(port)
+	return err
+}
+
+func (s *Suite) TestPointerSliceEnvVars(c *check.C) {
+	type config struct {
+		Sec struct {
+			Limits []*int
+			Flags  []*bool
+		}
+	}
+	src := `
+[sec]
+limits = 1
+flags = on
+`
+	env := map[string]string{
+		"APP_SEC_LIMITS": "2,0x10",
+		"APP_SEC_FLAGS":  "false",
+	}
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(src), env, "APP", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Limits, check.DeepEquals, []*int{intPtr(1), intPtr(2), intPtr(16)})
+	c.Assert(cfg.Sec.Flags, check.HasLen, 2)
+	c.Check(*cfg.Sec.Flags[0], check.Equals, true)
+	c.Check(*cfg.Sec.Flags[1], check.Equals, false)
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{"APP_SEC_FLAGS": "on"},
+		"APP", &cfg, WithStrictBooleans())
+	c.Check(err, check.ErrorMatches, `cannot set Sec\.Flags from APP_SEC_FLAGS="on": invalid boolean "on": .*`)
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(src), map[string]string{}, "APP", &cfg, WithStrictBooleans())
+	c.Check(err, check.ErrorMatches, `sec.flags: invalid boolean "on": .*`)
+}
+
+func (s *Suite) TestTextUnmarshalerSection(c *check.C) {
+	type config struct {
+		Endpoint endpointSection
+		Other    endpointSection
+	}
+	src := `
+[endpoint]
+host = file.example.com
+port = 80
+[other]
+host = other.example.com
+`
+	env := map[string]string{
+		"APP_ENDPOINT":      "env.example.com:443",
+		"APP_ENDPOINT_PORT": "8443",
+	}
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(src), env, "APP", &cfg, WithStrict())
+	c.Assert(err, check.IsNil)
+	// Field overrides are applied after the whole section.
+	c.Check(cfg.Endpoint, check.Equals, endpointSection{"env.example.com", 8443})
+	c.Check(cfg.Other, check.Equals, endpointSection{Host: "other.example.com"})
+
+	env = map[string]string{"APP_OTHER": "no-port"}
+	err = readWithMapInto(strings.NewReader(src), env, "APP", &cfg)
+	c.Check(err, check.ErrorMatches, ".*missing port in address.*")
+}
+
+func (s *Suite) TestEnvDisabled(c *check.C) {
+	type sub struct {
+		Name    string
+		Enabled bool `gcfgenv:"-"`
+	}
+	type config struct {
+		Auth struct {
+			DisableAuth bool `gcfg:"disable-auth" gcfgenv:"-"`
+			// Not set by DisableAuth's variable either, which would
+			// defeat the opt-out.
+			Disable_Auth bool
+			Realm        string
+		}
+		Sub    map[string]*sub
+		Locked struct{ Field string } `gcfgenv:"-"`
+	}
+	src := "[auth]\nrealm = file\n[sub \"a\"]\nenabled = true\n[locked]\nfield = file\n"
+	env := map[string]string{
+		"APP_AUTH_DISABLE_AUTH": "true",
+		"APP_AUTH_REALM":        "env",
+		"APP_SUB_a_ENABLED":     "false",
+		"APP_SUB_b_ENABLED":     "true",
+		"APP_SUB_c_NAME":        "c",
+		"APP_LOCKED_FIELD":      "env",
+	}
+	var cfg config
+	err := readWithMapInto(strings.NewReader(src), env, "APP", &cfg, WithStrict())
+	c.Check(gcfg.FatalOnly(err), check.IsNil)
+	c.Check(cfg.Auth.DisableAuth, check.Equals, false)
+	c.Check(cfg.Auth.Disable_Auth, check.Equals, false)
+	c.Check(cfg.Auth.Realm, check.Equals, "env")
+	c.Check(cfg.Sub["a"].Enabled, check.Equals, true)
+	c.Check(cfg.Sub["b"], check.IsNil)
+	c.Check(cfg.Sub["c"].Name, check.Equals, "c")
+	c.Check(cfg.Locked.Field, check.Equals, "file")
+	// Variables for such fields are reported as unknown.
+	c.Check(err, check.ErrorMatches, "(?s).*unknown environment variable APP_AUTH_DISABLE_AUTH.*")
+	c.Check(err, check.ErrorMatches, "(?s).*unknown environment variable APP_SUB_b_ENABLED.*")
+	c.Check(err, check.ErrorMatches, "(?s).*unknown environment variable APP_LOCKED_FIELD.*")
+
+	specs, err := EnvVarsFor(&cfg, "APP")
+	c.Assert(err, check.IsNil)
+	var names []string
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	c.Check(names, check.DeepEquals, []string{
+		"APP_AUTH_REALM", "APP_SUB_<name>_NAME",
+	})
+}
+
 func Test(t *testing.T) {
 	_ = check.Suite(&Suite{})
 	check.TestingT(t)
@@ -0,0 +1,49 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package gcfgenvtest provides helpers for testing and benchmarking programs
+// that read their configuration with gcfgenv.
+package gcfgenvtest
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rstudio/gcfgenv"
+	"gopkg.in/gcfg.v1"
+)
+
+// BenchmarkLoad benchmarks reading file and env (as ReadWithEnvMapInto does)
+// into the config struct returned by newConfig, e.g.
+//
+//	func BenchmarkConfig(b *testing.B) {
+//		gcfgenvtest.BenchmarkLoad(b, file, env, "APPNAME",
+//			func() interface{} { return &Config{} })
+//	}
+//
+// so that programs can track the cost of loading their own configurations. It
+// runs three sub-benchmarks, which separate the cost of the file from that of
+// the environment:
+//
+//   - "file" reads file with an empty environment;
+//   - "env" reads an empty file with env; and
+//   - "full" reads file with env.
+//
+// Errors other than gcfg-style warnings fail the benchmark.
+func BenchmarkLoad(b *testing.B, file []byte, env map[string]string, envPrefix string, newConfig func() interface{}, opts ...gcfgenv.Option) {
+	b.Helper()
+	run := func(name string, file []byte, env map[string]string) {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				err := gcfgenv.ReadWithEnvMapInto(bytes.NewReader(file), env, envPrefix, newConfig(), opts...)
+				if gcfg.FatalOnly(err) != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	run("file", file, map[string]string{})
+	run("env", nil, env)
+	run("full", file, env)
+}
@@ -0,0 +1,124 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenvtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rstudio/gcfgenv"
+)
+
+type tenant struct {
+	Quota int
+	Tags  []string
+}
+
+type config struct {
+	Server struct {
+		Listen string
+	}
+	Tenant map[string]*tenant
+}
+
+func BenchmarkExample(b *testing.B) {
+	var file bytes.Buffer
+	file.WriteString("[server]\nlisten = :80\n")
+	env := map[string]string{"APPNAME_SERVER_LISTEN": ":81"}
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&file, "[tenant \"t%d\"]\nquota = %d\ntags = a\n", i, i)
+		env[fmt.Sprintf("APPNAME_TENANT_t%d_TAGS", i)] = "b,c"
+	}
+	BenchmarkLoad(b, file.Bytes(), env, "APPNAME", func() interface{} { return &config{} })
+}
+
+// recorder is a testing.TB that records errors instead of failing.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	newConfig := func() interface{} { return &config{} }
+	CheckRoundTrip(t, []byte("[server]\nlisten = :80\n[tenant \"a.b\"]\nquota = 1\ntags = x\ntags = y\n"), newConfig)
+
+	r := &recorder{TB: t}
+	CheckRoundTrip(r, []byte(`[server]
+listen = :80
+listen = :81
+port = 80
+[cache]
+size = 1
+[tenant "a"]
+quota = 1
+quota = 2
+`), newConfig)
+	want := []string{
+		`can't store data at section "server", variable "port"`,
+		`can't store data at section "cache"`,
+		`server.listen (line 2) is overridden by a later value, since its field is not a slice`,
+		`tenant.a.quota (line 8) is overridden by a later value, since its field is not a slice`,
+	}
+	if strings.Join(r.errors, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got errors:\n%s\nwant:\n%s", strings.Join(r.errors, "\n"), strings.Join(want, "\n"))
+	}
+
+	r = &recorder{TB: t}
+	CheckRoundTrip(r, []byte("[tenant \"a\"]\nquota = many\n"), newConfig)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "many") {
+		t.Errorf("got errors %q, want a conversion error", r.errors)
+	}
+}
+
+func TestLoadForTest(t *testing.T) {
+	os.Setenv("APPNAME_SERVER_LISTEN", ":82")
+	defer os.Unsetenv("APPNAME_SERVER_LISTEN")
+	var name string
+	t.Run("load", func(t *testing.T) {
+		var cfg config
+		err := LoadForTest(t, []byte("[server]\nlisten = :80\n"), &cfg, map[string]string{
+			"SERVER_LISTEN":  ":81",
+			"TENANT_a_QUOTA": "5",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Server.Listen != ":81" || cfg.Tenant["a"] == nil || cfg.Tenant["a"].Quota != 5 {
+			t.Errorf("got %+v", cfg)
+		}
+		for _, kv := range os.Environ() {
+			if strings.HasPrefix(kv, checkPrefix) && strings.HasSuffix(kv, "_SERVER_LISTEN=:81") {
+				name = strings.SplitN(kv, "=", 2)[0]
+			}
+		}
+		if name == "" {
+			t.Error("the override is not in the environment")
+		}
+	})
+	if _, ok := os.LookupEnv(name); ok {
+		t.Errorf("%s is still set after the test", name)
+	}
+
+	t.Run("lowercase", func(t *testing.T) {
+		var cfg config
+		err := LoadForTest(t, []byte("[server]\nlisten = :80\n"), &cfg, map[string]string{
+			"server_listen": ":83",
+		}, gcfgenv.WithLowercaseNames())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Server.Listen != ":83" {
+			t.Errorf("got %q, want \":83\"", cfg.Server.Listen)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenvtest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rstudio/gcfgenv"
+)
+
+// LoadForTest reads file into config with the overrides in env, which are
+// named without a prefix (e.g. "DATABASE_HOST", as returned by
+// gcfgenv.EnvVarName("", "database", "host")):
+//
+//	func TestReplica(t *testing.T) {
+//		var cfg Config
+//		err := gcfgenvtest.LoadForTest(t, file, &cfg, map[string]string{
+//			"DATABASE_HOST": "replica",
+//		})
+//		...
+//	}
+//
+// The overrides are set in the process's environment with t.Setenv, under a
+// random prefix for the test, so that code which reads the environment itself
+// sees them too, and so that they are removed when the test ends. Variables
+// left over from other tests, or set in the developer's shell, do not have
+// the prefix and so are not applied. As with t.Setenv, the test must not be
+// parallel. The prefix is lowercase if the names in env are, as for
+// gcfgenv.WithLowercaseNames.
+//
+// The error is that of gcfgenv.ReadWithEnvInto, including any warnings.
+func LoadForTest(t testing.TB, file []byte, config interface{}, env map[string]string, opts ...gcfgenv.Option) error {
+	t.Helper()
+	prefix := testPrefix(t)
+	if lowercaseNames(env) {
+		prefix = strings.ToLower(prefix)
+	}
+	for k, v := range env {
+		t.Setenv(prefix+gcfgenv.Separator+k, v)
+	}
+	return gcfgenv.ReadWithEnvInto(bytes.NewReader(file), prefix, config, opts...)
+}
+
+// testPrefix returns a new random environment variable prefix.
+func testPrefix(t testing.TB) string {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		t.Fatalf("cannot generate a prefix: %v", err)
+	}
+	return fmt.Sprintf("%s%X", checkPrefix, b)
+}
+
+// lowercaseNames returns true if env is not empty and none of its names have
+// uppercase letters.
+func lowercaseNames(env map[string]string) bool {
+	for k := range env {
+		if strings.ToLower(k) != k {
+			return false
+		}
+	}
+	return len(env) > 0
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenvtest
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rstudio/gcfgenv"
+	"gopkg.in/warnings.v0"
+)
+
+// checkPrefix is the environment variable prefix used by CheckRoundTrip,
+// which reads with an empty environment.
+const checkPrefix = "GCFGENVTEST"
+
+// CheckRoundTrip checks that the config struct returned by newConfig can hold
+// everything in file, e.g. a sample of a production configuration:
+//
+//	func TestProductionConfig(t *testing.T) {
+//		file, err := os.ReadFile("testdata/production.gcfg")
+//		if err != nil {
+//			t.Fatal(err)
+//		}
+//		gcfgenvtest.CheckRoundTrip(t, file,
+//			func() interface{} { return &Config{} })
+//	}
+//
+// file is read both as a gcfgenv.Document and into the struct (with an empty
+// environment), and each way in which they disagree is reported with
+// t.Errorf:
+//
+//   - variables and sections that have no field in the struct, which gcfg
+//     would skip;
+//   - values that cannot be converted to the type of their field; and
+//   - variables given more than once for a field that only holds one
+//     value, so that all but the last are ignored.
+//
+// Files that cannot be parsed at all fail the test immediately.
+func CheckRoundTrip(t testing.TB, file []byte, newConfig func() interface{}, opts ...gcfgenv.Option) {
+	t.Helper()
+	doc, err := gcfgenv.ParseDocument(bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("cannot parse configuration file: %v", err)
+	}
+	config := newConfig()
+	err = gcfgenv.ReadWithEnvMapInto(bytes.NewReader(file), map[string]string{}, checkPrefix, config, opts...)
+	if list, ok := err.(warnings.List); ok {
+		// gcfg warns about each variable in an unknown section.
+		seen := make(map[string]bool)
+		for _, w := range list.Warnings {
+			if !seen[w.Error()] {
+				t.Errorf("%v", w)
+			}
+			seen[w.Error()] = true
+		}
+		err = list.Fatal
+	}
+	if err != nil {
+		// The struct may only be partly set, so do not compare it with
+		// the document.
+		t.Errorf("%v", err)
+		return
+	}
+
+	ref := reflect.Indirect(reflect.ValueOf(config))
+	for _, s := range doc.Sections {
+		for _, v := range s.Variables {
+			if s.Variable(v.Name) == v {
+				continue
+			}
+			f := lookupField(ref, s.Name, s.Subsection, v.Name)
+			if f.IsValid() && f.Kind() != reflect.Slice {
+				t.Errorf("%s (line %d) is overridden by a later value, since its field is not a slice",
+					variablePath(s, v), v.Line)
+			}
+		}
+	}
+}
+
+// variablePath returns the path of v, as used by gcfgenv.UpdateValue.
+func variablePath(s *gcfgenv.DocSection, v *gcfgenv.DocVariable) string {
+	if s.Subsection == "" {
+		return s.Name + "." + v.Name
+	}
+	return s.Name + "." + s.Subsection + "." + v.Name
+}
+
+// normalName returns name as gcfg matches it against field names.
+func normalName(name string) string {
+	return strings.ToLower(strings.Replace(name, "-", "_", -1))
+}
+
+// lookupField returns the field of the config struct ref that holds the given
+// variable, or the zero Value if it cannot be found (e.g. for registered
+// sections, which are not fields of ref).
+func lookupField(ref reflect.Value, section, subsection, name string) reflect.Value {
+	sec := fieldFold(ref, section)
+	if subsection != "" {
+		if sec.Kind() != reflect.Map {
+			return reflect.Value{}
+		}
+		sec = reflect.Indirect(sec.MapIndex(reflect.ValueOf(subsection)))
+	}
+	if sec.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	return fieldFold(sec, name)
+}
+
+// fieldFold returns the field of the struct v that gcfg would use for name.
+func fieldFold(v reflect.Value, name string) reflect.Value {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fieldName := sf.Name
+		if tag := strings.SplitN(sf.Tag.Get("gcfg"), ",", 2)[0]; tag != "" {
+			fieldName = tag
+		}
+		if normalName(fieldName) == normalName(name) {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const generatorPrefix = "gen:"
+
+// WithGenerators replaces string values of the form "gen:<kind>[:<n>]", from
+// the file or the environment, with randomly generated ones. This allows
+// ephemeral secrets such as session keys to be configured without any
+// bootstrap logic. The kinds are:
+//
+//   - "hex:n": n random bytes, hex-encoded.
+//   - "base64:n": n random bytes, encoded with padded, URL-safe base64.
+//   - "uuid": a random (version 4) UUID.
+//
+// If generated is not nil, each generated value is recorded there, keyed by
+// the field's path (e.g. "Session.Key", or "Pool.main.Token" for
+// subsections), so that it can be persisted.
+func WithGenerators(generated map[string]string) Option {
+	return func(o *options) {
+		o.generators = true
+		o.generated = generated
+	}
+}
+
+// generateValues replaces generator references in the string fields of ref.
+func (o *options) generateValues(ref reflect.Value) error {
+	if !o.generators {
+		return nil
+	}
+	return walkFields(ref, func(path []string, sf reflect.StructField, f reflect.Value) error {
+		return o.generateStrings(strings.Join(path, "."), f)
+	})
+}
+
+func (o *options) generateStrings(name string, f reflect.Value) error {
+	switch f.Kind() {
+	case reflect.String:
+		if !strings.HasPrefix(f.String(), generatorPrefix) {
+			return nil
+		}
+		val, err := generate(strings.TrimPrefix(f.String(), generatorPrefix))
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		f.SetString(val)
+		if o.generated != nil {
+			o.generated[name] = val
+		}
+	case reflect.Ptr:
+		if !f.IsNil() {
+			return o.generateStrings(name, f.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < f.Len(); i++ {
+			if err := o.generateStrings(name+"."+strconv.Itoa(i), f.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func generate(spec string) (string, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	kind := parts[0]
+	n := 0
+	if kind == "hex" || kind == "base64" {
+		var err error
+		if len(parts) == 2 {
+			n, err = strconv.Atoi(parts[1])
+		}
+		if len(parts) != 2 || err != nil || n <= 0 {
+			return "", fmt.Errorf("generator %q needs a positive length", kind)
+		}
+	} else if len(parts) != 1 {
+		return "", fmt.Errorf("generator %q does not take arguments", kind)
+	}
+	switch kind {
+	case "hex":
+		b, err := randomBytes(n)
+		return hex.EncodeToString(b), err
+	case "base64":
+		b, err := randomBytes(n)
+		return base64.URLEncoding.EncodeToString(b), err
+	case "uuid":
+		b, err := randomBytes(16)
+		if err != nil {
+			return "", err
+		}
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+	}
+	return "", fmt.Errorf("unknown generator %q", kind)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestGenerators(c *check.C) {
+	type pool struct {
+		Token string
+	}
+	type config struct {
+		Session struct {
+			Key    string
+			ID     *string `gcfg:"id"`
+			Tokens []string
+		}
+		Pool map[string]*pool
+	}
+
+	var err error
+	configString := "[session]\nkey = gen:hex:32\nid = gen:uuid\n"
+
+	// Generators are opt-in.
+	cfg := config{}
+	err = readWithMapInto(strings.NewReader(configString), map[string]string{},
+		"APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Session.Key, check.Equals, "gen:hex:32")
+
+	cfg = config{}
+	generated := make(map[string]string)
+	err = readWithMapInto(strings.NewReader(configString), map[string]string{
+		"APPNAME_SESSION_TOKENS":  "fixed,gen:base64:12",
+		"APPNAME_POOL_main_TOKEN": "gen:hex:4",
+	}, "APPNAME", &cfg, WithGenerators(generated))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Session.Key, check.Matches, "[0-9a-f]{64}")
+	c.Check(*cfg.Session.ID, check.Matches,
+		"[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}")
+	c.Check(cfg.Session.Tokens[0], check.Equals, "fixed")
+	c.Check(cfg.Session.Tokens[1], check.Matches, "[A-Za-z0-9_-]{16}")
+	c.Check(cfg.Pool["main"].Token, check.Matches, "[0-9a-f]{8}")
+	c.Check(generated, check.DeepEquals, map[string]string{
+		"Session.Key":      cfg.Session.Key,
+		"Session.id":       *cfg.Session.ID,
+		"Session.Tokens.1": cfg.Session.Tokens[1],
+		"Pool.main.Token":  cfg.Pool["main"].Token,
+	})
+
+	for spec, msg := range map[string]string{
+		"gen:hex":     `Session.Key: generator "hex" needs a positive length`,
+		"gen:uuid:4":  `Session.Key: generator "uuid" does not take arguments`,
+		"gen:random":  `Session.Key: unknown generator "random"`,
+		"gen:hex:-10": `Session.Key: generator "hex" needs a positive length`,
+	} {
+		cfg = config{}
+		err = readWithMapInto(strings.NewReader(""), map[string]string{
+			"APPNAME_SESSION_KEY": spec,
+		}, "APPNAME", &cfg, WithGenerators(nil))
+		c.Check(err, check.ErrorMatches, msg)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// Section structs may group related fields into plain struct fields, which
+// gcfg cannot set from the file, e.g.
+//
+//	type Server struct {
+//		Listen  string
+//		Timeout struct {
+//			Read  Duration
+//			Write Duration
+//		}
+//	}
+//
+// Their fields can be set by environment variables that add the name of the
+// group, as in APPNAME_SERVER_TIMEOUT_READ. Groups may be nested. Struct
+// fields that implement encoding.TextUnmarshaler (such as HostPort and
+// time.Time) are values rather than groups.
+//
+// The fields of embedded structs are promoted, as gcfg treats them, so they
+// are set by the same variables as fields of the section itself. As in Go,
+// fields of the section take precedence over promoted fields with the same
+// name.
+
+// isGroup reports whether the field sf of a section struct is a group.
+func isGroup(sf reflect.StructField) bool {
+	return sf.Type.Kind() == reflect.Struct && !sf.Anonymous &&
+		!reflect.PtrTo(sf.Type).Implements(textUnmarshalerType)
+}
+
+// isEmbedded reports whether the field sf of a section struct is an embedded
+// struct whose fields are promoted.
+func isEmbedded(sf reflect.StructField) bool {
+	return sf.Type.Kind() == reflect.Struct && sf.Anonymous &&
+		!reflect.PtrTo(sf.Type).Implements(textUnmarshalerType)
+}
+
+// An envField is a field of a section struct that can be set by an
+// environment variable, possibly within a group.
+type envField struct {
+	sf    reflect.StructField
+	index []int
+	// groups holds the names of the groups the field is in, if any.
+	groups []string
+	// name is the part of the environment variable name after the section
+	// and subsection, e.g. "TIMEOUT_READ".
+	name string
+}
+
+// envFields returns the fields of the section struct type t that can be set
+// by environment variables. Those in groups follow the fields before the
+// group, and promoted fields follow the others. Fields whose names are already
+// taken are left out, as for shadowed fields. The result must not be modified.
+func (n naming) envFields(t reflect.Type) []envField {
+	key := shadowKey{t, n}
+	if v, ok := envFieldCache.Load(key); ok {
+		return v.([]envField)
+	}
+	var out []envField
+	seen := make(map[string]bool)
+	var add func(t reflect.Type, index []int, groups []string, prefix string)
+	add = func(t reflect.Type, index []int, groups []string, prefix string) {
+		for j := 0; j < t.NumField(); j++ {
+			sf := t.Field(j)
+			if sf.IsExported() && envDisabled(sf) && !isEmbedded(sf) {
+				// Nor can promoted fields take the name.
+				seen[prefix+n.field(sf)] = true
+			}
+			if !sf.IsExported() || envDisabled(sf) || n.isShadowed(t, j) || isEmbedded(sf) {
+				continue
+			}
+			fieldIndex := append(index[:len(index):len(index)], j)
+			name := prefix + n.field(sf)
+			if isGroup(sf) {
+				add(sf.Type, fieldIndex, append(groups[:len(groups):len(groups)], fieldSectionName(sf)), name+Separator)
+				continue
+			}
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			out = append(out, envField{sf: sf, index: fieldIndex, groups: groups, name: name})
+		}
+		for j := 0; j < t.NumField(); j++ {
+			if sf := t.Field(j); isEmbedded(sf) && !envDisabled(sf) {
+				add(sf.Type, append(index[:len(index):len(index)], j), groups, prefix)
+			}
+		}
+	}
+	add(t, nil, nil, "")
+	envFieldCache.Store(key, out)
+	return out
+}
+
+// envFieldCache caches the results of envFields.
+var envFieldCache sync.Map
+
+// groupedFirst returns fields with those in groups first, so that names that
+// end with the name of a field in a group are matched against it, rather than
+// against a shorter field name that they also end with.
+func groupedFirst(fields []envField) []envField {
+	out := append([]envField(nil), fields...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return len(out[i].index) > len(out[j].index)
+	})
+	return out
+}
+
+// withGroups adds the names of the groups of ef to the path of err.
+func (ef envField) withGroups(err error) error {
+	if len(ef.groups) == 0 {
+		return err
+	}
+	return withPath(err, ef.groups...)
+}
@@ -0,0 +1,117 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestGroups(c *check.C) {
+	type limits struct {
+		Rate  int
+		Burst int
+	}
+	type timeouts struct {
+		Read   time.Duration
+		Write  time.Duration
+		Limits limits
+	}
+	type server struct {
+		Listen   HostPort
+		Timeouts timeouts
+		Read     string
+	}
+	type config struct {
+		Server  server
+		Backend map[string]*server
+	}
+
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(`[server]
+listen = :80
+[backend "a"]
+listen = a:80
+`), map[string]string{
+		"APPNAME_SERVER_LISTEN":                   ":81",
+		"APPNAME_SERVER_TIMEOUTS_READ":            "5s",
+		"APPNAME_SERVER_TIMEOUTS_LIMITS_RATE":     "10",
+		"APPNAME_SERVER_READ":                     "top",
+		"APPNAME_BACKEND_a_TIMEOUTS_WRITE":        "1m",
+		"APPNAME_BACKEND_b_TIMEOUTS_LIMITS_BURST": "3",
+		"APPNAME_BACKEND_b_READ":                  "new",
+	}, "APPNAME", &cfg, WithStrict())
+	c.Assert(err, check.ErrorMatches, `(?s).*environment variable APPNAME_BACKEND_b_\w+ creates new subsection "b".*`)
+	c.Check(cfg.Server, check.DeepEquals, server{
+		Listen:   HostPort{Port: 81},
+		Timeouts: timeouts{Read: 5 * time.Second, Limits: limits{Rate: 10}},
+		Read:     "top",
+	})
+	c.Check(cfg.Backend, check.DeepEquals, map[string]*server{
+		"a": {Listen: HostPort{Host: "a", Port: 80}, Timeouts: timeouts{Write: time.Minute}},
+		"b": {Timeouts: timeouts{Limits: limits{Burst: 3}}, Read: "new"},
+	})
+
+	// Errors give the path to the field.
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{
+		"APPNAME_SERVER_TIMEOUTS_LIMITS_RATE": "fast",
+		"APPNAME_BACKEND_a_TIMEOUTS_READ":     "soon",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.FitsTypeOf, Errors{})
+	errs := err.(Errors)
+	c.Assert(errs, check.HasLen, 2)
+	c.Check(errs[0].(*ValueError).Path, check.Equals, "Server.Timeouts.Limits.Rate")
+	c.Check(errs[1].(*ValueError).Path, check.Equals, "Backend.a.Timeouts.Read")
+}
+
+type tlsOptions struct {
+	CertFile string `gcfg:"cert-file" gcfgenv:"required"`
+	KeyFile  string `gcfg:"key-file"`
+	Verify   bool
+}
+
+func (s *Suite) TestEmbeddedFields(c *check.C) {
+	type listener struct {
+		tlsOptions
+		Listen string
+		// Verify takes precedence over the promoted field.
+		Verify string
+	}
+	type config struct {
+		Server listener
+		Extra  map[string]*listener
+	}
+
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(`[server]
+cert-file = server.pem
+listen = :443
+verify = peer
+[extra "admin"]
+cert-file = admin.pem
+key-file = admin.key
+`), map[string]string{
+		"APPNAME_SERVER_KEY_FILE":      "server.key",
+		"APPNAME_SERVER_VERIFY":        "none",
+		"APPNAME_EXTRA_admin_KEY_FILE": "other.key",
+		"APPNAME_EXTRA_ops_CERT_FILE":  "ops.pem",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server, check.DeepEquals, listener{
+		tlsOptions: tlsOptions{CertFile: "server.pem", KeyFile: "server.key"},
+		Listen:     ":443",
+		Verify:     "none",
+	})
+	c.Check(cfg.Extra, check.DeepEquals, map[string]*listener{
+		"admin": {tlsOptions: tlsOptions{CertFile: "admin.pem", KeyFile: "other.key"}},
+		"ops":   {tlsOptions: tlsOptions{CertFile: "ops.pem"}},
+	})
+
+	// Directives on promoted fields apply.
+	err = readWithMapInto(strings.NewReader("[server]\nlisten = :443\n"), nil, "APPNAME", &config{})
+	c.Check(err, check.ErrorMatches, `Server.cert-file is required, .*`)
+}
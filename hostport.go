@@ -0,0 +1,118 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A HostPort is a "host:port" pair, such as "example.com:443", "[::1]:80",
+// or ":8080". The port is optional: if it is missing, the Port field is zero,
+// unless the field has a gcfgenv:"defaultport=n" directive.
+type HostPort struct {
+	Host string
+	Port int
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (hp *HostPort) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	host, port, err := net.SplitHostPort(s)
+	if ip := net.ParseIP(s); ip != nil {
+		// A bare IPv6 address.
+		host, port, err = s, "", nil
+	}
+	if err != nil {
+		// The port is optional, so try again with just the host.
+		if _, _, err2 := net.SplitHostPort(s + ":0"); err2 != nil {
+			return fmt.Errorf("invalid host:port %q: %v", text, err)
+		}
+		host, port = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"), ""
+	}
+	if !validHost(host) {
+		return fmt.Errorf("invalid host:port %q: invalid host %q", text, host)
+	}
+	n := 0
+	if port != "" {
+		n, err = strconv.Atoi(port)
+		if err != nil || n < 0 || n > 65535 {
+			return fmt.Errorf("invalid host:port %q: invalid port %q", text, port)
+		}
+	}
+	*hp = HostPort{Host: host, Port: n}
+	return nil
+}
+
+// String returns the pair in "host:port" form, or just the host if there is
+// no port.
+func (hp HostPort) String() string {
+	if hp.Port == 0 {
+		if strings.Contains(hp.Host, ":") {
+			return "[" + hp.Host + "]"
+		}
+		return hp.Host
+	}
+	return net.JoinHostPort(hp.Host, strconv.Itoa(hp.Port))
+}
+
+// validHost reports whether host is empty, an IP address, or a hostname.
+func validHost(host string) bool {
+	if host == "" || net.ParseIP(host) != nil {
+		return true
+	}
+	for _, label := range strings.Split(host, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+				c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var hostPortType = reflect.TypeOf(HostPort{})
+
+// fillDefaultPorts sets the port of HostPort fields (and slices of them) that
+// have a gcfgenv:"defaultport=n" directive but no port.
+func fillDefaultPorts(ref reflect.Value) error {
+	return walkFields(ref, func(path []string, sf reflect.StructField, f reflect.Value) error {
+		v, ok := parseFieldTag(sf)["defaultport"]
+		if !ok {
+			return nil
+		}
+		port, err := strconv.Atoi(v)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid defaultport directive on %s: %q",
+				strings.Join(path, "."), v)
+		}
+		setDefaultPort(f, port)
+		return nil
+	})
+}
+
+func setDefaultPort(f reflect.Value, port int) {
+	switch {
+	case f.Type() == hostPortType:
+		hp := f.Addr().Interface().(*HostPort)
+		if hp.Port == 0 && hp.Host != "" {
+			hp.Port = port
+		}
+	case f.Kind() == reflect.Ptr:
+		if !f.IsNil() {
+			setDefaultPort(f.Elem(), port)
+		}
+	case f.Kind() == reflect.Slice:
+		for i := 0; i < f.Len(); i++ {
+			setDefaultPort(f.Index(i), port)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestHostPort(c *check.C) {
+	cases := []struct {
+		in  string
+		out HostPort
+		err string
+	}{
+		{in: "example.com:443", out: HostPort{"example.com", 443}},
+		{in: "example.com", out: HostPort{"example.com", 0}},
+		{in: ":8080", out: HostPort{"", 8080}},
+		{in: "10.0.0.1:53", out: HostPort{"10.0.0.1", 53}},
+		{in: "[::1]:80", out: HostPort{"::1", 80}},
+		{in: "[::1]", out: HostPort{"::1", 0}},
+		{in: "::1", out: HostPort{"::1", 0}},
+		{in: "my_host-1.internal", out: HostPort{"my_host-1.internal", 0}},
+		{in: "example.com:http", err: `invalid host:port "example.com:http": invalid port "http"`},
+		{in: "example.com:70000", err: `.*invalid port "70000"`},
+		{in: "bad host:80", err: `.*invalid host "bad host"`},
+		{in: "-bad.com", err: `.*invalid host "-bad.com"`},
+		{in: "a:b:c", err: `invalid host:port "a:b:c": .*`},
+	}
+	for _, tc := range cases {
+		var hp HostPort
+		err := hp.UnmarshalText([]byte(tc.in))
+		if tc.err != "" {
+			c.Check(err, check.ErrorMatches, tc.err, check.Commentf("%q", tc.in))
+			continue
+		}
+		c.Check(err, check.IsNil, check.Commentf("%q", tc.in))
+		c.Check(hp, check.Equals, tc.out, check.Commentf("%q", tc.in))
+	}
+
+	c.Check(HostPort{"example.com", 443}.String(), check.Equals, "example.com:443")
+	c.Check(HostPort{"::1", 80}.String(), check.Equals, "[::1]:80")
+	c.Check(HostPort{"::1", 0}.String(), check.Equals, "[::1]")
+	c.Check(HostPort{"", 8080}.String(), check.Equals, ":8080")
+
+	type config struct {
+		Cluster struct {
+			Listen HostPort   `gcfgenv:"defaultport=7000"`
+			Seeds  []HostPort `gcfgenv:"defaultport=7000"`
+			Admin  *HostPort
+		}
+	}
+	cfg := config{}
+	r := strings.NewReader("[cluster]\nlisten = :7001\nseeds = a\nseeds = b:7002\n")
+	err := readWithMapInto(r, map[string]string{
+		"APPNAME_CLUSTER_SEEDS": "c,[::1]",
+		"APPNAME_CLUSTER_ADMIN": "localhost:9000",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Cluster.Listen, check.Equals, HostPort{"", 7001})
+	c.Check(cfg.Cluster.Seeds, check.DeepEquals, []HostPort{
+		{"a", 7000}, {"b", 7002}, {"c", 7000}, {"::1", 7000},
+	})
+	c.Check(*cfg.Cluster.Admin, check.Equals, HostPort{"localhost", 9000})
+
+	cfg = config{}
+	r = strings.NewReader("[cluster]\nlisten = bad host\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `(?s).*invalid host "bad host".*`)
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package httpconf provides a reusable configuration section for HTTP client
+// settings, for use with gcfgenv.
+//
+// For example,
+//
+//	[upstream]
+//	timeout = 30s
+//	proxy = http://proxy.internal:3128
+//	retries = 3
+//	retry-backoff = 2*100ms
+//
+// can be read into an httpconf.Section field named Upstream, and overridden by
+// variables such as APPNAME_UPSTREAM_TIMEOUT.
+package httpconf
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rstudio/gcfgenv"
+	"github.com/rstudio/gcfgenv/tlsconf"
+)
+
+// Section holds HTTP client settings. The zero value uses Go's defaults and
+// does not retry.
+type Section struct {
+	// Timeout limits the whole request, including retries.
+	Timeout gcfgenv.Duration
+	// DialTimeout limits establishing each connection.
+	DialTimeout gcfgenv.Duration `gcfg:"dial-timeout"`
+	// IdleConnTimeout limits how long idle connections are kept.
+	IdleConnTimeout gcfgenv.Duration `gcfg:"idle-conn-timeout"`
+	// Proxy is the URL of a proxy. If it is empty, the usual HTTP_PROXY
+	// environment variables are used; "none" disables proxying.
+	Proxy string
+	// Retries is the number of times idempotent requests are retried after
+	// a network error or a 502, 503, or 504 response.
+	Retries int
+	// RetryBackoff is the delay before the first retry, which is doubled
+	// for each subsequent one.
+	RetryBackoff gcfgenv.Duration `gcfg:"retry-backoff"`
+	// CAFile is a PEM-encoded file of certificate authorities used to
+	// verify servers, instead of the system roots.
+	CAFile string `gcfg:"ca-file"`
+	// InsecureSkipVerify disables verification of server certificates.
+	InsecureSkipVerify bool `gcfg:"insecure-skip-verify"`
+}
+
+// Client returns an *http.Client for the settings.
+func (s *Section) Client() (*http.Client, error) {
+	if s.Retries < 0 {
+		return nil, fmt.Errorf("http: retries must not be negative")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	switch s.Proxy {
+	case "":
+	case "none":
+		transport.Proxy = nil
+	default:
+		u, err := url.Parse(s.Proxy)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("http: invalid proxy %q", s.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if s.DialTimeout > 0 {
+		dialer := &net.Dialer{Timeout: time.Duration(s.DialTimeout), KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+	}
+	if s.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = time.Duration(s.IdleConnTimeout)
+	}
+	if s.CAFile != "" || s.InsecureSkipVerify {
+		tlsConfig, err := (&tlsconf.Section{CAFile: s.CAFile}).Config()
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.InsecureSkipVerify = s.InsecureSkipVerify
+		transport.TLSClientConfig = tlsConfig
+	}
+	var rt http.RoundTripper = transport
+	if s.Retries > 0 {
+		rt = &retryTransport{
+			next:    transport,
+			retries: s.Retries,
+			backoff: time.Duration(s.RetryBackoff),
+		}
+	}
+	return &http.Client{Transport: rt, Timeout: time.Duration(s.Timeout)}, nil
+}
+
+// retryTransport retries idempotent requests that fail with a network error
+// or a temporary server error.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.retries || !retryable(req, resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func retryable(req *http.Request, resp *http.Response, err error) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+	default:
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
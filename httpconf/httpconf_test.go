@@ -0,0 +1,88 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package httpconf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rstudio/gcfgenv"
+	"gopkg.in/check.v1"
+)
+
+type Suite struct{}
+
+func Test(t *testing.T) {
+	_ = check.Suite(&Suite{})
+	check.TestingT(t)
+}
+
+func (s *Suite) TestClient(c *check.C) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	type config struct {
+		Upstream Section
+	}
+	cfg := config{}
+	os.Setenv("APPNAME_UPSTREAM_RETRIES", "2")
+	defer os.Unsetenv("APPNAME_UPSTREAM_RETRIES")
+	r := strings.NewReader(`[upstream]
+timeout = 2*5s
+dial-timeout = 1s
+retry-backoff = 10ms
+proxy = none
+`)
+	err := gcfgenv.ReadWithEnvInto(r, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(time.Duration(cfg.Upstream.Timeout), check.Equals, 10*time.Second)
+	c.Check(cfg.Upstream.Retries, check.Equals, 2)
+
+	client, err := cfg.Upstream.Client()
+	c.Assert(err, check.IsNil)
+	c.Check(client.Timeout, check.Equals, 10*time.Second)
+	resp, err := client.Get(server.URL)
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, check.Equals, http.StatusOK)
+	c.Check(atomic.LoadInt32(&calls), check.Equals, int32(3))
+
+	// Non-idempotent requests are not retried.
+	atomic.StoreInt32(&calls, 0)
+	resp, err = client.Post(server.URL, "text/plain", strings.NewReader("body"))
+	c.Assert(err, check.IsNil)
+	resp.Body.Close()
+	c.Check(resp.StatusCode, check.Equals, http.StatusServiceUnavailable)
+	c.Check(atomic.LoadInt32(&calls), check.Equals, int32(1))
+}
+
+func (s *Suite) TestClientErrors(c *check.C) {
+	_, err := (&Section{Proxy: "::"}).Client()
+	c.Check(err, check.ErrorMatches, `http: invalid proxy "::"`)
+	_, err = (&Section{Retries: -1}).Client()
+	c.Check(err, check.ErrorMatches, `http: retries must not be negative`)
+	_, err = (&Section{CAFile: "/nonexistent/ca.pem"}).Client()
+	c.Check(err, check.ErrorMatches, `tls: open /nonexistent/ca.pem: .*`)
+
+	client, err := (&Section{InsecureSkipVerify: true, Proxy: "http://proxy:3128"}).Client()
+	c.Assert(err, check.IsNil)
+	transport := client.Transport.(*http.Transport)
+	c.Check(transport.TLSClientConfig.InsecureSkipVerify, check.Equals, true)
+	req, _ := http.NewRequest("GET", "http://example.com", nil)
+	proxy, err := transport.Proxy(req)
+	c.Assert(err, check.IsNil)
+	c.Check(proxy.String(), check.Equals, "http://proxy:3128")
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"sort"
+	"strings"
+)
+
+// An envIndex holds a set of environment variables along with their sorted
+// names, so that those with a given prefix can be found without scanning the
+// whole set. Sections look up their variables this way, which keeps reading
+// fast even when there are thousands of unrelated variables. An index is
+// built wherever a set of variables is made, and passed along with it.
+type envIndex struct {
+	vars  map[string]string
+	names []string
+}
+
+// newEnvIndex indexes the variables in env that begin with prefix. Only those
+// variables can be found with withPrefix, so that unrelated variables cost
+// little more than a comparison each, but all of env is kept in vars.
+func newEnvIndex(env map[string]string, prefix string) *envIndex {
+	var names []string
+	for e := range env {
+		if strings.HasPrefix(e, prefix) {
+			names = append(names, e)
+		}
+	}
+	sort.Strings(names)
+	return &envIndex{vars: env, names: names}
+}
+
+// withPrefix returns the sorted names that begin with prefix. The result is a
+// part of the index, so it must not be modified, but nothing is allocated.
+func (ix *envIndex) withPrefix(prefix string) []string {
+	i := sort.SearchStrings(ix.names, prefix)
+	j := i + sort.Search(len(ix.names)-i, func(n int) bool {
+		return !strings.HasPrefix(ix.names[i+n], prefix)
+	})
+	return ix.names[i:j]
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestEnvIndex(c *check.C) {
+	env := map[string]string{
+		"APP_A":   "",
+		"APP_B":   "",
+		"APPX":    "",
+		"OTHER_A": "",
+		"APP_":    "",
+	}
+	ix := newEnvIndex(env, "")
+	c.Check(ix.withPrefix("APP_"), check.DeepEquals, []string{"APP_", "APP_A", "APP_B"})
+	c.Check(ix.withPrefix("APP"), check.DeepEquals, []string{"APPX", "APP_", "APP_A", "APP_B"})
+	c.Check(ix.withPrefix("ZZZ"), check.HasLen, 0)
+	c.Check(ix.withPrefix(""), check.HasLen, 5)
+
+	ix = newEnvIndex(env, "APP_")
+	c.Check(ix.withPrefix("APP_"), check.DeepEquals, []string{"APP_", "APP_A", "APP_B"})
+	c.Check(ix.withPrefix("APP_B"), check.DeepEquals, []string{"APP_B"})
+}
+
+// largeEnv returns an environment with n unrelated variables, plus a few
+// overrides for benchConfig.
+func largeEnv(n int) map[string]string {
+	env := make(map[string]string, n+3)
+	for i := 0; i < n; i++ {
+		env[fmt.Sprintf("UNRELATED_%d_VAR", i)] = "x"
+	}
+	env["APPNAME_SERVER_LISTEN"] = ":81"
+	env["APPNAME_TENANT_t1_QUOTA"] = "1"
+	env["APPNAME_TENANT_t1_QOUTA"] = "1"
+	return env
+}
+
+// The cost of reading a configuration should be largely independent of the
+// number of unrelated environment variables, with or without WithStrict.
+// These benchmarks are part of the package's performance contract.
+
+func benchmarkLargeEnv(b *testing.B, n int, opts ...Option) {
+	src := string(benchmarkTenants(100))
+	env := largeEnv(n)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cfg := benchConfig{}
+		err := readWithMapInto(strings.NewReader(src), env, "APPNAME", &cfg, opts...)
+		if len(opts) == 0 && err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEnv100(b *testing.B)       { benchmarkLargeEnv(b, 100) }
+func BenchmarkEnv10k(b *testing.B)       { benchmarkLargeEnv(b, 10000) }
+func BenchmarkEnv100Strict(b *testing.B) { benchmarkLargeEnv(b, 100, WithStrict()) }
+func BenchmarkEnv10kStrict(b *testing.B) { benchmarkLargeEnv(b, 10000, WithStrict()) }
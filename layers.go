@@ -0,0 +1,113 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/gcfg.v1"
+)
+
+// WithEnvironmentFiles makes ReadFileWithEnvInto layer files for the current
+// environment and machine over the one it is given. For "config.ini", it also
+// reads, if they exist:
+//
+//   - "config.<env>.ini", where <env> is the value of the environment
+//     variable envVar (e.g. "APP_ENV=staging"), if it is set; and then
+//   - "config.local.ini", e.g. for a developer's own settings, which should
+//     not be checked in.
+//
+// Later files override the values of earlier ones, as if they were appended
+// to the first, and environment variables are applied last. Each file's
+// syntax is checked separately, so that errors name the file they are in.
+func WithEnvironmentFiles(envVar string) Option {
+	return func(o *options) {
+		o.envFilesVar = envVar
+	}
+}
+
+// layeredFiles returns the files to read for filename and the environment
+// name envName, in order. Only the first is required.
+func layeredFiles(filename, envName string) ([]string, error) {
+	if strings.ContainsAny(envName, `/\`) || envName == "." || envName == ".." {
+		return nil, fmt.Errorf("invalid environment name %q", envName)
+	}
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	files := []string{filename}
+	if envName != "" && envName != "local" {
+		files = append(files, base+"."+envName+ext)
+	}
+	return append(files, base+".local"+ext), nil
+}
+
+// readLayeredFiles returns the contents of filename followed by those of any
+// files for the environment envName that exist.
+func readLayeredFiles(filename, envName string, o *options) ([]byte, error) {
+	files, err := layeredFiles(filename, envName)
+	if err != nil {
+		return nil, err
+	}
+	return readFiles(files, true, o)
+}
+
+// readFiles returns the contents of files, one after the other, checking the
+// syntax of each on its own. If skipMissing is true, files after the first
+// that do not exist are skipped.
+func readFiles(files []string, skipMissing bool, o *options) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, name := range files {
+		f, closeFile, err := openConfigFile(name, o)
+		if i > 0 && skipMissing && os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = appendFile(&buf, name, f, o)
+		closeFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// appendFile checks the syntax of the file name, read from f, and appends its
+// contents to buf. Mapped files are only valid until they are closed, so it
+// must be called before then.
+func appendFile(buf *bytes.Buffer, name string, f io.Reader, o *options) error {
+	src, err := readAll(f)
+	if err != nil {
+		return err
+	}
+	if err := checkLayer(name, src, o); err != nil {
+		return err
+	}
+	if buf.Len() > 0 {
+		// The previous file may not end with a newline.
+		buf.WriteByte('\n')
+	}
+	src, _ = skipBOM(src)
+	buf.Write(src)
+	return nil
+}
+
+// checkLayer checks the syntax of src, read from the file name, on its own.
+func checkLayer(name string, src []byte, o *options) error {
+	src, _ = skipBOM(src)
+	if o.iniCompat {
+		src = iniCompat(src)
+	}
+	err := gcfg.FatalOnly(gcfg.ReadInto(&struct{}{}, bytes.NewReader(src)))
+	if err != nil {
+		return fileError(fmt.Errorf("%s: %v", name, err))
+	}
+	return nil
+}
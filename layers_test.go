@@ -0,0 +1,117 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestEnvironmentFiles(c *check.C) {
+	type config struct {
+		Server struct {
+			Listen string
+			Debug  bool
+			Peers  []string
+		}
+		Database struct {
+			URL string
+		}
+	}
+	dir := c.MkDir()
+	write := func(name, contents string) {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600)
+		c.Assert(err, check.IsNil)
+	}
+	write("config.ini", "[server]\nlisten = :80\npeers = a\n[database]\nurl = postgres://prod")
+	write("config.staging.ini", "\ufeff[database]\nurl = postgres://staging\n")
+	write("config.local.ini", "[server]\ndebug\npeers = b\n")
+	filename := filepath.Join(dir, "config.ini")
+	os.Setenv("LAYERTEST_SERVER_LISTEN", ":8080")
+	defer os.Unsetenv("LAYERTEST_SERVER_LISTEN")
+
+	var err error
+	cfg := config{}
+	err = ReadFileWithEnvInto(filename, "LAYERTEST", &cfg, WithEnvironmentFiles("LAYERTEST_ENV"))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Listen, check.Equals, ":8080")
+	c.Check(cfg.Server.Debug, check.Equals, true)
+	c.Check(cfg.Server.Peers, check.DeepEquals, []string{"a", "b"})
+	c.Check(cfg.Database.URL, check.Equals, "postgres://prod")
+
+	os.Setenv("LAYERTEST_ENV", "staging")
+	defer os.Unsetenv("LAYERTEST_ENV")
+	cfg = config{}
+	err = ReadFileWithEnvInto(filename, "LAYERTEST", &cfg, WithEnvironmentFiles("LAYERTEST_ENV"))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Database.URL, check.Equals, "postgres://staging")
+	c.Check(cfg.Server.Debug, check.Equals, true)
+
+	// Without the option, only the given file is read.
+	cfg = config{}
+	err = ReadFileWithEnvInto(filename, "LAYERTEST", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Database.URL, check.Equals, "postgres://prod")
+	c.Check(cfg.Server.Debug, check.Equals, false)
+
+	// Files for environments that do not exist are skipped, but the base
+	// file is required.
+	os.Setenv("LAYERTEST_ENV", "production")
+	cfg = config{}
+	err = ReadFileWithEnvInto(filename, "LAYERTEST", &cfg, WithEnvironmentFiles("LAYERTEST_ENV"))
+	c.Check(err, check.IsNil)
+	cfg = config{}
+	err = ReadFileWithEnvInto(filepath.Join(dir, "other.ini"), "LAYERTEST", &cfg, WithEnvironmentFiles("LAYERTEST_ENV"))
+	c.Check(os.IsNotExist(err), check.Equals, true)
+
+	os.Setenv("LAYERTEST_ENV", "../secrets")
+	err = ReadFileWithEnvInto(filename, "LAYERTEST", &cfg, WithEnvironmentFiles("LAYERTEST_ENV"))
+	c.Check(err, check.ErrorMatches, `invalid environment name "\.\./secrets"`)
+
+	// Syntax errors name the file they are in.
+	os.Setenv("LAYERTEST_ENV", "staging")
+	write("config.staging.ini", "[database\n")
+	err = ReadFileWithEnvInto(filename, "LAYERTEST", &cfg, WithEnvironmentFiles("LAYERTEST_ENV"))
+	c.Check(err, check.ErrorMatches, `.*config\.staging\.ini: 1:10: .*`)
+	c.Check(ErrorCode(err), check.Equals, CodeFile)
+}
+
+func (s *Suite) TestReadFiles(c *check.C) {
+	type config struct {
+		Server struct {
+			Listen string
+			Peers  []string
+		}
+		Database struct {
+			URL string
+		}
+	}
+	dir := c.MkDir()
+	base := filepath.Join(dir, "base.ini")
+	override := filepath.Join(dir, "override.ini")
+	c.Assert(ioutil.WriteFile(base, []byte("[server]\nlisten = :80\npeers = a\n[database]\nurl = sqlite://"), 0o600), check.IsNil)
+	c.Assert(ioutil.WriteFile(override, []byte("[database]\nurl = postgres://db\n[server]\npeers = b\n"), 0o600), check.IsNil)
+	os.Setenv("FILESTEST_SERVER_LISTEN", ":8080")
+	defer os.Unsetenv("FILESTEST_SERVER_LISTEN")
+
+	for _, opts := range [][]Option{nil, {WithMmap()}} {
+		cfg := config{}
+		err := ReadFilesWithEnvInto([]string{base, override}, "FILESTEST", &cfg, opts...)
+		c.Assert(err, check.IsNil)
+		c.Check(cfg.Server.Listen, check.Equals, ":8080")
+		c.Check(cfg.Server.Peers, check.DeepEquals, []string{"a", "b"})
+		c.Check(cfg.Database.URL, check.Equals, "postgres://db")
+	}
+
+	cfg := config{}
+	err := ReadFilesWithEnvInto([]string{base, filepath.Join(dir, "missing.ini")}, "FILESTEST", &cfg)
+	c.Check(os.IsNotExist(err), check.Equals, true)
+
+	c.Assert(ioutil.WriteFile(override, []byte("[server]\nlisten = :81\n[database\n"), 0o600), check.IsNil)
+	err = ReadFilesWithEnvInto([]string{base, override}, "FILESTEST", &cfg)
+	c.Check(err, check.ErrorMatches, `.*override\.ini: 3:10: .*`)
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A RawConfig holds the variables of a configuration file before they are
+// stored in a config struct. Sections and variables are keyed in the same way
+// as the RawSections field, but every value of a variable is kept, in order.
+// Variables given without a value (as is common for booleans) have the value
+// "true".
+type RawConfig map[string]map[string][]string
+
+// A Migration upgrades a configuration file from one schema version to
+// another by modifying its variables in place, e.g. to rename a section.
+type Migration struct {
+	From, To int
+	Migrate  func(raw RawConfig) error
+}
+
+// WithMigrations upgrades older configuration files before they are stored in
+// the config struct, so that they keep working as the struct evolves. The
+// file's version is read from versionKey, given as "section.variable" (e.g.
+// "meta.config-version"), and files without one are at version 0. Migrations
+// are applied in sequence, starting with the one whose From matches the
+// file's version, and versionKey is then set to the final version.
+//
+// Environment variables are applied after migration, and so always use the
+// current schema.
+func WithMigrations(versionKey string, migrations ...Migration) Option {
+	return func(o *options) {
+		o.versionKey = versionKey
+		o.migrations = append(o.migrations, migrations...)
+	}
+}
+
+// migrate applies migrations to src, returning it unchanged if there are none
+// to apply.
+func migrate(src []byte, versionKey string, migrations []Migration) ([]byte, error) {
+	parts := strings.SplitN(strings.ToLower(versionKey), ".", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid version key %q", versionKey)
+	}
+	raw, sections, names := rawConfigFromEntries(scanGcfg(src))
+	version := 0
+	if vals := raw[parts[0]][parts[1]]; len(vals) > 0 {
+		v, err := strconv.Atoi(strings.TrimSpace(vals[len(vals)-1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %q", versionKey, vals[len(vals)-1])
+		}
+		version = v
+	}
+	applied := false
+	for {
+		var next *Migration
+		for i := range migrations {
+			if migrations[i].From == version {
+				next = &migrations[i]
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		if next.To <= next.From {
+			return nil, fmt.Errorf("migration from version %d to %d does not upgrade",
+				next.From, next.To)
+		}
+		if err := next.Migrate(raw); err != nil {
+			return nil, fmt.Errorf("migrating from version %d to %d: %v",
+				next.From, next.To, err)
+		}
+		version = next.To
+		applied = true
+	}
+	if !applied {
+		return src, nil
+	}
+	if raw[parts[0]] == nil {
+		raw[parts[0]] = make(map[string][]string)
+	}
+	raw[parts[0]][parts[1]] = []string{strconv.Itoa(version)}
+	return raw.encode(sections, names), nil
+}
+
+// rawConfigFromEntries collects entries into a RawConfig, along with the
+// order in which sections and the variables in each section first appear.
+func rawConfigFromEntries(entries []gcfgEntry) (RawConfig, []string, map[string][]string) {
+	raw := make(RawConfig)
+	var sections []string
+	names := make(map[string][]string)
+	for _, e := range entries {
+		key := strings.ToLower(e.Section)
+		if e.Subsection != "" {
+			key += "." + e.Subsection
+		}
+		if raw[key] == nil {
+			raw[key] = make(map[string][]string)
+			sections = append(sections, key)
+		}
+		if e.Name == "" {
+			continue
+		}
+		name := strings.ToLower(e.Name)
+		if _, found := raw[key][name]; !found {
+			names[key] = append(names[key], name)
+		}
+		val := e.Value
+		if e.Blank {
+			val = "true"
+		}
+		raw[key][name] = append(raw[key][name], val)
+	}
+	return raw, sections, names
+}
+
+// encode writes raw in gcfg syntax. Sections and variables listed in order
+// come first, in that order, followed by any others in sorted order.
+func (raw RawConfig) encode(order []string, names map[string][]string) []byte {
+	var buf bytes.Buffer
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	for _, key := range orderedKeys(order, keys) {
+		vars := raw[key]
+		parts := strings.SplitN(key, ".", 2)
+		if len(parts) == 2 {
+			fmt.Fprintf(&buf, "[%s %s]\n", parts[0], quoteGcfgValue(parts[1]))
+		} else {
+			fmt.Fprintf(&buf, "[%s]\n", key)
+		}
+		varNames := make([]string, 0, len(vars))
+		for name := range vars {
+			varNames = append(varNames, name)
+		}
+		for _, name := range orderedKeys(names[key], varNames) {
+			for _, val := range vars[name] {
+				fmt.Fprintf(&buf, "%s = %s\n", name, quoteGcfgValue(val))
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// orderedKeys sorts keys so that those that appear in order come first, in
+// that order, followed by the rest in sorted order.
+func orderedKeys(order []string, keys []string) []string {
+	pos := make(map[string]int, len(order))
+	for i, k := range order {
+		pos[k] = i
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		pi, iok := pos[keys[i]]
+		pj, jok := pos[keys[j]]
+		switch {
+		case iok && jok:
+			return pi < pj
+		case iok != jok:
+			return iok
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestMigrations(c *check.C) {
+	type backend struct {
+		Addr []string
+	}
+	type config struct {
+		Meta struct {
+			ConfigVersion int `gcfg:"config-version"`
+		}
+		HTTP struct {
+			Addr  string
+			Debug bool
+		}
+		Backend       map[string]*backend
+		Order_Backend []string
+	}
+	migrations := []Migration{
+		{From: 0, To: 1, Migrate: func(raw RawConfig) error {
+			// [server] listen-addr became [http] addr.
+			if server, ok := raw["server"]; ok {
+				raw["http"] = map[string][]string{"addr": server["listen-addr"]}
+				if debug, ok := server["debug"]; ok {
+					raw["http"]["debug"] = debug
+				}
+				delete(raw, "server")
+			}
+			return nil
+		}},
+		{From: 1, To: 3, Migrate: func(raw RawConfig) error {
+			if v := raw["http"]["addr"]; len(v) > 0 && v[0] == "" {
+				return fmt.Errorf("empty address")
+			}
+			return nil
+		}},
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader(`[backend "b"]
+addr = one
+addr = two
+[server]
+listen-addr = ":8080"
+debug
+[backend "a"]
+`)
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_HTTP_ADDR": ":9090",
+	}, "APPNAME", &cfg, WithMigrations("meta.config-version", migrations...))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Meta.ConfigVersion, check.Equals, 3)
+	c.Check(cfg.HTTP.Addr, check.Equals, ":9090")
+	c.Check(cfg.HTTP.Debug, check.Equals, true)
+	c.Check(cfg.Backend["b"].Addr, check.DeepEquals, []string{"one", "two"})
+	c.Check(cfg.Order_Backend, check.DeepEquals, []string{"b", "a"})
+
+	// Current files are left alone.
+	cfg = config{}
+	r = strings.NewReader("[meta]\nconfig-version = 3\n[server]\nlisten-addr = x\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithMigrations("meta.config-version", migrations...))
+	c.Check(err, check.ErrorMatches, `(?s).*can't store data at section "server".*`)
+
+	cfg = config{}
+	r = strings.NewReader("[meta]\nconfig-version = 1\n[http]\naddr = \"\"\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithMigrations("meta.config-version", migrations...))
+	c.Check(err, check.ErrorMatches, `migrating from version 1 to 3: empty address`)
+
+	cfg = config{}
+	r = strings.NewReader("[meta]\nconfig-version = two\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithMigrations("meta.config-version", migrations...))
+	c.Check(err, check.ErrorMatches, `invalid meta.config-version: "two"`)
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// WithMmap makes ReadFileWithEnvInto map the configuration file into memory
+// instead of reading it into a buffer, which reduces the load time and peak
+// memory use for very large (e.g. generated) files. On platforms without
+// mmap, the file is read as usual. The file must not be truncated while it is
+// being read.
+func WithMmap() Option {
+	return func(o *options) {
+		o.mmap = true
+	}
+}
+
+var errMmapUnsupported = errors.New("mmap is not supported on this platform")
+
+// mappedReader reads a file that has been mapped into memory.
+type mappedReader struct {
+	*bytes.Reader
+	b []byte
+}
+
+// stdin is read for the filename "-". It is a variable for testing.
+var stdin io.Reader = os.Stdin
+
+// openConfigFile opens filename for reading, or stdin if it is "-", or the
+// file in the file system given to ReadFSFileWithEnvInto. The returned close
+// function must be called once it has been read.
+func openConfigFile(filename string, o *options) (io.Reader, func() error, error) {
+	if o.fsys != nil {
+		f, err := o.fsys.Open(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		return f, f.Close, nil
+	}
+	if filename == "-" {
+		return stdin, func() error { return nil }, nil
+	}
+	if o.mmap {
+		b, unmap, err := mmapFile(filename)
+		if err == nil {
+			return &mappedReader{bytes.NewReader(b), b}, unmap, nil
+		}
+		if err != errMmapUnsupported {
+			return nil, nil, err
+		}
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+// readAll returns the contents of r, without copying them if r is a mapped
+// file. The result must not be modified.
+func readAll(r io.Reader) ([]byte, error) {
+	if m, ok := r.(*mappedReader); ok {
+		return m.b, nil
+	}
+	return ioutil.ReadAll(r)
+}
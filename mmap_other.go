@@ -0,0 +1,11 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package gcfgenv
+
+func mmapFile(filename string) ([]byte, func() error, error) {
+	return nil, nil, errMmapUnsupported
+}
@@ -0,0 +1,69 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestMmap(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string
+			Other string
+		}
+	}
+	dir := c.MkDir()
+	cases := []struct {
+		contents string
+		want     string
+	}{
+		{"[sec]\nfield = value\n", "value"},
+		{"\ufeff[sec]\nfield = bom\n", "bom"},
+		{"", ""},
+	}
+	os.Setenv("MMAPTEST_SEC_OTHER", "env")
+	defer os.Unsetenv("MMAPTEST_SEC_OTHER")
+	for i, tc := range cases {
+		filename := filepath.Join(dir, "config.gcfg")
+		c.Assert(ioutil.WriteFile(filename, []byte(tc.contents), 0o600), check.IsNil)
+		for _, opts := range [][]Option{nil, {WithMmap()}, {WithMmap(), WithIniCompat()}} {
+			cfg := config{}
+			err := ReadFileWithEnvInto(filename, "MMAPTEST", &cfg, opts...)
+			c.Check(err, check.IsNil, check.Commentf("case %d", i))
+			c.Check(cfg.Sec.Field, check.Equals, tc.want, check.Commentf("case %d", i))
+			c.Check(cfg.Sec.Other, check.Equals, "env")
+		}
+	}
+
+	cfg := config{}
+	err := ReadFileWithEnvInto(filepath.Join(dir, "missing"), "MMAPTEST", &cfg, WithMmap())
+	c.Check(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *Suite) TestReadStdin(c *check.C) {
+	type config struct {
+		Sec struct{ Field string }
+	}
+	defer func(r io.Reader) { stdin = r }(stdin)
+	for _, opts := range [][]Option{nil, {WithMmap()}} {
+		stdin = strings.NewReader("\ufeff[sec]\nfield = piped\n")
+		cfg := config{}
+		err := ReadFileWithEnvInto("-", "STDINTEST", &cfg, opts...)
+		c.Check(err, check.IsNil)
+		c.Check(cfg.Sec.Field, check.Equals, "piped")
+	}
+
+	// The byte order mark is reported like that of any other file.
+	stdin = strings.NewReader("\ufeff[sec]\nfield = piped\n")
+	cfg := config{}
+	err := ReadFileWithEnvInto("-", "STDINTEST", &cfg, WithStrict())
+	c.Check(err, check.ErrorMatches, "(?s).*byte order mark.*")
+}
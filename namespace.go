@@ -0,0 +1,39 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ApplyNamespace applies the environment overrides under prefix (e.g.
+// "TENANT42_") to config, which should be a copy of a base configuration
+// read once from a file. This allows each tenant to be specialised without
+// reading the file again:
+//
+//	var tenant Config
+//	err := gcfgenv.DeepCopy(&tenant, &base)
+//	...
+//	err = gcfgenv.ApplyNamespace(&tenant, "TENANT42_", opts...)
+//
+// It is equivalent to ApplyEnvInto(prefix, config, opts...).
+func ApplyNamespace(config interface{}, prefix string, opts ...Option) error {
+	return ApplyEnvInto(prefix, config, opts...)
+}
+
+// DeepCopy copies the configuration pointed to by src into dst, which must be
+// a pointer of the same type, without sharing any maps, slices, or pointers.
+// See ApplyNamespace.
+func DeepCopy(dst, src interface{}) error {
+	d, s := reflect.ValueOf(dst), reflect.ValueOf(src)
+	if d.Kind() != reflect.Ptr || d.IsNil() || s.Kind() != reflect.Ptr || s.IsNil() {
+		return fmt.Errorf("dst and src must be non-nil pointers")
+	}
+	if d.Type() != s.Type() {
+		return fmt.Errorf("cannot copy %s into %s", s.Type(), d.Type())
+	}
+	d.Elem().Set(deepCopy(s.Elem()))
+	return nil
+}
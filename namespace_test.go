@@ -0,0 +1,74 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"os"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestNamespaces(c *check.C) {
+	type config struct {
+		Server struct {
+			Listen string
+			Tags   []string
+		}
+		Pool       map[string]*struct{ Size int }
+		Order_Pool []string
+	}
+	src := `
+[server]
+listen = :80
+tags = a
+[pool "b"]
+size = 1
+[pool "a"]
+size = 2
+`
+	base := config{}
+	err := readWithMapInto(strings.NewReader(src), map[string]string{}, "", &base)
+	c.Assert(err, check.IsNil)
+
+	env := map[string]string{
+		"T1_SERVER_LISTEN": ":81",
+		"T1_SERVER_TAGS":   "t1",
+		"T1_POOL_b_SIZE":   "10",
+		"T1_POOL_c_SIZE":   "3",
+		"T2_SERVER_LISTEN": ":82",
+	}
+	t1 := config{}
+	c.Assert(DeepCopy(&t1, &base), check.IsNil)
+	c.Assert(applyWithMapInto(env, "T1", &t1), check.IsNil)
+	c.Check(t1.Server.Listen, check.Equals, ":81")
+	c.Check(t1.Server.Tags, check.DeepEquals, []string{"a", "t1"})
+	c.Check(t1.Pool["b"].Size, check.Equals, 10)
+	c.Check(t1.Pool["c"].Size, check.Equals, 3)
+	c.Check(t1.Order_Pool, check.DeepEquals, []string{"b", "a", "c"})
+
+	t2 := config{}
+	c.Assert(DeepCopy(&t2, &base), check.IsNil)
+	c.Assert(applyWithMapInto(env, "T2_", &t2), check.IsNil)
+	c.Check(t2.Server.Listen, check.Equals, ":82")
+	c.Check(t2.Pool, check.HasLen, 2)
+
+	os.Setenv("NSTEST42_SERVER_LISTEN", ":83")
+	defer os.Unsetenv("NSTEST42_SERVER_LISTEN")
+	t3 := config{}
+	c.Assert(DeepCopy(&t3, &base), check.IsNil)
+	c.Assert(ApplyNamespace(&t3, "NSTEST42_"), check.IsNil)
+	c.Check(t3.Server.Listen, check.Equals, ":83")
+	c.Check(t3.Pool, check.HasLen, 2)
+
+	// The base configuration is untouched.
+	c.Check(base.Server.Listen, check.Equals, ":80")
+	c.Check(base.Server.Tags, check.DeepEquals, []string{"a"})
+	c.Check(base.Pool["b"].Size, check.Equals, 1)
+	c.Check(base.Pool, check.HasLen, 2)
+	c.Check(base.Order_Pool, check.DeepEquals, []string{"b", "a"})
+
+	c.Check(applyWithMapInto(env, "T1", base), check.ErrorMatches, "config must be a non-nil pointer to a struct, not .*")
+	c.Check(DeepCopy(&t1, &base.Server), check.ErrorMatches, "cannot copy .* into .*")
+}
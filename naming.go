@@ -0,0 +1,98 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"strings"
+)
+
+// naming holds the rules for converting section and variable names into
+// environment variable names.
+type naming struct {
+	// lower uses lowercase names (including the prefix) instead of
+	// uppercase ones.
+	lower bool
+	// dash replaces dashes in names, if it is not empty.
+	dash string
+}
+
+// defaultNaming is used by EnvVarName and when no naming options are given.
+var defaultNaming = naming{}
+
+// WithLowercaseNames expects environment variable names, including the
+// prefix, to be lowercase (e.g. "appname_sec_k1_other_field") rather than
+// uppercase, for tooling that standardises on lowercase names. Subsection
+// names are still left as-is.
+func WithLowercaseNames() Option {
+	return func(o *options) {
+		o.naming.lower = true
+	}
+}
+
+// WithDashReplacement replaces dashes in section and variable names with r
+// in environment variable names, instead of Separator. This avoids collisions
+// between e.g. "another-name" and "another_name", and r may be "-" to keep
+// dashes for sources that allow them (such as Consul KV).
+func WithDashReplacement(r string) Option {
+	return func(o *options) {
+		o.naming.dash = r
+	}
+}
+
+// name converts a section or variable name to its part of an environment
+// variable name.
+func (n naming) name(s string) string {
+	// By default, dashes are replaced for consistency with Go field
+	// names, which cannot contain them.
+	dash := n.dash
+	if dash == "" {
+		dash = Separator
+	}
+	s = strings.ReplaceAll(s, "-", dash)
+	if n.lower {
+		return strings.ToLower(s)
+	}
+	return strings.ToUpper(s)
+}
+
+// field returns the part of an environment variable name for a section or
+// variable field.
+func (n naming) field(sf reflect.StructField) string {
+	return n.name(fieldSectionName(sf))
+}
+
+// pinnedEnvVar returns the environment variable name given in the field's
+// "env" struct tag, if any. Such names are used as-is, without the prefix or
+// section name, so that fields can keep legacy names that do not match the
+// layout of the config struct. They only apply to fields of plain sections.
+func pinnedEnvVar(sf reflect.StructField) string {
+	return sf.Tag.Get("env")
+}
+
+// prefix returns the prefix of all environment variable names, ending in
+// Separator if it is not empty.
+func (n naming) prefix(prefix string) string {
+	if n.lower {
+		prefix = strings.ToLower(prefix)
+	}
+	return normalizePrefix(prefix)
+}
+
+// envVarName implements EnvVarName.
+func (n naming) envVarName(prefix string, fieldPath ...string) string {
+	parts := make([]string, 0, len(fieldPath))
+	for i, name := range fieldPath {
+		// Subsection names are left as-is, and the empty subsection
+		// refers to the section itself.
+		if i > 0 && i < len(fieldPath)-1 {
+			if name != "" {
+				parts = append(parts, name)
+			}
+			continue
+		}
+		parts = append(parts, n.name(name))
+	}
+	return n.prefix(prefix) + strings.Join(parts, Separator)
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestLowercaseNames(c *check.C) {
+	type sec struct {
+		Field      string
+		OtherField string `gcfg:"other-field"`
+		DB         DSN
+	}
+	type config struct {
+		Sec    sec
+		Subsec map[string]*sec
+	}
+	src := `
+[sec]
+db = postgres://app@db/main
+[subsec "K1"]
+field = file
+`
+	env := map[string]string{
+		"appname_sec_field":         "a",
+		"appname_sec_other_field":   "b",
+		"appname_sec_db_password":   "secret",
+		"appname_subsec_K1_field":   "c",
+		"appname_subsec_k2_field":   "d",
+		"appname_sec_unknown":       "e",
+		"APPNAME_SEC_FIELD":         "ignored",
+		"APPNAME_SUBSEC_K1_FIELD":   "ignored",
+		"appname_subsec_K1_unknown": "f",
+	}
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(src), env, "APPNAME", &cfg,
+		WithLowercaseNames(), WithStrict())
+	c.Check(err, check.ErrorMatches, `(?s).*unknown environment variable appname_sec_unknown for section "Sec".*`+
+		`unknown environment variable appname_subsec_K1_unknown for section "Subsec", subsection "K1".*`)
+	c.Check(cfg.Sec.Field, check.Equals, "a")
+	c.Check(cfg.Sec.OtherField, check.Equals, "b")
+	c.Check(cfg.Sec.DB.Password, check.Equals, "secret")
+	c.Check(cfg.Subsec["K1"].Field, check.Equals, "c")
+	c.Check(cfg.Subsec["k2"].Field, check.Equals, "d")
+
+	c.Check(defaultNaming.envVarName("APPNAME", "sec", "K1", "other-field"), check.Equals,
+		"APPNAME_SEC_K1_OTHER_FIELD")
+	c.Check(naming{lower: true}.envVarName("APPNAME", "sec", "K1", "other-field"), check.Equals,
+		"appname_sec_K1_other_field")
+}
+
+func (s *Suite) TestDashReplacement(c *check.C) {
+	type sec struct {
+		Another_Name string
+		AnotherName  string `gcfg:"another-name"`
+	}
+	type config struct {
+		Sec    sec `gcfg:"my-sec"`
+		Subsec map[string]*sec
+	}
+	env := map[string]string{
+		"APP_MY-SEC_ANOTHER_NAME":     "underscore",
+		"APP_MY-SEC_ANOTHER-NAME":     "dash",
+		"APP_SUBSEC_a-b_ANOTHER-NAME": "sub",
+	}
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithDashReplacement("-"))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Another_Name, check.Equals, "underscore")
+	c.Check(cfg.Sec.AnotherName, check.Equals, "dash")
+	c.Check(cfg.Subsec["a-b"].AnotherName, check.Equals, "sub")
+	c.Check(cfg.Subsec["a-b"].Another_Name, check.Equals, "")
+
+	cfg = config{}
+	env = map[string]string{"APP_MY__SEC_ANOTHER__NAME": "x"}
+	err = readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithDashReplacement("__"))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.AnotherName, check.Equals, "x")
+}
+
+func (s *Suite) TestPinnedEnvVar(c *check.C) {
+	type sub struct {
+		Field string `env:"IGNORED_FOR_SUBSECTIONS"`
+	}
+	type config struct {
+		Database struct {
+			Password     string `env:"LEGACY_DB_PASS" gcfgenv:"exclusive=password"`
+			PasswordFile string `gcfgenv:"exclusive=password"`
+			Host         string
+		}
+		Sub map[string]*sub
+	}
+	env := map[string]string{
+		"LEGACY_DB_PASS":          "secret",
+		"APP_DATABASE_PASSWORD":   "ignored",
+		"APP_DATABASE_HOST":       "db",
+		"IGNORED_FOR_SUBSECTIONS": "ignored",
+		"APP_SUB_a_FIELD":         "sub",
+	}
+	var cfg config
+	var report Report
+	err := readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithReport(&report))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Database.Password, check.Equals, "secret")
+	c.Check(cfg.Database.Host, check.Equals, "db")
+	c.Check(cfg.Sub["a"].Field, check.Equals, "sub")
+	c.Check(report.Fields[0], check.DeepEquals, FieldReport{
+		Path: "Database.Password", Source: SourceEnv, EnvVar: "LEGACY_DB_PASS",
+	})
+
+	// Errors name the pinned variable.
+	cfg = config{}
+	env["APP_DATABASE_PASSWORDFILE"] = "/run/secrets/db"
+	err = readWithMapInto(strings.NewReader(""), env, "APP", &cfg)
+	c.Check(err, check.ErrorMatches, `only one of .* but found Database\.Password \(from LEGACY_DB_PASS\) and .*`)
+
+	specs, err := EnvVarsFor(&cfg, "APP")
+	c.Assert(err, check.IsNil)
+	c.Check(specs[0].Name, check.Equals, "LEGACY_DB_PASS")
+	c.Check(specs[3].Name, check.Equals, "APP_SUB_<name>_FIELD")
+}
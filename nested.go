@@ -0,0 +1,182 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Sections may have two levels of subsections by using a field of type
+// map[string]map[string]*struct, which gcfg does not support on its own. In
+// the configuration file, the two keys are joined with a '.', e.g.
+//
+//	[sec "region.zone"]
+//
+// and in environment variables they are joined with an '_', as in
+// SEC_region_zone_FIELD. Subsections without a '.' are stored under the empty
+// inner key.
+
+// isNestedSubsectionMap reports whether t is a map[string]map[string]*struct.
+func isNestedSubsectionMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		isSubsectionMap(t.Elem())
+}
+
+// shadowNested returns a copy of the config struct ref in which any nested
+// subsection maps are flattened into a form that gcfg can read, and any
+// map[string]string sections are left out, along with a function that copies
+// the results back into ref. If ref has no such fields, it returns the zero
+// Value.
+func shadowNested(ref reflect.Value) (reflect.Value, func()) {
+	refType := ref.Type()
+	var fields []reflect.StructField
+	var indices []int
+	nested := false
+	for i := 0; i < refType.NumField(); i++ {
+		sf := refType.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		t := sf.Type
+		if isStringMapSection(t) {
+			nested = true
+			continue
+		}
+		if isNestedSubsectionMap(t) {
+			t = t.Elem()
+			nested = true
+		}
+		fields = append(fields, reflect.StructField{
+			Name: sf.Name, Type: t, Tag: sf.Tag,
+		})
+		indices = append(indices, i)
+	}
+	if !nested {
+		return reflect.Value{}, nil
+	}
+	shadow := reflect.New(reflect.StructOf(fields)).Elem()
+	for j, i := range indices {
+		if isNestedSubsectionMap(refType.Field(i).Type) {
+			shadow.Field(j).Set(flattenNested(ref.Field(i)))
+			continue
+		}
+		shadow.Field(j).Set(ref.Field(i))
+	}
+	restore := func() {
+		for j, i := range indices {
+			if isNestedSubsectionMap(refType.Field(i).Type) {
+				ref.Field(i).Set(unflattenNested(shadow.Field(j), refType.Field(i).Type))
+				continue
+			}
+			ref.Field(i).Set(shadow.Field(j))
+		}
+	}
+	return shadow, restore
+}
+
+func flattenNested(m reflect.Value) reflect.Value {
+	if m.IsNil() {
+		return reflect.Zero(m.Type().Elem())
+	}
+	out := reflect.MakeMap(m.Type().Elem())
+	iter := m.MapRange()
+	for iter.Next() {
+		outer := iter.Key().String()
+		inner := iter.Value().MapRange()
+		for inner.Next() {
+			key := outer
+			if inner.Key().String() != "" {
+				key += "." + inner.Key().String()
+			}
+			out.SetMapIndex(reflect.ValueOf(key), inner.Value())
+		}
+	}
+	return out
+}
+
+func unflattenNested(flat reflect.Value, t reflect.Type) reflect.Value {
+	if flat.IsNil() {
+		return reflect.Zero(t)
+	}
+	out := reflect.MakeMap(t)
+	iter := flat.MapRange()
+	for iter.Next() {
+		parts := strings.SplitN(iter.Key().String(), ".", 2)
+		if len(parts) == 1 {
+			parts = append(parts, "")
+		}
+		outer := reflect.ValueOf(parts[0])
+		inner := out.MapIndex(outer)
+		if !inner.IsValid() {
+			inner = reflect.MakeMap(t.Elem())
+			out.SetMapIndex(outer, inner)
+		}
+		inner.SetMapIndex(reflect.ValueOf(parts[1]), iter.Value())
+	}
+	return out
+}
+
+// setNestedSubsectionsWithEnvMap applies overrides from env to sec, a
+// map[string]map[string]*struct, creating new subsections as needed.
+//
+// Each variable is assigned to the longest existing outer key it matches, or
+// failing that, to an outer key made up of everything up to the next '_'.
+// Outer keys containing underscores can therefore only be created in the
+// configuration file.
+func setNestedSubsectionsWithEnvMap(st *applyState, sec reflect.Value, secPrefix string, defaults reflect.Value, env *envIndex) error {
+	var existing []string
+	iter := sec.MapRange()
+	for iter.Next() {
+		existing = append(existing, iter.Key().String())
+	}
+	sort.Slice(existing, func(i, j int) bool {
+		return len(existing[i]) > len(existing[j])
+	})
+	groups := make(map[string]map[string]string)
+	for _, e := range env.withPrefix(secPrefix + "_") {
+		v := env.vars[e]
+		rest := strings.TrimPrefix(e, secPrefix+"_")
+		outer := ""
+		for _, k := range existing {
+			if strings.HasPrefix(rest, k+"_") {
+				outer = k
+				break
+			}
+		}
+		if outer == "" {
+			i := strings.Index(rest, "_")
+			if i <= 0 {
+				continue
+			}
+			outer = rest[:i]
+		}
+		if groups[outer] == nil {
+			groups[outer] = make(map[string]string)
+		}
+		groups[outer][e] = v
+	}
+	var errs Errors
+	for outer, group := range groups {
+		key := reflect.ValueOf(outer)
+		inner := reflect.New(sec.Type().Elem()).Elem()
+		if existing := sec.MapIndex(key); existing.IsValid() {
+			inner.Set(existing)
+		}
+		err := setSubsectionsWithEnvMap(st, inner, secPrefix+"_"+outer, defaults, newEnvIndex(group, ""))
+		if err != nil {
+			errs = errs.appendError(withPath(err, outer))
+			continue
+		}
+		if inner.Len() == 0 {
+			continue
+		}
+		if sec.IsNil() {
+			sec.Set(reflect.MakeMap(sec.Type()))
+		}
+		sec.SetMapIndex(key, inner)
+	}
+	return errs.err()
+}
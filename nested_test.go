@@ -0,0 +1,72 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestNestedSubsections(c *check.C) {
+	type zone struct {
+		F1 string
+		F2 int
+	}
+	type config struct {
+		Topology         map[string]map[string]*zone
+		Default_Topology zone
+		private          int
+	}
+
+	var err error
+	configString := `[topology]
+f1 = global
+
+[topology "us-east.a"]
+f1 = file
+
+[topology "us_west.b"]
+f1 = file
+
+[topology "eu"]
+f1 = region
+`
+	configEnvVars := map[string]string{
+		"TOPOLOGY_us-east_a_F2":  "1",
+		"TOPOLOGY_us-east_b_F1":  "env",
+		"TOPOLOGY_us_west_b_F2":  "2",
+		"TOPOLOGY_us_west_c_F2":  "3",
+		"TOPOLOGY_ap-south_a_F1": "env",
+		"TOPOLOGY_eu_F2":         "4",
+	}
+
+	cfg := config{Default_Topology: zone{F2: -1}, private: 1}
+	r := strings.NewReader(configString)
+	err = readWithMapInto(r, configEnvVars, "", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{
+		Topology: map[string]map[string]*zone{
+			"": {
+				"": {F1: "global", F2: -1},
+			},
+			"us-east": {
+				"a": {F1: "file", F2: 1},
+				"b": {F1: "env", F2: -1},
+			},
+			"us_west": {
+				"b": {F1: "file", F2: 2},
+				"c": {F2: 3},
+			},
+			"ap-south": {
+				"a": {F1: "env", F2: -1},
+			},
+			"eu": {
+				"": {F1: "region", F2: 4},
+			},
+		},
+		Default_Topology: zone{F2: -1},
+		private:          1,
+	})
+}
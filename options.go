@@ -0,0 +1,109 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// An Option configures optional behaviour of the functions in this package.
+type Option func(*options)
+
+type options struct {
+	iniCompat         bool
+	reservedPrefixes  []string
+	strict            bool
+	strictSubsections bool
+	limitSubsections  bool
+	maxNewSubsections int
+	required          [][]string
+	varsSection       string
+	interpolate       bool
+	sliceMode         SliceMode
+	customDelimiter   bool
+	sliceDelimiter    string
+	envFilesVar       string
+	fileVars          bool
+	location          *time.Location
+	utf8Mode          UTF8Mode
+	exclusive         [][]string
+	references        [][2]string
+	versionKey        string
+	migrations        []Migration
+	boolValues        []string
+	generators        bool
+	generated         map[string]string
+	streamSection     string
+	streamFunc        SubsectionFunc
+	parallelism       int
+	naming            naming
+	prefixVar         string
+	prefixVars        map[string]string
+	requirePrefix     bool
+	mmap              bool
+	fsys              fs.FS
+	report            *Report
+	errorCodes        bool
+	cache             *Cache
+}
+
+var (
+	defaultOptionsMu sync.RWMutex
+	defaultOptions   []Option
+)
+
+// SetDefaultOptions sets options that apply to every later call in this
+// package, before any options passed to the call itself, so that e.g. naming
+// or strictness can be configured once at startup. It replaces any previous
+// defaults, and is safe for concurrent use.
+func SetDefaultOptions(opts ...Option) {
+	defaultOptionsMu.Lock()
+	defer defaultOptionsMu.Unlock()
+	defaultOptions = append([]Option(nil), opts...)
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	defaultOptionsMu.RLock()
+	for _, opt := range defaultOptions {
+		opt(o)
+	}
+	defaultOptionsMu.RUnlock()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithIniCompat pre-processes the configuration file to accept common
+// constructs from other INI dialects that gcfg would otherwise reject, such as
+// single-quoted values, literal backslashes, and ':' separators.
+func WithIniCompat() Option {
+	return func(o *options) {
+		o.iniCompat = true
+	}
+}
+
+// WithReservedPrefixes ignores any environment variables that begin with one
+// of the given prefixes (e.g. "APPNAME_INTERNAL_"), so that they are never
+// interpreted as overrides even when they would otherwise match a section.
+// The prefixes are matched against complete variable names, including any
+// prefix passed to the read functions.
+func WithReservedPrefixes(prefixes ...string) Option {
+	return func(o *options) {
+		o.reservedPrefixes = append(o.reservedPrefixes, prefixes...)
+	}
+}
+
+// WithParallelism applies environment overrides to the subsections of large
+// sections using up to n goroutines, which can speed up reading files with
+// thousands of subsections. Any encoding.TextUnmarshaler implementations and
+// transforms used by subsection fields must be safe for concurrent use.
+func WithParallelism(n int) Option {
+	return func(o *options) {
+		o.parallelism = n
+	}
+}
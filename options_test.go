@@ -0,0 +1,105 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestDefaultOptions(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string
+		}
+	}
+	SetDefaultOptions(WithLowercaseNames(), WithStrict())
+	defer SetDefaultOptions()
+
+	env := map[string]string{
+		"app_sec_field": "lower",
+		"app_sec_other": "unknown",
+		"APP_SEC_FIELD": "upper",
+	}
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(""), env, "APP", &cfg)
+	c.Check(err, check.ErrorMatches, "(?s).*unknown environment variable app_sec_other.*")
+	c.Check(cfg.Sec.Field, check.Equals, "lower")
+
+	// Options passed to the call are applied after the defaults.
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithRequirePrefix(),
+		func(o *options) { o.naming.lower = false })
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Sec.Field, check.Equals, "upper")
+
+	// Setting the defaults again replaces them, and can race with reads.
+	done := make(chan bool)
+	go func() {
+		SetDefaultOptions(WithStrict())
+		done <- true
+	}()
+	_ = readWithMapInto(strings.NewReader(""), env, "APP", &config{})
+	<-done
+	SetDefaultOptions()
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), env, "APP", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Sec.Field, check.Equals, "upper")
+}
+
+func (s *Suite) TestParallelism(c *check.C) {
+	type tenant struct {
+		Name  string
+		Quota int
+	}
+	type config struct {
+		Tenant map[string]*tenant
+	}
+	src := benchmarkTenants(1000)
+	env := make(map[string]string)
+	for i := 0; i < 1000; i += 3 {
+		env[fmt.Sprintf("APPNAME_TENANT_t%d_QUOTA", i)] = "-1"
+	}
+	env["APPNAME_TENANT_new_NAME"] = "created"
+
+	cfg := config{}
+	err := readWithMapInto(bytes.NewReader(src), env, "APPNAME", &cfg, WithParallelism(8),
+		WithStrict())
+	c.Check(err, check.ErrorMatches, `(?s)warnings:\n.*creates new subsection "new"\n`)
+	c.Assert(cfg.Tenant, check.HasLen, 1001)
+	for i := 0; i < 1000; i++ {
+		want := i
+		if i%3 == 0 {
+			want = -1
+		}
+		c.Check(cfg.Tenant[fmt.Sprintf("t%d", i)].Quota, check.Equals, want)
+	}
+	c.Check(cfg.Tenant["new"].Name, check.Equals, "created")
+
+	cfg = config{}
+	env["APPNAME_TENANT_t500_QUOTA"] = "many"
+	err = readWithMapInto(bytes.NewReader(src), env, "APPNAME", &cfg, WithParallelism(8))
+	c.Check(err, check.ErrorMatches, `cannot set Tenant\.t500\.Quota from APPNAME_TENANT_t500_QUOTA="many": failed to parse "many" as int: .*`)
+}
+
+func BenchmarkTenantsParallel(b *testing.B) {
+	src := benchmarkTenants(5000)
+	env := make(map[string]string)
+	for i := 0; i < 5000; i++ {
+		env[fmt.Sprintf("APPNAME_TENANT_t%d_QUOTA", i)] = "1"
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cfg := benchConfig{}
+		err := readWithMapInto(bytes.NewReader(src), env, "APPNAME", &cfg, WithParallelism(4))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"sort"
+)
+
+// orderFieldPrefix is the prefix of optional []string fields that record the
+// order of the subsections of a section. Following gcfg's Default_ convention,
+// the field for a section stored in the field Sec is called Order_Sec.
+//
+// Subsections are listed in the order they are first declared in the
+// configuration file, followed by any others (e.g. those created by
+// environment variables, or set before reading) in sorted order.
+const orderFieldPrefix = "Order_"
+
+var orderFieldType = reflect.TypeOf([]string{})
+
+// fillSubsectionOrder populates any Order_ fields of ref from the section
+// headers in src and the current contents of the corresponding maps. If src is
+// nil, the existing order is kept instead, and extended with any new
+// subsections.
+func fillSubsectionOrder(ref reflect.Value, src []byte) {
+	refType := ref.Type()
+	var entries []gcfgEntry
+	for i := 0; i < refType.NumField(); i++ {
+		sf := refType.Field(i)
+		if !sf.IsExported() || !isSubsectionMap(sf.Type) {
+			continue
+		}
+		order := ref.FieldByName(orderFieldPrefix + sf.Name)
+		if !order.IsValid() || !order.CanSet() || order.Type() != orderFieldType {
+			continue
+		}
+		var keys []string
+		seen := make(map[string]bool)
+		if src == nil {
+			// Keep the existing order of any remaining subsections.
+			for _, k := range order.Interface().([]string) {
+				if ref.Field(i).MapIndex(reflect.ValueOf(k)).IsValid() && !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+		if entries == nil && src != nil {
+			entries = scanGcfg(src)
+		}
+		for _, e := range entries {
+			if e.Name != "" || seen[e.Subsection] ||
+				sectionFieldIndex(refType, e.Section) != i {
+				continue
+			}
+			seen[e.Subsection] = true
+			keys = append(keys, e.Subsection)
+		}
+		var rest []string
+		iter := ref.Field(i).MapRange()
+		for iter.Next() {
+			if k := iter.Key().String(); !seen[k] {
+				rest = append(rest, k)
+			}
+		}
+		sort.Strings(rest)
+		order.Set(reflect.ValueOf(append(keys, rest...)))
+	}
+}
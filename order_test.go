@@ -0,0 +1,53 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestSubsectionOrder(c *check.C) {
+	type route struct {
+		Target string
+	}
+	type config struct {
+		Route       map[string]*route `gcfg:"routes"`
+		Order_Route []string
+	}
+
+	var err error
+	configString := `[routes "zeta"]
+target = z
+
+[routes "alpha"]
+target = a
+
+[routes]
+target = default
+
+[routes "mid"]
+target = m
+
+[routes "zeta"]
+target = z2
+`
+	configEnvVars := map[string]string{
+		"ROUTES_new2_TARGET": "n2",
+		"ROUTES_new1_TARGET": "n1",
+	}
+
+	cfg := config{
+		Route:       map[string]*route{"preset": {"p"}},
+		Order_Route: []string{"stale"},
+	}
+	r := strings.NewReader(configString)
+	err = readWithMapInto(r, configEnvVars, "", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Order_Route, check.DeepEquals, []string{
+		"zeta", "alpha", "", "mid", "new1", "new2", "preset",
+	})
+	c.Check(cfg.Route, check.HasLen, 7)
+}
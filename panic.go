@@ -0,0 +1,68 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A PanicError reports a panic recovered while reading a configuration, e.g.
+// from an UnmarshalText method or an unusual config struct, so that a
+// malformed config type (such as one supplied by a plugin) cannot crash the
+// program.
+type PanicError struct {
+	// Path is the field being set or checked when the panic occurred,
+	// made up of field names (or their gcfg tags) and subsection names,
+	// e.g. "Server.main.Listen". It is empty if the field is not known.
+	Path string
+	// Value is the value passed to panic.
+	Value interface{}
+}
+
+func (e *PanicError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("panic while reading configuration: %v", e.Value)
+	}
+	return fmt.Sprintf("panic while reading configuration for %s: %v", e.Path, e.Value)
+}
+
+// recoverPanic stores a *PanicError for path in *err if the calling function
+// is panicking. It must be deferred directly, e.g.
+//
+//	defer recoverPanic(&err, "")
+func recoverPanic(err *error, path string) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Path: path, Value: r}
+	}
+}
+
+// withPath prefixes the path of a *PanicError or *ValueError with the names
+// of the enclosing sections or subsections, including those in Errors. Other
+// errors are returned unchanged.
+func withPath(err error, names ...string) error {
+	switch e := err.(type) {
+	case Errors:
+		out := make(Errors, len(e))
+		for i := range e {
+			out[i] = withPath(e[i], names...)
+		}
+		return out
+	case *PanicError:
+		return &PanicError{Path: joinPath(names, e.Path), Value: e.Value}
+	case *ValueError:
+		out := *e
+		out.Path = joinPath(names, e.Path)
+		return &out
+	}
+	return err
+}
+
+func joinPath(names []string, path string) string {
+	prefix := strings.Join(names, ".")
+	if path == "" {
+		return prefix
+	}
+	return prefix + "." + path
+}
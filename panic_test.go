@@ -0,0 +1,89 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+// panicky panics when unmarshalled from "boom".
+type panicky string
+
+func (p *panicky) UnmarshalText(text []byte) error {
+	if string(text) == "boom" {
+		panic("boom")
+	}
+	*p = panicky(text)
+	return nil
+}
+
+// panickySection panics when set as a whole.
+type panickySection struct {
+	Field string
+}
+
+func (p *panickySection) UnmarshalText(text []byte) error {
+	panic("whole section")
+}
+
+func (s *Suite) TestPanicRecovery(c *check.C) {
+	type sub struct {
+		Value panicky
+	}
+	type config struct {
+		Sec     sub
+		Whole   panickySection
+		Sub     map[string]*sub
+		Nested  map[string]map[string]*sub
+		Checked struct {
+			Value string `gcfgenv:"transform=panic"`
+		}
+	}
+	RegisterTransform("panic", func(s string) (string, error) {
+		if s == "boom" {
+			panic("transform")
+		}
+		return s, nil
+	})
+
+	cases := []struct {
+		src  string
+		env  map[string]string
+		want string
+	}{
+		{"", map[string]string{"APP_SEC_VALUE": "boom"},
+			"panic while reading configuration for Sec.Value: boom"},
+		{"", map[string]string{"APP_WHOLE": "x"},
+			"panic while reading configuration for Whole: whole section"},
+		{"[sub \"a\"]\nvalue = ok\n", map[string]string{"APP_SUB_a_VALUE": "boom"},
+			"panic while reading configuration for Sub.a.Value: boom"},
+		{"", map[string]string{"APP_SUB_b_VALUE": "boom"},
+			"panic while reading configuration for Sub.b.Value: boom"},
+		{"", map[string]string{"APP_NESTED_x_y_VALUE": "boom"},
+			"panic while reading configuration for Nested.x.y.Value: boom"},
+		{"[checked]\nvalue = boom\n", map[string]string{},
+			"panic while reading configuration for Checked.Value: transform"},
+	}
+	for _, tc := range cases {
+		cfg := config{}
+		err := readWithMapInto(strings.NewReader(tc.src), tc.env, "APP", &cfg)
+		c.Check(err, check.ErrorMatches, tc.want, check.Commentf("%v", tc.env))
+		_, ok := err.(*PanicError)
+		c.Check(ok, check.Equals, true)
+	}
+
+	// Panics on other goroutines are recovered too.
+	var src bytes.Buffer
+	for i := 0; i < 4*minKeysPerShard; i++ {
+		fmt.Fprintf(&src, "[sub \"t%d\"]\nvalue = ok\n", i)
+	}
+	cfg := config{}
+	err := readWithMapInto(&src, map[string]string{"APP_SUB_t200_VALUE": "boom"}, "APP", &cfg,
+		WithParallelism(4))
+	c.Check(err, check.ErrorMatches, "panic while reading configuration for Sub.t200.Value: boom")
+}
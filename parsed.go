@@ -0,0 +1,85 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"io"
+	"os"
+
+	"gopkg.in/gcfg.v1"
+)
+
+// Parsed is a configuration file that has been read and checked by
+// ParseConfig, which can then be applied, with different environments, to
+// any number of config structs.
+type Parsed struct {
+	src []byte
+}
+
+// ParseConfig reads the gcfg-formatted data in r and checks its syntax,
+// without setting any config struct, so that the result can be kept and
+// applied later (or several times) with Parsed.ApplyEnv. opts are only used
+// to check the syntax, e.g. WithIniCompat, and must be passed to ApplyEnv as
+// well.
+func ParseConfig(r io.Reader, opts ...Option) (*Parsed, error) {
+	src, err := readAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// The data in a mapped file is only valid until it is unmapped.
+	src = append([]byte(nil), src...)
+	o := newOptions(opts)
+	check, _ := skipBOM(src)
+	if o.iniCompat {
+		check = iniCompat(check)
+	}
+	// Every section is unknown to an empty struct, but that is only a
+	// warning.
+	err = gcfg.FatalOnly(gcfg.ReadInto(&struct{}{}, bytes.NewReader(check)))
+	if err != nil {
+		return nil, fileError(err)
+	}
+	return &Parsed{src: src}, nil
+}
+
+// ParseConfigFile is like ParseConfig, but reads the file at filename (or the
+// standard input, if it is "-"), along with any files for the environment
+// given by WithEnvironmentFiles.
+//
+// Since the files are read and kept in full, ParseConfigFile can be used by
+// daemons that must drop privileges (e.g. with setuid) before they finish
+// initializing: they parse files that only root can read, and capture the
+// environment with Environ, before dropping privileges, then call ApplyEnv
+// afterwards. Nothing is read from the file system after ParseConfigFile
+// returns.
+func ParseConfigFile(filename string, opts ...Option) (*Parsed, error) {
+	o := newOptions(opts)
+	if o.envFilesVar != "" && filename != "-" {
+		src, err := readLayeredFiles(filename, os.Getenv(o.envFilesVar), o)
+		if err != nil {
+			return nil, err
+		}
+		return ParseConfig(bytes.NewReader(src), opts...)
+	}
+	f, closeFile, err := openConfigFile(filename, o)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFile()
+	return ParseConfig(f, opts...)
+}
+
+// ApplyEnv sets the values from p in the corresponding fields of config, then
+// applies overrides from env (keyed by variable name, e.g. from the process's
+// environment with Environ) as ReadWithEnvMapInto does.
+func (p *Parsed) ApplyEnv(env map[string]string, envPrefix string, config interface{}, opts ...Option) error {
+	return readWithMapInto(bytes.NewReader(p.src), env, envPrefix, config, opts...)
+}
+
+// Environ returns the process's environment variables as a map, for
+// Parsed.ApplyEnv and ReadWithEnvMapInto.
+func Environ() map[string]string {
+	return mapFromEnviron(os.Environ())
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestParseConfig(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string
+			Other string
+		}
+	}
+	p, err := ParseConfig(strings.NewReader("\ufeff[sec]\nfield = file\nother = file\n"))
+	c.Assert(err, check.IsNil)
+
+	// The same file can be applied with different environments.
+	var a, b config
+	err = p.ApplyEnv(map[string]string{"APP_SEC_FIELD": "a"}, "APP", &a)
+	c.Assert(err, check.IsNil)
+	err = p.ApplyEnv(map[string]string{"APP_SEC_FIELD": "b"}, "APP", &b)
+	c.Assert(err, check.IsNil)
+	c.Check(a.Sec.Field, check.Equals, "a")
+	c.Check(b.Sec.Field, check.Equals, "b")
+	c.Check(b.Sec.Other, check.Equals, "file")
+
+	// Syntax errors are found by ParseConfig.
+	_, err = ParseConfig(strings.NewReader("[sec\n"))
+	c.Check(err, check.NotNil)
+	c.Check(ErrorCode(err), check.Equals, CodeFile)
+	_, err = ParseConfig(strings.NewReader("[sec]\nfield = a ; comment\n"))
+	c.Check(err, check.IsNil)
+	_, err = ParseConfig(strings.NewReader("[sec]\nfield: a\n"))
+	c.Check(err, check.NotNil)
+	_, err = ParseConfig(strings.NewReader("[sec]\nfield: a\n"), WithIniCompat())
+	c.Check(err, check.IsNil)
+
+	// Errors that depend on the config struct are found by ApplyEnv.
+	p, err = ParseConfig(strings.NewReader("[sec]\nunknown = x\n"))
+	c.Assert(err, check.IsNil)
+	err = p.ApplyEnv(nil, "APP", &a)
+	c.Check(err, check.ErrorMatches, `(?s).*can.t store data at section "sec", variable "unknown".*`)
+}
+
+func (s *Suite) TestParseConfigFile(c *check.C) {
+	type config struct {
+		Sec struct{ Field string }
+	}
+	filename := filepath.Join(c.MkDir(), "config.gcfg")
+	c.Assert(ioutil.WriteFile(filename, []byte("[sec]\nfield = file\n"), 0o600), check.IsNil)
+	for _, opts := range [][]Option{nil, {WithMmap()}} {
+		p, err := ParseConfigFile(filename, opts...)
+		c.Assert(err, check.IsNil)
+		var cfg config
+		c.Assert(p.ApplyEnv(Environ(), "PARSEDTEST", &cfg), check.IsNil)
+		c.Check(cfg.Sec.Field, check.Equals, "file")
+	}
+	_, err := ParseConfigFile(filepath.Join(c.MkDir(), "missing"))
+	c.Check(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *Suite) TestParseConfigFileLayers(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string
+			Other string
+		}
+	}
+	dir := c.MkDir()
+	filename := filepath.Join(dir, "config.gcfg")
+	c.Assert(ioutil.WriteFile(filename, []byte("[sec]\nfield = file\nother = file\n"), 0o600), check.IsNil)
+	local := filepath.Join(dir, "config.local.gcfg")
+	c.Assert(ioutil.WriteFile(local, []byte("[sec]\nfield = local\n"), 0o600), check.IsNil)
+	os.Setenv("PARSEDTEST_SEC_OTHER", "env")
+	defer os.Unsetenv("PARSEDTEST_SEC_OTHER")
+
+	p, err := ParseConfigFile(filename, WithEnvironmentFiles("PARSEDTEST_ENV"))
+	c.Assert(err, check.IsNil)
+	env := Environ()
+	// Later changes to the files and the environment (e.g. once privileges
+	// have been dropped) make no difference.
+	c.Assert(os.Remove(filename), check.IsNil)
+	c.Assert(os.Remove(local), check.IsNil)
+	os.Setenv("PARSEDTEST_SEC_OTHER", "changed")
+	var cfg config
+	c.Assert(p.ApplyEnv(env, "PARSEDTEST", &cfg), check.IsNil)
+	c.Check(cfg.Sec.Field, check.Equals, "local")
+	c.Check(cfg.Sec.Other, check.Equals, "env")
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A Percent is a proportion, such as a sampling rate, stored as a fraction:
+// 15% is 0.15. It can be given in the file or environment as a percentage
+// ("15%") or as a fraction ("0.15"). Fields with a gcfgenv:"bare=percent"
+// directive take numbers without a "%" as percentages instead, so that "15"
+// is also 15%; gcfgenv:"bare=fraction" is the default.
+type Percent float64
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *Percent) UnmarshalText(text []byte) error {
+	s := strings.TrimSpace(string(text))
+	scale := 1.0
+	if strings.HasSuffix(s, "%") {
+		s = strings.TrimSpace(strings.TrimSuffix(s, "%"))
+		scale = 100
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("invalid percentage %q", text)
+	}
+	*p = Percent(f / scale)
+	return nil
+}
+
+func (p Percent) String() string {
+	return strconv.FormatFloat(float64(p)*100, 'f', -1, 64) + "%"
+}
+
+var percentType = reflect.TypeOf(Percent(0))
+
+// isPercentType returns true for Percent, and for slices of and pointers to
+// it.
+func isPercentType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return derefType(t) == percentType
+}
+
+// barePercents returns true if numbers without a "%" are percentages for the
+// Percent field sf.
+func barePercents(sf reflect.StructField) (bool, error) {
+	if !isPercentType(sf.Type) {
+		return false, nil
+	}
+	bare, ok := parseFieldTag(sf)["bare"]
+	switch {
+	case !ok || bare == "fraction":
+		return false, nil
+	case bare == "percent":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid bare directive on %s: %q", sf.Name, bare)
+}
+
+// percentValues adds a "%" to numbers in val, which is to be converted to
+// the field sf, if they are percentages, separated by delim.
+func percentValues(sf reflect.StructField, val, delim string) (string, error) {
+	bare, err := barePercents(sf)
+	if err != nil || !bare {
+		return val, err
+	}
+	return rewriteEntries(sf, val, delim, func(s string) string {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil {
+			return s
+		}
+		return strings.TrimSpace(s) + "%"
+	}), nil
+}
+
+// filePercents adds a "%" to numbers in src for Percent fields of the config
+// struct type refType that take them as percentages, so that they are not
+// read as fractions.
+func filePercents(refType reflect.Type, src []byte) ([]byte, error) {
+	match := func(sf reflect.StructField) bool {
+		_, ok := parseFieldTag(sf)["bare"]
+		return ok && isPercentType(sf.Type)
+	}
+	return rewriteFileValues(refType, src, match, func(sf reflect.StructField, val string) (string, error) {
+		return percentValues(sf, val, "")
+	})
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestPercent(c *check.C) {
+	var p Percent
+	for text, want := range map[string]Percent{
+		"15%":   0.15,
+		" 15 %": 0.15,
+		"0.15":  0.15,
+		"150%":  1.5,
+		"0":     0,
+	} {
+		c.Check(p.UnmarshalText([]byte(text)), check.IsNil)
+		c.Check(p, check.Equals, want, check.Commentf("%q", text))
+	}
+	c.Check(p.UnmarshalText([]byte("15 percent")), check.ErrorMatches, `invalid percentage "15 percent"`)
+	c.Check(p.UnmarshalText([]byte("NaN")), check.ErrorMatches, `invalid percentage "NaN"`)
+	c.Check(Percent(0.15).String(), check.Equals, "15%")
+
+	type config struct {
+		Tracing struct {
+			SampleRate Percent   `gcfg:"sample-rate"`
+			MaxCPU     Percent   `gcfg:"max-cpu" gcfgenv:"bare=percent"`
+			Steps      []Percent `gcfgenv:"bare=percent"`
+			Memory     *Percent  `gcfgenv:"bare=percent"`
+		}
+	}
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(`[tracing]
+sample-rate = 0.05
+max-cpu = 80
+steps = 10
+steps = 0.5%
+`), map[string]string{
+		"APPNAME_TRACING_STEPS":  "25,50%",
+		"APPNAME_TRACING_MEMORY": "75",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Tracing.SampleRate, check.Equals, Percent(0.05))
+	c.Check(cfg.Tracing.MaxCPU, check.Equals, Percent(0.8))
+	c.Check(cfg.Tracing.Steps, check.DeepEquals, []Percent{0.1, 0.005, 0.25, 0.5})
+	c.Assert(cfg.Tracing.Memory, check.NotNil)
+	c.Check(*cfg.Tracing.Memory, check.Equals, Percent(0.75))
+
+	type badConfig struct {
+		Sec struct {
+			Rate Percent `gcfgenv:"bare=points"`
+		}
+	}
+	err = readWithMapInto(strings.NewReader("[sec]\nrate = 5\n"), nil, "APPNAME", &badConfig{})
+	c.Check(err, check.ErrorMatches, `invalid bare directive on Rate: "points"`)
+}
@@ -0,0 +1,198 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package playground provides an HTTP handler where operators can paste a
+// candidate configuration file and environment variables and see whether they
+// would be accepted, without restarting anything.
+//
+// For example, to serve it on an internal admin listener,
+//
+//	mux.Handle("/config/playground", playground.Handler(
+//		func() interface{} { return &Config{} }, "APPNAME"))
+//
+// The handler only reads what is submitted: it never looks at the process's
+// own environment or configuration files, and it does not keep anything
+// between requests. It does not authenticate anyone, so it should only be
+// served to trusted users.
+package playground
+
+import (
+	"bufio"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/rstudio/gcfgenv"
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/warnings.v0"
+)
+
+// maxBodySize limits the size of a submitted form.
+const maxBodySize = 1 << 20
+
+// A result is the outcome of reading a submitted configuration.
+type result struct {
+	// Errors holds the fatal errors, of which gcfgenv reports at most one.
+	Errors []message
+	// Warnings holds the non-fatal errors.
+	Warnings []message
+	// Fields holds the report of where each field came from.
+	Fields []gcfgenv.FieldReport
+}
+
+// A message is an error or warning with its code, if it has one.
+type message struct {
+	Code gcfgenv.Code
+	Text string
+}
+
+// page is the data for the page template.
+type page struct {
+	File    string
+	Env     string
+	EnvVars []gcfgenv.EnvVarSpec
+	Result  *result
+}
+
+// Handler returns a handler that serves a form for a configuration file and
+// environment variables (one NAME=value per line) on GET, and on POST reads
+// them into a new config struct from newConfig, as ReadWithEnvMapInto would
+// with envPrefix and opts, and shows the errors and warnings along with where
+// each field came from. The form lists the environment variables accepted for
+// the struct, from EnvVarsFor.
+//
+// Values are never shown, other than in the submitted form itself, and errors
+// about fields tagged gcfgenv:"secret" leave their values out as usual.
+func Handler(newConfig func() interface{}, envPrefix string, opts ...gcfgenv.Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars, err := gcfgenv.EnvVarsFor(newConfig(), envPrefix, opts...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		p := page{EnvVars: vars}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			p.File = r.PostForm.Get("file")
+			p.Env = r.PostForm.Get("env")
+			p.Result = evaluate(newConfig(), p.File, p.Env, envPrefix, opts)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := pageTemplate.Execute(w, p); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// evaluate reads file and env into config and collects the outcome.
+func evaluate(config interface{}, file, env, envPrefix string, opts []gcfgenv.Option) *result {
+	var report gcfgenv.Report
+	opts = append(opts[:len(opts):len(opts)], gcfgenv.WithReport(&report))
+	err := gcfgenv.ReadWithEnvMapInto(strings.NewReader(file), parseEnv(env), envPrefix, config, opts...)
+	res := &result{}
+	if fatal := gcfg.FatalOnly(err); fatal != nil {
+		res.Errors = append(res.Errors, newMessage(fatal))
+		return res
+	}
+	var list warnings.List
+	if errors.As(err, &list) {
+		for _, w := range list.Warnings {
+			res.Warnings = append(res.Warnings, newMessage(w))
+		}
+	}
+	res.Fields = report.Fields
+	return res
+}
+
+// newMessage splits err into its code and message. The code is left out of
+// the message if WithErrorCodes added it there.
+func newMessage(err error) message {
+	code := gcfgenv.ErrorCode(err)
+	return message{Code: code, Text: strings.TrimPrefix(err.Error(), string(code)+": ")}
+}
+
+// parseEnv parses env as lines of NAME=value, as in a .env file or the output
+// of env(1). Blank lines, comments and lines without "=" are skipped, and an
+// "export " before the name is allowed.
+func parseEnv(env string) map[string]string {
+	out := make(map[string]string)
+	s := bufio.NewScanner(strings.NewReader(env))
+	s.Buffer(nil, maxBodySize)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			continue
+		}
+		out[strings.TrimSpace(line[:i])] = line[i+1:]
+	}
+	return out
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Configuration playground</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+textarea { width: 100%; font-family: monospace; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; font-family: monospace; }
+.error { color: #a00; }
+.warning { color: #a60; }
+</style>
+</head>
+<body>
+<h1>Configuration playground</h1>
+<form method="post">
+<h2>Configuration file</h2>
+<textarea name="file" rows="15">{{.File}}</textarea>
+<h2>Environment variables</h2>
+<p>One NAME=value per line.</p>
+<textarea name="env" rows="8">{{.Env}}</textarea>
+<p><button type="submit">Check</button></p>
+</form>
+{{with .Result}}
+<h2>Result</h2>
+{{if .Errors}}
+<ul>{{range .Errors}}<li class="error">{{if .Code}}{{.Code}}: {{end}}{{.Text}}</li>{{end}}</ul>
+{{else}}
+<p>The configuration is valid.</p>
+{{end}}
+{{if .Warnings}}
+<h3>Warnings</h3>
+<ul>{{range .Warnings}}<li class="warning">{{if .Code}}{{.Code}}: {{end}}{{.Text}}</li>{{end}}</ul>
+{{end}}
+{{if .Fields}}
+<h3>Fields</h3>
+<table>
+<tr><th>Field</th><th>Source</th><th>Variable</th></tr>
+{{range .Fields}}<tr><td>{{.Path}}</td><td>{{.Source}}</td><td>{{.EnvVar}}</td></tr>
+{{end}}</table>
+{{end}}
+{{end}}
+<h2>Environment variables accepted</h2>
+<table>
+<tr><th>Variable</th><th>Type</th><th>Field</th></tr>
+{{range .EnvVars}}<tr><td>{{.Name}}</td><td>{{.Type}}</td><td>{{.Path}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
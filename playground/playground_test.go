@@ -0,0 +1,85 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package playground
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+type Suite struct{}
+
+func Test(t *testing.T) {
+	_ = check.Suite(&Suite{})
+	check.TestingT(t)
+}
+
+type config struct {
+	Server struct {
+		Listen string
+		Port   int
+	}
+	Db struct {
+		Password string `gcfgenv:"secret"`
+	}
+}
+
+func newConfig() interface{} { return &config{} }
+
+func post(h http.Handler, file, env string) *httptest.ResponseRecorder {
+	form := url.Values{"file": {file}, "env": {env}}
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func (s *Suite) TestHandler(c *check.C) {
+	h := Handler(newConfig, "APPNAME")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+	c.Check(w.Body.String(), check.Matches, `(?s).*APPNAME_SERVER_LISTEN.*APPNAME_DB_PASSWORD.*`)
+	c.Check(strings.Contains(w.Body.String(), "Result"), check.Equals, false)
+
+	// The process's own environment is not used.
+	os.Setenv("APPNAME_SERVER_PORT", "oops")
+	defer os.Unsetenv("APPNAME_SERVER_PORT")
+	w = post(h, "[server]\nlisten = :80\n[cache]\nsize = 1\n", "# comment\nexport APPNAME_SERVER_PORT=8080\n\n")
+	c.Assert(w.Code, check.Equals, http.StatusOK)
+	body := w.Body.String()
+	c.Check(body, check.Matches, `(?s).*The configuration is valid\..*`)
+	c.Check(body, check.Matches, `(?s).*GCFGENV-0017: .*can&#39;t store data at section &#34;cache&#34;.*`)
+	c.Check(body, check.Matches, `(?s).*<td>Server.Port</td><td>env</td><td>APPNAME_SERVER_PORT</td>.*`)
+	c.Check(body, check.Matches, `(?s).*<td>Server.Listen</td><td>file</td><td></td>.*`)
+
+	w = post(h, "", "APPNAME_SERVER_PORT=<b>\nAPPNAME_DB_PASSWORD=hunter2\n")
+	body = w.Body.String()
+	c.Check(body, check.Matches, `(?s).*class="error">GCFGENV-\d+: .*APPNAME_SERVER_PORT.*`)
+	c.Check(body, check.Matches, `(?s).*&lt;b&gt;.*`)
+	c.Check(strings.Contains(body, "<b>"), check.Equals, false)
+
+	w = post(h, "", "APPNAME_SERVER_PORT=1\nAPPNAME_DB_PASSWORD=hunter2\n")
+	c.Check(strings.Count(w.Body.String(), "hunter2"), check.Equals, 1)
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/", nil))
+	c.Check(w.Code, check.Equals, http.StatusMethodNotAllowed)
+}
+
+func (s *Suite) TestParseEnv(c *check.C) {
+	c.Check(parseEnv("A=1\r\n  export B = 2\n#C=3\nD\n=4\nE=x=y\n"), check.DeepEquals, map[string]string{
+		"A": "1",
+		"B": " 2",
+		"E": "x=y",
+	})
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import "sync"
+
+// Reading a configuration creates many short-lived maps and slices while
+// matching environment variables to subsections. They are pooled so that
+// programs that read configuration frequently (e.g. per request) create less
+// garbage. Nothing from these pools is ever returned to callers.
+
+var stringMapPool = sync.Pool{
+	New: func() interface{} { return make(map[string]string) },
+}
+
+func getStringMap() map[string]string {
+	return stringMapPool.Get().(map[string]string)
+}
+
+func putStringMap(m map[string]string) {
+	for k := range m {
+		delete(m, k)
+	}
+	stringMapPool.Put(m)
+}
+
+var stringSlicePool = sync.Pool{
+	New: func() interface{} {
+		s := make([]string, 0, 16)
+		return &s
+	},
+}
+
+func getStringSlice() *[]string {
+	return stringSlicePool.Get().(*[]string)
+}
+
+func putStringSlice(s *[]string) {
+	for i := range *s {
+		(*s)[i] = ""
+	}
+	*s = (*s)[:0]
+	stringSlicePool.Put(s)
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+	"testing"
+)
+
+func BenchmarkSmallConfig(b *testing.B) {
+	type backend struct {
+		URL     string
+		Weight  int
+		Enabled bool
+	}
+	type config struct {
+		Server struct {
+			Listen string
+			Debug  bool
+		}
+		Backend map[string]*backend
+	}
+	src := `[server]
+listen = :8080
+[backend "a"]
+url = http://a
+[backend "b"]
+url = http://b
+`
+	env := map[string]string{
+		"APPNAME_SERVER_DEBUG":     "true",
+		"APPNAME_BACKEND_a_WEIGHT": "2",
+		"APPNAME_BACKEND_c_URL":    "http://c",
+		"PATH":                     "/usr/bin",
+		"HOME":                     "/home/user",
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cfg := config{}
+		if err := readWithMapInto(strings.NewReader(src), env, "APPNAME", &cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
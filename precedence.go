@@ -0,0 +1,166 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import "strings"
+
+// A LayerKind identifies a source of configuration values.
+type LayerKind string
+
+const (
+	// LayerDefaults is the initial contents of the config struct, along
+	// with any Default_ fields for subsections.
+	LayerDefaults LayerKind = "defaults"
+	// LayerFile is the configuration file (or, for ReadFilesWithEnvInto,
+	// each of the files in the order given).
+	LayerFile LayerKind = "file"
+	// LayerEnvironmentFile is the file for the current environment, from
+	// WithEnvironmentFiles.
+	LayerEnvironmentFile LayerKind = "environment-file"
+	// LayerLocalFile is the file for the current machine, from
+	// WithEnvironmentFiles.
+	LayerLocalFile LayerKind = "local-file"
+	// LayerEnvironment is the environment variables with the prefix.
+	LayerEnvironment LayerKind = "environment"
+)
+
+// A Layer is one of the sources of configuration values in a Precedence.
+type Layer struct {
+	Kind LayerKind
+	// Patterns holds the names the layer is read from, with placeholders
+	// in angle brackets: file names for files, e.g. "<base>.local<ext>",
+	// or variable names for LayerEnvironment, e.g.
+	// "APPNAME_<SECTION>_<VARIABLE>". It is empty for LayerDefaults, and
+	// LayerFile is named by the caller.
+	Patterns []string
+	// Variable is the environment variable that the layer depends on, if
+	// any, e.g. the one whose value is <env> for LayerEnvironmentFile.
+	Variable string
+	// Optional is true if the layer is skipped when its file does not
+	// exist.
+	Optional bool
+}
+
+// A Precedence describes how a read function combines its sources of values,
+// for the options in effect, so that tooling can document it for a specific
+// build of a program.
+type Precedence struct {
+	// Layers holds the sources of values, from lowest to highest
+	// precedence: each overrides the values of those before it, except
+	// that slice fields may be combined (see SliceMode).
+	Layers []Layer
+	// Naming holds the rules for environment variable names.
+	Naming NamingRules
+}
+
+// NamingRules describes how environment variable names are formed.
+type NamingRules struct {
+	// Prefix is the prefix of every name, ending in Separator if it is not
+	// empty. Any placeholders for WithPrefixVars are left in place.
+	Prefix string
+	// PrefixVar is the environment variable that replaces Prefix when it
+	// is set, from WithPrefixFromEnv, if any.
+	PrefixVar string
+	// Separator joins the parts of a name.
+	Separator string
+	// Lowercase is true if names are lowercase rather than uppercase.
+	// Subsection names are left as-is either way.
+	Lowercase bool
+	// DashReplacement replaces dashes in section and variable names.
+	DashReplacement string
+	// FileSuffix is the suffix of variables that name a file holding the
+	// value, from WithFileVars, or "" if there are none.
+	FileSuffix string
+	// ReservedPrefixes holds the prefixes of names that are never used,
+	// from WithReservedPrefixes.
+	ReservedPrefixes []string
+	// SliceMode is how entries from variables are combined with those of
+	// slice fields without a directive of their own.
+	SliceMode SliceMode
+	// SliceDelimiter separates the entries of slice fields without a delim
+	// tag of their own, or is "" if values are not split.
+	SliceDelimiter string
+}
+
+// PrecedenceFor returns the layers and naming rules that the read functions
+// use with envPrefix and opts (along with any default options). It does not
+// look at the environment, so placeholders in the prefix are not filled, and
+// the environment files are included even if no environment is set.
+func PrecedenceFor(envPrefix string, opts ...Option) *Precedence {
+	o := newOptions(opts)
+	n := o.naming
+	rules := NamingRules{
+		Prefix:           prefixTemplate(n, envPrefix),
+		PrefixVar:        o.prefixVar,
+		Separator:        Separator,
+		Lowercase:        n.lower,
+		DashReplacement:  n.dash,
+		ReservedPrefixes: append([]string(nil), o.reservedPrefixes...),
+		SliceMode:        o.sliceMode,
+		SliceDelimiter:   SliceDelimiter,
+	}
+	if rules.DashReplacement == "" {
+		rules.DashReplacement = Separator
+	}
+	if o.customDelimiter {
+		rules.SliceDelimiter = o.sliceDelimiter
+	}
+	if o.fileVars {
+		rules.FileSuffix = n.name(fileVarSuffix)
+	}
+
+	p := &Precedence{Naming: rules}
+	p.Layers = append(p.Layers, Layer{Kind: LayerDefaults}, Layer{Kind: LayerFile})
+	if o.envFilesVar != "" {
+		p.Layers = append(p.Layers,
+			Layer{
+				Kind:     LayerEnvironmentFile,
+				Patterns: []string{"<base>.<env><ext>"},
+				Variable: o.envFilesVar,
+				Optional: true,
+			},
+			Layer{
+				Kind:     LayerLocalFile,
+				Patterns: []string{"<base>.local<ext>"},
+				Optional: true,
+			})
+	}
+	section, variable := n.name("<section>"), n.name("<variable>")
+	names := []string{
+		rules.Prefix + section + Separator + variable,
+		rules.Prefix + section + Separator + SubsectionPlaceholder + Separator + variable,
+	}
+	if o.fileVars {
+		for _, name := range names[:2] {
+			names = append(names, name+rules.FileSuffix)
+		}
+	}
+	p.Layers = append(p.Layers, Layer{Kind: LayerEnvironment, Patterns: names})
+	return p
+}
+
+// prefixTemplate applies the naming rules n to the prefix template prefix,
+// leaving the names of placeholders as they are, since they are filled before
+// the rules are applied.
+func prefixTemplate(n naming, prefix string) string {
+	if !n.lower {
+		return n.prefix(prefix)
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(prefix, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(prefix[i:], '}')
+		if j < 0 {
+			break
+		}
+		b.WriteString(strings.ToLower(prefix[:i]))
+		b.WriteString(prefix[i : i+j+1])
+		prefix = prefix[i+j+1:]
+	}
+	b.WriteString(strings.ToLower(prefix))
+	return normalizePrefix(b.String())
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestPrecedenceFor(c *check.C) {
+	c.Check(PrecedenceFor("APPNAME"), check.DeepEquals, &Precedence{
+		Layers: []Layer{
+			{Kind: LayerDefaults},
+			{Kind: LayerFile},
+			{Kind: LayerEnvironment, Patterns: []string{
+				"APPNAME_<SECTION>_<VARIABLE>",
+				"APPNAME_<SECTION>_<name>_<VARIABLE>",
+			}},
+		},
+		Naming: NamingRules{
+			Prefix:          "APPNAME_",
+			Separator:       "_",
+			DashReplacement: "_",
+			SliceMode:       SliceAppend,
+			SliceDelimiter:  ",",
+		},
+	})
+
+	p := PrecedenceFor("APPNAME_{INSTANCE}",
+		WithEnvironmentFiles("APP_ENV"),
+		WithLowercaseNames(),
+		WithDashReplacement("-"),
+		WithFileVars(),
+		WithPrefixFromEnv(""),
+		WithReservedPrefixes("appname_internal_"),
+		WithSliceMode(SliceReplace),
+		WithSliceDelimiter(""))
+	c.Check(p.Layers, check.DeepEquals, []Layer{
+		{Kind: LayerDefaults},
+		{Kind: LayerFile},
+		{Kind: LayerEnvironmentFile, Patterns: []string{"<base>.<env><ext>"}, Variable: "APP_ENV", Optional: true},
+		{Kind: LayerLocalFile, Patterns: []string{"<base>.local<ext>"}, Optional: true},
+		{Kind: LayerEnvironment, Patterns: []string{
+			"appname_{INSTANCE}_<section>_<variable>",
+			"appname_{INSTANCE}_<section>_<name>_<variable>",
+			"appname_{INSTANCE}_<section>_<variable>_file",
+			"appname_{INSTANCE}_<section>_<name>_<variable>_file",
+		}},
+	})
+	c.Check(p.Naming, check.DeepEquals, NamingRules{
+		Prefix:           "appname_{INSTANCE}_",
+		PrefixVar:        DefaultPrefixVar,
+		Separator:        "_",
+		Lowercase:        true,
+		DashReplacement:  "-",
+		FileSuffix:       "_file",
+		ReservedPrefixes: []string{"appname_internal_"},
+		SliceMode:        SliceReplace,
+	})
+	c.Check(p.Naming.SliceMode.String(), check.Equals, "replace")
+	c.Check(SliceMode(7).String(), check.Equals, "SliceMode(7)")
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultPrefixVar is the environment variable read by WithPrefixFromEnv when
+// it is not given one.
+const DefaultPrefixVar = "GCFGENV_PREFIX"
+
+// WithPrefixFromEnv replaces the prefix passed to the read functions with the
+// value of the environment variable name (DefaultPrefixVar if name is empty),
+// when it is set and not empty. This allows several instances of the same
+// program to run side by side with disjoint environment variables, e.g. with
+// GCFGENV_PREFIX=MYAPP2.
+func WithPrefixFromEnv(name string) Option {
+	if name == "" {
+		name = DefaultPrefixVar
+	}
+	return func(o *options) {
+		o.prefixVar = name
+	}
+}
+
+// WithPrefixVars sets the values of placeholders in prefix templates. A
+// prefix may contain placeholders such as "{INSTANCE}" (e.g.
+// "APPNAME_{INSTANCE}_"), so that each of several instances on the same host
+// can be configured individually. Placeholders are filled from vars, or
+// failing that from the environment variable of the same name, and it is an
+// error for one to be missing.
+func WithPrefixVars(vars map[string]string) Option {
+	return func(o *options) {
+		if o.prefixVars == nil {
+			o.prefixVars = make(map[string]string)
+		}
+		for k, v := range vars {
+			o.prefixVars[k] = v
+		}
+	}
+}
+
+// WithRequirePrefix makes it an error to read a configuration with an empty
+// prefix, which would match bare variables such as SEC_FIELD, and so risks
+// picking up unrelated variables.
+func WithRequirePrefix() Option {
+	return func(o *options) {
+		o.requirePrefix = true
+	}
+}
+
+// An EmptyPrefixError is a warning, reported by WithStrict, that environment
+// variables without a prefix were applied.
+type EmptyPrefixError struct {
+	// Names are the names of the variables, in sorted order.
+	Names []string
+}
+
+func (e *EmptyPrefixError) Error() string {
+	return "applied environment variables without a prefix: " + strings.Join(e.Names, ", ")
+}
+
+// emptyPrefixWarning returns an *EmptyPrefixError for the variables applied
+// with an empty prefix, or nil.
+func (st *applyState) emptyPrefixWarning() error {
+	if st.prefix != "" || len(st.used) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(st.used))
+	for e := range st.used {
+		names = append(names, e)
+	}
+	sort.Strings(names)
+	return &EmptyPrefixError{Names: names}
+}
+
+// resolvePrefix returns the prefix to use for env, taking WithPrefixFromEnv,
+// prefix templates, and the naming options into account.
+func (o *options) resolvePrefix(prefix string, env map[string]string) (string, error) {
+	if o.prefixVar != "" {
+		if p := env[o.prefixVar]; p != "" {
+			prefix = p
+		}
+	}
+	prefix, err := o.expandPrefix(prefix, env)
+	if err != nil {
+		return "", err
+	}
+	if prefix == "" && o.requirePrefix {
+		return "", fmt.Errorf("an environment variable prefix is required")
+	}
+	return o.naming.prefix(prefix), nil
+}
+
+// expandPrefix fills the placeholders in a prefix template.
+func (o *options) expandPrefix(prefix string, env map[string]string) (string, error) {
+	if !strings.Contains(prefix, "{") {
+		return prefix, nil
+	}
+	var b strings.Builder
+	rest := prefix
+	for {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			b.WriteString(rest)
+			return b.String(), nil
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return "", fmt.Errorf("unterminated placeholder in prefix %q", prefix)
+		}
+		name := rest[i+1 : i+j]
+		v := o.prefixVars[name]
+		if v == "" {
+			v = env[name]
+		}
+		if v == "" {
+			return "", fmt.Errorf("no value for placeholder {%s} in prefix %q", name, prefix)
+		}
+		b.WriteString(rest[:i])
+		b.WriteString(v)
+		rest = rest[i+j+1:]
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/gcfg.v1"
+)
+
+func (s *Suite) TestPrefixFromEnv(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string
+		}
+	}
+	env := map[string]string{
+		"GCFGENV_PREFIX":   "MYAPP2",
+		"INSTANCE":         "MYAPP3_",
+		"MYAPP_SEC_FIELD":  "one",
+		"MYAPP2_SEC_FIELD": "two",
+		"MYAPP3_SEC_FIELD": "three",
+	}
+	cases := []struct {
+		opts []Option
+		want string
+	}{
+		{nil, "one"},
+		{[]Option{WithPrefixFromEnv("")}, "two"},
+		{[]Option{WithPrefixFromEnv("INSTANCE")}, "three"},
+		{[]Option{WithPrefixFromEnv("UNSET")}, "one"},
+	}
+	for _, tc := range cases {
+		cfg := config{}
+		err := readWithMapInto(strings.NewReader(""), env, "MYAPP", &cfg, tc.opts...)
+		c.Check(err, check.IsNil)
+		c.Check(cfg.Sec.Field, check.Equals, tc.want)
+	}
+
+	cfg := config{}
+	err := reapplyEnvSectionWithMap(&cfg, "sec", env, "MYAPP", WithPrefixFromEnv(""))
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Sec.Field, check.Equals, "two")
+}
+
+func (s *Suite) TestPrefixTemplates(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string
+		}
+	}
+	env := map[string]string{
+		"INSTANCE":             "2",
+		"APP_1_SEC_FIELD":      "one",
+		"APP_2_SEC_FIELD":      "two",
+		"APP_2_east_SEC_FIELD": "east",
+	}
+	cases := []struct {
+		prefix string
+		opts   []Option
+		want   string
+		err    string
+	}{
+		{"APP_{INSTANCE}", nil, "two", ""},
+		{"APP_{INSTANCE}_", []Option{WithPrefixVars(map[string]string{"INSTANCE": "1"})}, "one", ""},
+		{"APP_{INSTANCE}_{ZONE}", []Option{WithPrefixVars(map[string]string{"ZONE": "east"})}, "east", ""},
+		{"APP_{ZONE}", nil, "", `no value for placeholder \{ZONE\} in prefix "APP_\{ZONE\}"`},
+		{"APP_{INSTANCE", nil, "", `unterminated placeholder in prefix "APP_\{INSTANCE"`},
+	}
+	for _, tc := range cases {
+		cfg := config{}
+		err := readWithMapInto(strings.NewReader(""), env, tc.prefix, &cfg, tc.opts...)
+		if tc.err != "" {
+			c.Check(err, check.ErrorMatches, tc.err)
+			continue
+		}
+		c.Check(err, check.IsNil)
+		c.Check(cfg.Sec.Field, check.Equals, tc.want)
+	}
+}
+
+func (s *Suite) TestEmptyPrefix(c *check.C) {
+	type config struct {
+		Sec struct {
+			Field string
+			Other string
+		}
+	}
+	env := map[string]string{
+		"SEC_FIELD": "a",
+		"SEC_OTHER": "b",
+		"PATH":      "/bin",
+	}
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(""), env, "", &cfg, WithStrict())
+	c.Check(err, check.ErrorMatches, "(?s).*applied environment variables without a prefix: SEC_FIELD, SEC_OTHER.*")
+	c.Check(gcfg.FatalOnly(err), check.IsNil)
+	c.Check(cfg.Sec.Field, check.Equals, "a")
+
+	// Nothing to warn about if nothing matched.
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{"PATH": "/bin"}, "", &cfg, WithStrict())
+	c.Check(err, check.IsNil)
+
+	err = readWithMapInto(strings.NewReader(""), env, "", &cfg, WithRequirePrefix())
+	c.Check(err, check.ErrorMatches, "an environment variable prefix is required")
+	err = readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithRequirePrefix())
+	c.Check(err, check.IsNil)
+}
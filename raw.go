@@ -0,0 +1,171 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/gcfg.v1/scanner"
+	"gopkg.in/gcfg.v1/token"
+	"gopkg.in/warnings.v0"
+)
+
+// rawSectionsField is the name of the optional catch-all field in a config
+// struct that collects any sections not matched by other fields. It must have
+// type map[string]map[string]string.
+//
+// Sections are keyed by their lowercase name, and subsections by the section
+// name and subsection name joined with a '.' (as with git-config), e.g.
+// "sec.k1". Variables are keyed by their lowercase name. If a variable is
+// given more than once, the last value is kept.
+//
+// Environment variables can override variables that are present in the file,
+// using the usual naming rules, but cannot add new ones: there is no way to
+// recover the original name from an environment variable.
+const rawSectionsField = "RawSections"
+
+var rawSectionsType = reflect.TypeOf(map[string]map[string]string{})
+
+// gcfgEntry is a section header or variable found while scanning a gcfg file.
+type gcfgEntry struct {
+	Section    string
+	Subsection string
+	// Name is empty for section headers.
+	Name  string
+	Value string
+	Blank bool
+}
+
+// scanGcfg returns the section headers and variables in src, in the order
+// they appear. It expects src to have already been validated by gcfg, and
+// silently skips anything it does not understand.
+func scanGcfg(src []byte) []gcfgEntry {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	var out []gcfgEntry
+	sect, sub := "", ""
+	_, tok, lit := s.Scan()
+	for tok != token.EOF {
+		switch tok {
+		case token.LBRACK:
+			_, tok, lit = s.Scan()
+			if tok != token.IDENT {
+				continue
+			}
+			sect, sub = lit, ""
+			_, tok, lit = s.Scan()
+			if tok == token.STRING {
+				sub = unquote(lit)
+				_, tok, lit = s.Scan()
+			}
+			out = append(out, gcfgEntry{Section: sect, Subsection: sub})
+		case token.IDENT:
+			entry := gcfgEntry{Section: sect, Subsection: sub, Name: lit}
+			_, tok, lit = s.Scan()
+			if tok != token.ASSIGN {
+				entry.Blank = true
+				out = append(out, entry)
+				continue
+			}
+			_, tok, lit = s.Scan()
+			if tok == token.STRING {
+				entry.Value = unquote(lit)
+				_, tok, lit = s.Scan()
+			}
+			out = append(out, entry)
+		default:
+			_, tok, lit = s.Scan()
+		}
+	}
+	return out
+}
+
+var unescape = map[rune]rune{'\\': '\\', '"': '"', 'n': '\n', 't': '\t'}
+
+// unquote mirrors gcfg's handling of quotes and escapes in values, but ignores
+// invalid sequences instead of panicking.
+func unquote(s string) string {
+	u, esc := make([]rune, 0, len(s)), false
+	for _, c := range s {
+		if esc {
+			if uc, ok := unescape[c]; ok {
+				u = append(u, uc)
+			}
+			esc = false
+			continue
+		}
+		switch c {
+		case '"':
+		case '\\':
+			esc = true
+		default:
+			u = append(u, c)
+		}
+	}
+	return string(u)
+}
+
+// fillRawSections populates the RawSections field of ref (if there is one)
+// with the sections in src that have no corresponding field, then applies
+// any overrides from env. Warnings from gcfg about those sections are removed
+// from upstreamErr, since their data is no longer discarded.
+func fillRawSections(st *applyState, ref reflect.Value, src []byte, prefix string, env map[string]string, upstreamErr error) error {
+	raw := ref.FieldByName(rawSectionsField)
+	if !raw.IsValid() || !raw.CanSet() || raw.Type() != rawSectionsType {
+		return upstreamErr
+	}
+	captured := make(map[string]bool)
+	for _, e := range scanGcfg(src) {
+		if sectionFieldIndex(ref.Type(), e.Section) >= 0 {
+			continue
+		}
+		captured[e.Section] = true
+		if raw.IsNil() {
+			raw.Set(reflect.MakeMap(rawSectionsType))
+		}
+		m := raw.Interface().(map[string]map[string]string)
+		key := strings.ToLower(e.Section)
+		if e.Subsection != "" {
+			key += "." + e.Subsection
+		}
+		if m[key] == nil {
+			m[key] = make(map[string]string)
+		}
+		if e.Name == "" {
+			continue
+		}
+		name := strings.ToLower(e.Name)
+		m[key][name] = e.Value
+		envVar := st.opts.naming.envVarName(prefix, e.Section, e.Subsection, e.Name)
+		if val, found := env[envVar]; found {
+			m[key][name] = val
+			st.used[envVar] = true
+		}
+	}
+	if len(captured) == 0 {
+		return upstreamErr
+	}
+	list, ok := upstreamErr.(warnings.List)
+	if !ok {
+		return upstreamErr
+	}
+	var remaining []error
+outer:
+	for _, w := range list.Warnings {
+		for sect := range captured {
+			if strings.HasPrefix(w.Error(), "can't store data at section \""+sect+"\"") {
+				continue outer
+			}
+		}
+		remaining = append(remaining, w)
+	}
+	if len(remaining) == 0 && list.Fatal == nil {
+		return nil
+	}
+	list.Warnings = remaining
+	return list
+}
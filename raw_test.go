@@ -0,0 +1,86 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestScanGcfg(c *check.C) {
+	src := `; comment
+[sec]
+field = value ; comment
+flag
+
+[sec "k1"]
+quoted = "a \"b\"\tc"
+`
+	c.Check(scanGcfg([]byte(src)), check.DeepEquals, []gcfgEntry{
+		{Section: "sec"},
+		{Section: "sec", Name: "field", Value: "value"},
+		{Section: "sec", Name: "flag", Blank: true},
+		{Section: "sec", Subsection: "k1"},
+		{Section: "sec", Subsection: "k1", Name: "quoted", Value: "a \"b\"\tc"},
+	})
+}
+
+func (s *Suite) TestRawSections(c *check.C) {
+	type sec struct {
+		Field string
+	}
+	type config struct {
+		Sec         sec
+		RawSections map[string]map[string]string
+	}
+
+	var err error
+	configString := `[sec]
+field = value
+
+[Cache]
+size = 10
+Evict-Policy = lru
+size = 20
+
+[cache "k1"]
+size = 5
+
+[empty]
+`
+	configEnvVars := map[string]string{
+		"APPNAME_SEC_FIELD":    "set",
+		"APPNAME_CACHE_SIZE":   "30",
+		"APPNAME_CACHE_k1_TTL": "noset",
+	}
+
+	cfg := config{}
+	r := strings.NewReader(configString)
+	err = readWithMapInto(r, configEnvVars, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{
+		Sec: sec{"set"},
+		RawSections: map[string]map[string]string{
+			"cache":    {"size": "30", "evict-policy": "lru"},
+			"cache.k1": {"size": "5"},
+			"empty":    {},
+		},
+	})
+
+	// Without the field, gcfg warns about the unknown sections.
+	type plainConfig struct {
+		Sec sec
+	}
+	plain := plainConfig{}
+	r = strings.NewReader(configString)
+	err = readWithMapInto(r, configEnvVars, "APPNAME", &plain)
+	c.Check(err, check.ErrorMatches, "(?s).*can't store data at section \"Cache\".*")
+
+	// Warnings for other problems are still reported.
+	cfg = config{}
+	r = strings.NewReader(configString + "[sec]\nother = value\n")
+	err = readWithMapInto(r, configEnvVars, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, "(?s)warning:\ncan't store data at section \"sec\", variable \"other\"\n")
+}
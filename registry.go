@@ -0,0 +1,296 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A Registry holds section structs registered by independently compiled
+// modules (e.g. plugins), so that a host program can read a configuration
+// file once and fan the values out to each of them.
+type Registry struct {
+	mu       sync.Mutex
+	sections []registeredSection
+}
+
+type registeredSection struct {
+	name string
+	ptr  reflect.Value
+}
+
+// DefaultRegistry is the Registry used by RegisterSection.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// RegisterSection registers section under name in DefaultRegistry. See
+// Registry.Register.
+func RegisterSection(name string, section interface{}) {
+	DefaultRegistry.Register(name, section)
+}
+
+// Register arranges for the section called name to be read into section,
+// which must be a pointer to a struct (for plain sections) or to a
+// map[string]*struct (for sections with subsections). Any values already in
+// section act as defaults.
+//
+// Like http.Handle, Register is intended to be called during initialisation
+// and panics if section has an unsupported type or name is already
+// registered.
+func (reg *Registry) Register(name string, section interface{}) {
+	ptr := reflect.ValueOf(section)
+	if !isSectionPtr(ptr) {
+		panic(fmt.Sprintf("gcfgenv: section %q must be a non-nil pointer to a struct or map[string]*struct, not %T",
+			name, section))
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for _, s := range reg.sections {
+		if strings.EqualFold(s.name, name) {
+			panic(fmt.Sprintf("gcfgenv: section %q registered twice", name))
+		}
+	}
+	reg.sections = append(reg.sections, registeredSection{name, ptr})
+}
+
+func isSectionPtr(ptr reflect.Value) bool {
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return false
+	}
+	t := ptr.Type().Elem()
+	if t.Kind() == reflect.Struct {
+		return true
+	}
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		t.Elem().Kind() == reflect.Ptr &&
+		t.Elem().Elem().Kind() == reflect.Struct
+}
+
+// ReadFileWithEnvInto is like the package-level ReadFileWithEnvInto, but also
+// sets the values of every registered section. config may be nil if the host
+// program has no sections of its own.
+func (reg *Registry) ReadFileWithEnvInto(filename string, envPrefix string, config interface{}, opts ...Option) error {
+	f, closeFile, err := openConfigFile(filename, newOptions(opts))
+	if err != nil {
+		return err
+	}
+	defer closeFile()
+	return reg.ReadWithEnvInto(f, envPrefix, config, opts...)
+}
+
+// ReadWithEnvInto is like the package-level ReadWithEnvInto, but also sets the
+// values of every registered section. config may be nil if the host program
+// has no sections of its own.
+func (reg *Registry) ReadWithEnvInto(r io.Reader, envPrefix string, config interface{}, opts ...Option) error {
+	env := mapFromEnviron(os.Environ())
+	return reg.readWithMapInto(r, env, envPrefix, config, opts...)
+}
+
+func (reg *Registry) readWithMapInto(r io.Reader, env map[string]string, prefix string, config interface{}, opts ...Option) error {
+	reg.mu.Lock()
+	sections := make([]registeredSection, len(reg.sections))
+	copy(sections, reg.sections)
+	reg.mu.Unlock()
+
+	var configs []interface{}
+	if config != nil {
+		configs = append(configs, config)
+	}
+	return readWithMapIntoEach(r, env, prefix, configs, sections, opts...)
+}
+
+// A hostField is an exported field of a config struct passed to
+// composeSections.
+type hostField struct {
+	sf reflect.StructField
+	v  reflect.Value
+}
+
+// hostFields returns the exported fields of the config struct host, and those
+// of any structs it embeds (which are not sections themselves), in order.
+func hostFields(host reflect.Value) []hostField {
+	var out []hostField
+	for i := 0; i < host.NumField(); i++ {
+		sf := host.Type().Field(i)
+		switch {
+		case isEmbedded(sf):
+			out = append(out, hostFields(host.Field(i))...)
+		case sf.IsExported():
+			out = append(out, hostField{sf, host.Field(i)})
+		}
+	}
+	return out
+}
+
+// hasEmbeddedConfig reports whether the config struct type t embeds other
+// config structs, which are read through composeSections.
+func hasEmbeddedConfig(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if isEmbedded(t.Field(i)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readComposed calls read with a config struct holding the sections of ref
+// and of the structs it embeds, then copies their values back to ref.
+func readComposed(ref reflect.Value, read func(config interface{}) error) error {
+	hosts := []reflect.Value{ref}
+	composite, err := composeSections(hosts, nil)
+	if err != nil {
+		return err
+	}
+	err = read(composite.Addr().Interface())
+	// Copy values back regardless of errors, as gcfg may already have set
+	// some of them.
+	decomposeSections(composite, hosts, nil)
+	return err
+}
+
+// flattenEmbedded returns ref, or a copy of it holding the sections of the
+// structs it embeds as its own fields, for functions that only examine it.
+func flattenEmbedded(ref reflect.Value) (reflect.Value, error) {
+	if !hasEmbeddedConfig(ref.Type()) {
+		return ref, nil
+	}
+	return composeSections([]reflect.Value{ref}, nil)
+}
+
+// composeSections builds a single config struct containing the exported
+// fields of each of hosts (including those of embedded structs) followed by
+// one field for each of the registered sections, initialised with their
+// current values.
+func composeSections(hosts []reflect.Value, sections []registeredSection) (reflect.Value, error) {
+	var fields []reflect.StructField
+	seen := make(map[string]bool)
+	claim := func(name string) error {
+		key := strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+		if seen[key] {
+			return fmt.Errorf("section %q is used by more than one destination", name)
+		}
+		seen[key] = true
+		return nil
+	}
+	for _, host := range hosts {
+		for _, hf := range hostFields(host) {
+			if err := claim(fieldSectionName(hf.sf)); err != nil {
+				return reflect.Value{}, err
+			}
+			fields = append(fields, reflect.StructField{
+				Name: hf.sf.Name, Type: hf.sf.Type, Tag: hf.sf.Tag,
+			})
+		}
+	}
+	for i, s := range sections {
+		if err := claim(s.name); err != nil {
+			return reflect.Value{}, err
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("GcfgenvRegistered%d", i),
+			Type: s.ptr.Type().Elem(),
+			Tag:  reflect.StructTag(fmt.Sprintf("gcfg:%q", s.name)),
+		})
+	}
+	composite := reflect.New(reflect.StructOf(fields)).Elem()
+	j := 0
+	for _, host := range hosts {
+		for _, hf := range hostFields(host) {
+			composite.Field(j).Set(hf.v)
+			j++
+		}
+	}
+	for _, s := range sections {
+		composite.Field(j).Set(s.ptr.Elem())
+		j++
+	}
+	return composite, nil
+}
+
+// fieldSectionName returns the name of the section gcfg stores in sf.
+func fieldSectionName(sf reflect.StructField) string {
+	if tag := gcfgTagName(sf); tag != "" {
+		return tag
+	}
+	return sf.Name
+}
+
+// decomposeSections reverses composeSections.
+func decomposeSections(composite reflect.Value, hosts []reflect.Value, sections []registeredSection) {
+	j := 0
+	for _, host := range hosts {
+		for _, hf := range hostFields(host) {
+			hf.v.Set(composite.Field(j))
+			j++
+		}
+	}
+	for _, s := range sections {
+		s.ptr.Elem().Set(composite.Field(j))
+		j++
+	}
+}
+
+// ReadWithEnvIntoEach is like ReadWithEnvInto, but reads into several config
+// structs at once, so that different subsystems can own their own config
+// types while the data is only read and parsed once. Each section may only
+// appear in one of the structs.
+func ReadWithEnvIntoEach(r io.Reader, envPrefix string, configs []interface{}, opts ...Option) error {
+	env := mapFromEnviron(os.Environ())
+	return readWithMapIntoEach(r, env, envPrefix, configs, nil, opts...)
+}
+
+// ReadWithEnvIntoSections is like ReadWithEnvInto, but reads each section
+// into its own destination, given as a map of section names to pointers to
+// structs (for plain sections) or map[string]*struct (for sections with
+// subsections).
+func ReadWithEnvIntoSections(r io.Reader, envPrefix string, sections map[string]interface{}, opts ...Option) error {
+	env := mapFromEnviron(os.Environ())
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	registered := make([]registeredSection, 0, len(sections))
+	for _, name := range names {
+		ptr := reflect.ValueOf(sections[name])
+		if !isSectionPtr(ptr) {
+			return fmt.Errorf("section %q must be a non-nil pointer to a struct or map[string]*struct, not %T",
+				name, sections[name])
+		}
+		registered = append(registered, registeredSection{name, ptr})
+	}
+	return readWithMapIntoEach(r, env, envPrefix, nil, registered, opts...)
+}
+
+func readWithMapIntoEach(r io.Reader, env map[string]string, prefix string, configs []interface{}, sections []registeredSection, opts ...Option) (err error) {
+	defer showErrorCodes(&err, opts)
+	defer recoverPanic(&err, "")
+	hosts := make([]reflect.Value, 0, len(configs))
+	for _, config := range configs {
+		host, err := targetStruct(config)
+		if err != nil {
+			return err
+		}
+		hosts = append(hosts, host)
+	}
+	composite, err := composeSections(hosts, sections)
+	if err != nil {
+		return err
+	}
+	err = readWithMapInto(r, env, prefix, composite.Addr().Interface(), opts...)
+	// Copy values back regardless of errors, as gcfg may already have set
+	// some of them.
+	decomposeSections(composite, hosts, sections)
+	return err
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"os"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestRegistry(c *check.C) {
+	type cacheConfig struct {
+		Size int
+		TTL  string
+	}
+	type poolConfig struct {
+		Max int
+	}
+	type sec struct {
+		Field string
+	}
+	type config struct {
+		Sec     sec
+		private int
+	}
+
+	var err error
+	configString := `[sec]
+field = value
+
+[cache]
+size = 10
+
+[pool "a"]
+max = 1
+`
+	configEnvVars := map[string]string{
+		"APPNAME_CACHE_TTL":   "5m",
+		"APPNAME_POOL_b_MAX":  "2",
+		"APPNAME_SEC_FIELD":   "set",
+		"APPNAME_OTHER_FIELD": "noset",
+	}
+
+	cache := cacheConfig{TTL: "1m"}
+	var pools map[string]*poolConfig
+	reg := NewRegistry()
+	reg.Register("cache", &cache)
+	reg.Register("pool", &pools)
+
+	cfg := config{private: 1}
+	r := strings.NewReader(configString)
+	err = reg.readWithMapInto(r, configEnvVars, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{Sec: sec{"set"}, private: 1})
+	c.Check(cache, check.DeepEquals, cacheConfig{Size: 10, TTL: "5m"})
+	c.Check(pools, check.DeepEquals, map[string]*poolConfig{
+		"a": {Max: 1}, "b": {Max: 2},
+	})
+
+	// The host config is optional.
+	cache = cacheConfig{}
+	r = strings.NewReader("[cache]\nsize = 20\n")
+	err = reg.readWithMapInto(r, configEnvVars, "APPNAME", nil)
+	c.Check(err, check.IsNil)
+	c.Check(cache, check.DeepEquals, cacheConfig{Size: 20, TTL: "5m"})
+
+	// Conflicts with the host config are reported.
+	conflicting := NewRegistry()
+	conflicting.Register("SEC", &cache)
+	r = strings.NewReader("")
+	err = conflicting.readWithMapInto(r, configEnvVars, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, "section \"SEC\" is used by more than one destination")
+
+	err = reg.readWithMapInto(r, configEnvVars, "APPNAME", cfg)
+	c.Check(err, check.ErrorMatches, "config must be a non-nil pointer to a struct, not .*")
+
+	// Invalid registrations panic.
+	c.Check(func() { reg.Register("Cache", &cache) }, check.PanicMatches,
+		".*registered twice")
+	c.Check(func() { reg.Register("other", cache) }, check.PanicMatches,
+		".*must be a non-nil pointer.*")
+	c.Check(func() { reg.Register("other", &configString) }, check.PanicMatches,
+		".*must be a non-nil pointer.*")
+}
+
+func (s *Suite) TestReadIntoEach(c *check.C) {
+	type sec struct {
+		Field string
+	}
+	type serverConfig struct {
+		Server sec
+	}
+	type dbConfig struct {
+		DB map[string]*sec
+	}
+
+	var err error
+	configString := `[server]
+field = value
+
+[db "main"]
+field = value
+`
+	configEnvVars := map[string]string{
+		"APPNAME_SERVER_FIELD":     "set",
+		"APPNAME_DB_replica_FIELD": "set",
+	}
+
+	var server serverConfig
+	var db dbConfig
+	r := strings.NewReader(configString)
+	err = readWithMapIntoEach(r, configEnvVars, "APPNAME",
+		[]interface{}{&server, &db}, nil)
+	c.Check(err, check.IsNil)
+	c.Check(server, check.DeepEquals, serverConfig{Server: sec{"set"}})
+	c.Check(db, check.DeepEquals, dbConfig{DB: map[string]*sec{
+		"main": {"value"}, "replica": {"set"},
+	}})
+
+	var other serverConfig
+	r = strings.NewReader(configString)
+	err = readWithMapIntoEach(r, configEnvVars, "APPNAME",
+		[]interface{}{&server, &other}, nil)
+	c.Check(err, check.ErrorMatches, "section \"Server\" is used by more than one destination")
+
+	err = readWithMapIntoEach(r, configEnvVars, "APPNAME",
+		[]interface{}{server}, nil)
+	c.Check(err, check.ErrorMatches, "config must be a non-nil pointer to a struct, not .*")
+}
+
+func (s *Suite) TestReadIntoSections(c *check.C) {
+	type sec struct {
+		Field string
+	}
+
+	var err error
+	var server sec
+	var dbs map[string]*sec
+	os.Setenv("APPNAME_SERVER_FIELD", "set")
+	defer os.Unsetenv("APPNAME_SERVER_FIELD")
+
+	r := strings.NewReader("[server]\nfield = value\n[db \"main\"]\nfield = value\n")
+	err = ReadWithEnvIntoSections(r, "APPNAME", map[string]interface{}{
+		"server": &server,
+		"db":     &dbs,
+	})
+	c.Check(err, check.IsNil)
+	c.Check(server, check.DeepEquals, sec{"set"})
+	c.Check(dbs, check.DeepEquals, map[string]*sec{"main": {"value"}})
+
+	err = ReadWithEnvIntoSections(r, "APPNAME", map[string]interface{}{
+		"server": server,
+	})
+	c.Check(err, check.ErrorMatches, "section \"server\" must be a non-nil pointer.*")
+}
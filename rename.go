@@ -0,0 +1,213 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/gcfg.v1/scanner"
+	"gopkg.in/gcfg.v1/token"
+)
+
+// A DeprecatedNameError is a warning about a section or variable in the file,
+// or an environment variable, that uses a name listed in a
+// gcfgenv:"renamedfrom=..." directive. The value is still used.
+type DeprecatedNameError struct {
+	// Name is the deprecated name, as found. Names from the file are given
+	// as "section" or "section.variable".
+	Name string
+	// Replacement is the name that should be used instead.
+	Replacement string
+}
+
+func (e *DeprecatedNameError) Error() string {
+	return fmt.Sprintf("%s is deprecated, use %s instead", e.Name, e.Replacement)
+}
+
+// renamedSection describes the renames declared on a section field and the
+// fields of its struct.
+type renamedSection struct {
+	sf      reflect.StructField
+	oldName []string
+	// vars maps the deprecated names of variables (as given in the tag) to
+	// their fields.
+	vars map[string]reflect.StructField
+	// subsections is true for subsection maps.
+	subsections bool
+}
+
+// collectRenames returns the renamed sections and variables of the config
+// struct type t, or nil if there are none.
+func collectRenames(t reflect.Type) []renamedSection {
+	var out []renamedSection
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		secType := sectionStructType(sf.Type)
+		if secType == nil {
+			continue
+		}
+		rs := renamedSection{
+			sf:          sf,
+			oldName:     parseFieldTag(sf).list("renamedfrom"),
+			subsections: sf.Type.Kind() == reflect.Map,
+		}
+		for j := 0; j < secType.NumField(); j++ {
+			f := secType.Field(j)
+			for _, old := range parseFieldTag(f).list("renamedfrom") {
+				if rs.vars == nil {
+					rs.vars = make(map[string]reflect.StructField)
+				}
+				rs.vars[old] = f
+			}
+		}
+		if len(rs.oldName) > 0 || len(rs.vars) > 0 {
+			out = append(out, rs)
+		}
+	}
+	return out
+}
+
+// renamedVar returns the field that the deprecated variable name lit, from
+// the file, refers to.
+func (rs *renamedSection) renamedVar(lit string) (reflect.StructField, bool) {
+	for old, f := range rs.vars {
+		if envName(old) == envName(lit) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// configName returns the name of a section or variable field as it would
+// appear in the file.
+func configName(sf reflect.StructField) string {
+	return strings.ToLower(fieldSectionName(sf))
+}
+
+// renameInFile rewrites deprecated section and variable names in src to
+// their replacements, leaving everything else untouched.
+func renameInFile(src []byte, renames []renamedSection) ([]byte, []error) {
+	if len(renames) == 0 {
+		return src, nil
+	}
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	var buf []byte
+	var warns []error
+	seen := make(map[string]bool)
+	last := 0
+	replace := func(pos token.Pos, lit, replacement string, warn *DeprecatedNameError) {
+		off := file.Offset(pos)
+		buf = append(buf, src[last:off]...)
+		buf = append(buf, replacement...)
+		last = off + len(lit)
+		if !seen[warn.Name] {
+			seen[warn.Name] = true
+			warns = append(warns, warn)
+		}
+	}
+	var sec *renamedSection
+	secName := ""
+	pos, tok, lit := s.Scan()
+	for tok != token.EOF {
+		switch tok {
+		case token.LBRACK:
+			pos, tok, lit = s.Scan()
+			if tok != token.IDENT {
+				continue
+			}
+			sec, secName = nil, lit
+			for i := range renames {
+				rs := &renames[i]
+				if envName(lit) == fieldToEnvVar(rs.sf) {
+					sec = rs
+				}
+				for _, old := range rs.oldName {
+					if envName(lit) == envName(old) {
+						sec, secName = rs, configName(rs.sf)
+						replace(pos, lit, secName, &DeprecatedNameError{
+							Name:        lit,
+							Replacement: secName,
+						})
+					}
+				}
+			}
+		case token.IDENT:
+			if sec != nil {
+				if f, found := sec.renamedVar(lit); found {
+					replace(pos, lit, configName(f), &DeprecatedNameError{
+						Name:        secName + "." + lit,
+						Replacement: secName + "." + configName(f),
+					})
+				}
+			}
+		}
+		pos, tok, lit = s.Scan()
+	}
+	if buf == nil {
+		return src, nil
+	}
+	return append(buf, src[last:]...), warns
+}
+
+// renameInEnv returns a copy of env in which deprecated environment variable
+// names have been replaced. If both a deprecated name and its replacement are
+// set, the replacement wins.
+func renameInEnv(env map[string]string, prefix string, n naming, renames []renamedSection) (map[string]string, []error) {
+	if len(renames) == 0 {
+		return env, nil
+	}
+	out := make(map[string]string, len(env))
+	renamed := make(map[string]string)
+	for e, v := range env {
+		name := e
+		for _, rs := range renames {
+			secPrefix := prefix + n.field(rs.sf) + "_"
+			for _, old := range rs.oldName {
+				if p := prefix + n.name(old) + "_"; strings.HasPrefix(name, p) {
+					name = secPrefix + strings.TrimPrefix(name, p)
+				}
+			}
+			if !strings.HasPrefix(name, secPrefix) {
+				continue
+			}
+			for old, f := range rs.vars {
+				old := n.name(old)
+				if !rs.subsections && name == secPrefix+old {
+					name = secPrefix + n.field(f)
+				}
+				if rs.subsections && strings.HasSuffix(name, "_"+old) &&
+					len(name) > len(secPrefix)+len(old)+1 {
+					name = strings.TrimSuffix(name, old) + n.field(f)
+				}
+			}
+		}
+		if name == e {
+			out[e] = v
+			continue
+		}
+		renamed[e] = name
+		if _, found := env[name]; !found {
+			out[name] = v
+		}
+	}
+	olds := make([]string, 0, len(renamed))
+	for e := range renamed {
+		olds = append(olds, e)
+	}
+	sort.Strings(olds)
+	var warns []error
+	for _, e := range olds {
+		warns = append(warns, &DeprecatedNameError{Name: e, Replacement: renamed[e]})
+	}
+	return out, warns
+}
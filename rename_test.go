@@ -0,0 +1,72 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/warnings.v0"
+)
+
+func (s *Suite) TestRenamedFrom(c *check.C) {
+	type http struct {
+		Listen  string `gcfgenv:"renamedfrom=listen-addr,bind"`
+		Timeout int
+	}
+	type backend struct {
+		URL string `gcfg:"url" gcfgenv:"renamedfrom=address"`
+	}
+	type config struct {
+		HTTP    http `gcfgenv:"renamedfrom=server"`
+		Backend map[string]*backend
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader(`[server]
+listen-addr = ":8080" ; listen-addr
+timeout = 5
+[backend "b1"]
+address = http://b1
+[backend "b2"]
+url = http://b2
+`)
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SERVER_TIMEOUT":     "10",
+		"APPNAME_BACKEND_b2_ADDRESS": "http://b2.example",
+		"APPNAME_BACKEND_b3_ADDRESS": "http://b3",
+	}, "APPNAME", &cfg)
+	c.Check(gcfg.FatalOnly(err), check.IsNil)
+	c.Check(warnings.WarningsOnly(err), check.DeepEquals, []error{
+		&DeprecatedNameError{Name: "server", Replacement: "http"},
+		&DeprecatedNameError{Name: "http.listen-addr", Replacement: "http.listen"},
+		&DeprecatedNameError{Name: "backend.address", Replacement: "backend.url"},
+		&DeprecatedNameError{Name: "APPNAME_BACKEND_b2_ADDRESS", Replacement: "APPNAME_BACKEND_b2_URL"},
+		&DeprecatedNameError{Name: "APPNAME_BACKEND_b3_ADDRESS", Replacement: "APPNAME_BACKEND_b3_URL"},
+		&DeprecatedNameError{Name: "APPNAME_SERVER_TIMEOUT", Replacement: "APPNAME_HTTP_TIMEOUT"},
+	})
+	c.Check(cfg, check.DeepEquals, config{
+		HTTP: http{Listen: ":8080", Timeout: 10},
+		Backend: map[string]*backend{
+			"b1": {URL: "http://b1"},
+			"b2": {URL: "http://b2.example"},
+			"b3": {URL: "http://b3"},
+		},
+	})
+
+	// Current names take precedence over deprecated ones in the environment.
+	cfg = config{}
+	r = strings.NewReader("[http]\nbind = :80\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_HTTP_BIND":   ":81",
+		"APPNAME_HTTP_LISTEN": ":82",
+	}, "APPNAME", &cfg)
+	c.Check(warnings.WarningsOnly(err), check.HasLen, 2)
+	c.Check(cfg.HTTP.Listen, check.Equals, ":82")
+
+	c.Check((&DeprecatedNameError{Name: "server", Replacement: "http"}).Error(),
+		check.Equals, "server is deprecated, use http instead")
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A Source says where the value of a field came from.
+type Source int
+
+const (
+	// SourceNone means that the field was not set by the file or the
+	// environment, and kept its initial (or gcfg default) value.
+	SourceNone Source = iota
+	// SourceFile means that the field was set by the configuration file.
+	SourceFile
+	// SourceEnv means that the field was set (or, for slices, appended
+	// to) by an environment variable, whether or not it was also in the
+	// file.
+	SourceEnv
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceFile:
+		return "file"
+	case SourceEnv:
+		return "env"
+	}
+	return "none"
+}
+
+// A FieldReport describes where the value of a single field came from.
+type FieldReport struct {
+	// Path is the path of the field, made up of field names (or their
+	// gcfg tags) and subsection names, e.g. "Server.main.Listen".
+	Path   string
+	Source Source
+	// EnvVar is the environment variable that set the field, if any.
+	EnvVar string
+	// Roles holds the roles in the field's gcfgenv:"role=..." directive,
+	// if any, e.g. for admin UIs to hide the field from other viewers.
+	Roles []string
+}
+
+// A Report describes where the values in a configuration came from, to help
+// operators work out why a field has the value it does.
+type Report struct {
+	// Fields holds every field of every section and subsection, in the
+	// same order as the config struct, with subsections sorted by name.
+	// Groups are replaced by their fields, e.g. "Server.Timeout.Read".
+	Fields []FieldReport
+}
+
+// WithReport fills r with a report of which fields were set by the file and
+// which by environment variables, once the configuration has been read.
+func WithReport(r *Report) Option {
+	return func(o *options) {
+		o.report = r
+	}
+}
+
+// fileKey identifies a variable in the file, independently of case and
+// dashes in the section and variable names.
+func fileKey(section, subsection, name string) string {
+	return envName(section) + "\x00" + subsection + "\x00" + envName(name)
+}
+
+// fillReport fills in the report requested with WithReport, if any, for the
+// config struct ref read from src (which may be nil).
+func (st *applyState) fillReport(ref reflect.Value, src []byte) error {
+	if st.opts.report == nil {
+		return nil
+	}
+	inFile := make(map[string]bool)
+	for _, e := range scanGcfg(src) {
+		if e.Name != "" {
+			inFile[fileKey(e.Section, e.Subsection, e.Name)] = true
+		}
+	}
+	var fields []FieldReport
+	err := walkSections(ref, func(path []string, sec reflect.Value) error {
+		fields = st.reportSection(fields, path, sec, inFile)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	st.opts.report.Fields = fields
+	return nil
+}
+
+// reportSection appends the reports for the fields of the section or
+// subsection sec at path to fields, including those in groups and those
+// promoted from embedded structs. The variables that set them are named by
+// envFields, as when the environment is applied.
+func (st *applyState) reportSection(fields []FieldReport, path []string, sec reflect.Value, inFile map[string]bool) []FieldReport {
+	names := make(map[string]string)
+	for _, ef := range st.opts.naming.envFields(sec.Type()) {
+		names[fmt.Sprint(ef.index)] = ef.name
+	}
+	secPrefix := st.opts.naming.envVarName(st.prefix, append(path[:len(path):len(path)], "")...)
+	sub := strings.Join(path[1:], ".")
+	var add func(v reflect.Value, index []int, groupPath []string)
+	add = func(v reflect.Value, index []int, groupPath []string) {
+		t := v.Type()
+		for j := 0; j < t.NumField(); j++ {
+			sf := t.Field(j)
+			f := v.Field(j)
+			fieldIndex := append(index[:len(index):len(index)], j)
+			if isEmbedded(sf) {
+				add(f, fieldIndex, groupPath)
+				continue
+			}
+			if !sf.IsExported() || !f.CanSet() {
+				continue
+			}
+			fieldPath := append(groupPath[:len(groupPath):len(groupPath)], fieldSectionName(sf))
+			if isGroup(sf) {
+				add(f, fieldIndex, fieldPath)
+				continue
+			}
+			fr := FieldReport{Path: strings.Join(fieldPath, "."), Roles: fieldRoles(sf)}
+			// Only fields outside groups can be set by the file, or
+			// have pinned variables.
+			var e string
+			if name, ok := names[fmt.Sprint(fieldIndex)]; ok {
+				e = secPrefix + name
+			}
+			if len(fieldPath) == len(path)+1 {
+				if inFile[fileKey(path[0], sub, fieldSectionName(sf))] {
+					fr.Source = SourceFile
+				}
+				if pinned, ok := st.pinned[fr.Path]; ok {
+					e = pinned
+				}
+			}
+			if e != "" && st.used[e] {
+				fr.Source = SourceEnv
+				fr.EnvVar = e
+			}
+			fields = append(fields, fr)
+		}
+	}
+	add(sec, nil, path)
+	return fields
+}
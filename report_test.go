@@ -0,0 +1,85 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+type reportCommon struct {
+	Region string
+}
+
+func (s *Suite) TestReport(c *check.C) {
+	type pool struct {
+		Size int
+	}
+	type config struct {
+		Server struct {
+			Listen     string
+			OtherField string `gcfg:"other-field"`
+			Debug      bool   `gcfgenv:"role=admin"`
+		}
+		Pool map[string]*pool
+	}
+	src := `
+[Server]
+listen = :80
+other-field = x
+[pool "a"]
+size = 1
+[pool "b"]
+size = 2
+`
+	env := map[string]string{
+		"APP_SERVER_OTHER_FIELD": "y",
+		"APP_POOL_b_SIZE":        "3",
+		"APP_POOL_c_SIZE":        "4",
+	}
+	var report Report
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(src), env, "APP", &cfg, WithReport(&report))
+	c.Assert(err, check.IsNil)
+	c.Check(report.Fields, check.DeepEquals, []FieldReport{
+		{Path: "Server.Listen", Source: SourceFile},
+		{Path: "Server.other-field", Source: SourceEnv, EnvVar: "APP_SERVER_OTHER_FIELD"},
+		{Path: "Server.Debug", Source: SourceNone, Roles: []string{"admin"}},
+		{Path: "Pool.a.Size", Source: SourceFile},
+		{Path: "Pool.b.Size", Source: SourceEnv, EnvVar: "APP_POOL_b_SIZE"},
+		{Path: "Pool.c.Size", Source: SourceEnv, EnvVar: "APP_POOL_c_SIZE"},
+	})
+	c.Check(SourceEnv.String(), check.Equals, "env")
+
+	report = Report{}
+	cfg = config{}
+	err = applyWithMapInto(env, "APP", &cfg, WithReport(&report))
+	c.Assert(err, check.IsNil)
+	c.Check(report.Fields[0], check.DeepEquals, FieldReport{Path: "Server.Listen"})
+	c.Check(report.Fields[1].Source, check.Equals, SourceEnv)
+
+	// Fields in groups, and those promoted from embedded structs, are
+	// reported with the variables that set them.
+	type nested struct {
+		Server struct {
+			reportCommon
+			Limits struct {
+				Conns int
+				Queue int
+			}
+		}
+	}
+	report = Report{}
+	err = readWithMapInto(strings.NewReader("[server]\nregion = eu\n"), map[string]string{
+		"APP_SERVER_REGION":       "us",
+		"APP_SERVER_LIMITS_CONNS": "10",
+	}, "APP", &nested{}, WithReport(&report))
+	c.Assert(err, check.IsNil)
+	c.Check(report.Fields, check.DeepEquals, []FieldReport{
+		{Path: "Server.Region", Source: SourceEnv, EnvVar: "APP_SERVER_REGION"},
+		{Path: "Server.Limits.Conns", Source: SourceEnv, EnvVar: "APP_SERVER_LIMITS_CONNS"},
+		{Path: "Server.Limits.Queue", Source: SourceNone},
+	})
+}
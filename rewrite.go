@@ -0,0 +1,140 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/gcfg.v1/scanner"
+	"gopkg.in/gcfg.v1/token"
+)
+
+// UpdateValue sets the variable at path in doc to value, adding the variable
+// (and its section) if necessary. path is "section.variable" or
+// "section.subsection.variable", and the subsection may itself contain dots,
+// e.g. "backend.a.example.com.weight".
+//
+// When doc is written out, the rest of the variable's line (its indentation,
+// the spacing around the "=", and any comment) is kept as it was, so that
+// automated changes do not remove annotations made by operators.
+func UpdateValue(doc *Document, path, value string) error {
+	first := strings.Index(path, ".")
+	last := strings.LastIndex(path, ".")
+	if first <= 0 || last == len(path)-1 {
+		return fmt.Errorf("invalid variable path %q: expected section.variable or section.subsection.variable", path)
+	}
+	name, subsection, variable := path[:first], "", path[last+1:]
+	if last > first {
+		subsection = path[first+1 : last]
+	}
+	s := doc.Section(name, subsection)
+	if s == nil {
+		s = doc.AddSection(name, subsection)
+	}
+	s.Set(variable, value)
+	return nil
+}
+
+// lines returns the lines to write for the section header.
+func (s *DocSection) lines(cr string) []string {
+	if s.raw == nil {
+		return []string{formatSectionHeader(s.Name, s.Subsection) + cr}
+	}
+	if s.orig == [2]string{s.Name, s.Subsection} {
+		return s.raw
+	}
+	return []string{leadingSpace(s.raw[0]) + formatSectionHeader(s.Name, s.Subsection) +
+		inlineComment(s.raw) + lineEnd(s.raw)}
+}
+
+// lines returns the lines to write for the variable. New variables are
+// indented with indent.
+func (v *DocVariable) lines(indent, cr string) []string {
+	if v.raw == nil {
+		return []string{indent + formatVariable(v) + cr}
+	}
+	if v.orig == v.value() {
+		return v.raw
+	}
+	if len(v.raw) == 1 && v.orig.name == v.Name && !v.Blank {
+		if line, ok := replaceValue(v.raw[0], formatGcfgValue(v.Value)); ok {
+			return []string{line}
+		}
+	}
+	return []string{leadingSpace(v.raw[0]) + formatVariable(v) + inlineComment(v.raw) + lineEnd(v.raw)}
+}
+
+// indent returns the indentation of the first variable in s that was read
+// from a file, or a tab if there is none.
+func (s *DocSection) indent() string {
+	for _, v := range s.Variables {
+		if v.raw != nil {
+			return leadingSpace(v.raw[0])
+		}
+	}
+	return "\t"
+}
+
+// replaceValue replaces the value in line, a variable with a value, keeping
+// everything around it.
+func replaceValue(line, value string) (string, bool) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(line))
+	var s scanner.Scanner
+	s.Init(file, []byte(line), nil, scanner.ScanComments)
+	for {
+		pos, tok, _ := s.Scan()
+		switch tok {
+		case token.EOF, token.EOL:
+			return "", false
+		case token.ASSIGN:
+			offs := file.Offset(pos) + 1
+			pos, tok, lit := s.Scan()
+			if tok != token.STRING {
+				// An empty value.
+				return line[:offs] + " " + value + line[offs:], true
+			}
+			start := file.Offset(pos)
+			return line[:start] + value + line[start+len(lit):], true
+		}
+	}
+}
+
+// inlineComment returns the comment at the end of lines, including the
+// whitespace before it, or "".
+func inlineComment(lines []string) string {
+	last := strings.TrimSuffix(lines[len(lines)-1], "\r")
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(last))
+	var s scanner.Scanner
+	s.Init(file, []byte(last), nil, scanner.ScanComments)
+	end := 0
+	for {
+		pos, tok, lit := s.Scan()
+		switch tok {
+		case token.EOF, token.EOL:
+			return ""
+		case token.COMMENT:
+			// Keep the whitespace before the comment.
+			return last[end:file.Offset(pos)] + lit
+		}
+		end = file.Offset(pos) + len(lit)
+		if lit == "" {
+			end = file.Offset(pos) + len(tok.String())
+		}
+	}
+}
+
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// lineEnd returns "\r" if lines have Windows line endings, and "" otherwise.
+func lineEnd(lines []string) string {
+	if len(lines) > 0 && strings.HasSuffix(lines[len(lines)-1], "\r") {
+		return "\r"
+	}
+	return ""
+}
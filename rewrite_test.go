@@ -0,0 +1,54 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestUpdateValue(c *check.C) {
+	src := `; Example configuration.
+[server] ; the main server
+    listen   =   0.0.0.0:8080   ; set by ops, see TICKET-12
+    verbose ; keep until the next release
+    motd = hello, \
+world
+[backend "a.example.com"]
+  weight=2 # was 1
+`
+	d, err := ParseDocument(strings.NewReader(src))
+	c.Assert(err, check.IsNil)
+	c.Check(UpdateValue(d, "server.listen", "127.0.0.1:9090"), check.IsNil)
+	c.Check(UpdateValue(d, "server.verbose", "false"), check.IsNil)
+	c.Check(UpdateValue(d, "server.motd", "; hi"), check.IsNil)
+	c.Check(UpdateValue(d, "backend.a.example.com.weight", "3"), check.IsNil)
+	c.Check(UpdateValue(d, "backend.a.example.com.tag", "x"), check.IsNil)
+	c.Check(UpdateValue(d, "cache.size", "10"), check.IsNil)
+	c.Check(string(d.Bytes()), check.Equals, `; Example configuration.
+[server] ; the main server
+    listen   =   127.0.0.1:9090   ; set by ops, see TICKET-12
+    verbose = false ; keep until the next release
+    motd = "; hi"
+[backend "a.example.com"]
+  weight=3 # was 1
+  tag = x
+[cache]
+	size = 10
+`)
+
+	// Renamed sections keep their comments, and Windows line endings are
+	// kept for new lines.
+	d, err = ParseDocument(strings.NewReader("[server] ; main\r\n\tlisten = :80\r\n"))
+	c.Assert(err, check.IsNil)
+	d.Sections[0].Name = "http"
+	c.Check(UpdateValue(d, "http.timeout", "5s"), check.IsNil)
+	c.Check(string(d.Bytes()), check.Equals, "[http] ; main\r\n\tlisten = :80\r\n\ttimeout = 5s\r\n")
+
+	for _, path := range []string{"listen", ".listen", "server.", "server.a."} {
+		err = UpdateValue(d, path, "x")
+		c.Check(err, check.ErrorMatches, `invalid variable path ".*": expected section\.variable or section\.subsection\.variable`)
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// fillSameAs enforces gcfgenv:"same-as=..." directives once the file and the
+// environment have both been applied: each field with one that is not set
+// (see isSet) takes the value of the field it names, which is either a field
+// of the same section (or subsection) or "Section.Field", as for required_if.
+//
+// A field may name another with its own same-as directive, so fields are
+// filled in passes until none changes. Fields in a cycle with none set are
+// left unset. The sections in secs, which is either ref or a wrapper from
+// streamWrapper, are filled, and fields of other sections are looked up in
+// the config struct ref.
+func fillSameAs(ref, secs reflect.Value) error {
+	for {
+		changed := false
+		err := walkSections(secs, func(path []string, sec reflect.Value) error {
+			secType := sec.Type()
+			for j := 0; j < secType.NumField(); j++ {
+				sf := secType.Field(j)
+				if !sf.IsExported() {
+					continue
+				}
+				target, ok := parseFieldTag(sf)["same-as"]
+				if !ok || isSet(sec.Field(j)) {
+					continue
+				}
+				name := strings.Join(append(path[:len(path):len(path)], fieldSectionName(sf)), ".")
+				other, err := lookupFieldRef(ref, sec, target)
+				if err != nil {
+					return fmt.Errorf("invalid same-as directive on %s: %q", name, target)
+				}
+				if other.Type() != sf.Type {
+					return fmt.Errorf("%s cannot be the same as %s, which has type %s, not %s",
+						name, target, other.Type(), sf.Type)
+				}
+				if !isSet(other) {
+					continue
+				}
+				setSameAs(sec.Field(j), other)
+				changed = true
+			}
+			return nil
+		})
+		if err != nil || !changed {
+			return err
+		}
+	}
+}
+
+// setSameAs sets f to the value of other, copying slices so that appending to
+// one does not change the other.
+func setSameAs(f, other reflect.Value) {
+	if other.Kind() == reflect.Slice {
+		f.Set(reflect.AppendSlice(reflect.MakeSlice(other.Type(), 0, other.Len()), other))
+		return
+	}
+	f.Set(other)
+}
@@ -0,0 +1,86 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestSameAs(c *check.C) {
+	type pool struct {
+		Primary string
+		Replica string `gcfgenv:"same-as=Primary"`
+	}
+	type config struct {
+		Server struct {
+			PublicURL   string   `gcfg:"public-url"`
+			InternalURL string   `gcfg:"internal-url" gcfgenv:"same-as=public-url"`
+			AdminURL    string   `gcfg:"admin-url" gcfgenv:"same-as=internal-url"`
+			Hosts       []string `gcfgenv:"same-as=Client.Hosts"`
+		}
+		Client struct {
+			Hosts []string
+		}
+		Pool map[string]*pool
+	}
+	file := `[server]
+public-url = https://example.com
+[client]
+hosts = a
+hosts = b
+[pool "x"]
+primary = db1
+[pool "y"]
+primary = db2
+replica = db3
+`
+	var cfg config
+	err := ReadWithEnvMapInto(strings.NewReader(file), map[string]string{
+		"APPNAME_SERVER_ADMIN_URL": "https://admin.internal",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.InternalURL, check.Equals, "https://example.com")
+	c.Check(cfg.Server.AdminURL, check.Equals, "https://admin.internal")
+	c.Check(cfg.Server.Hosts, check.DeepEquals, []string{"a", "b"})
+	c.Check(cfg.Pool["x"].Replica, check.Equals, "db1")
+	c.Check(cfg.Pool["y"].Replica, check.Equals, "db3")
+
+	// The value is taken after the environment has been applied, and
+	// chains are followed.
+	cfg = config{}
+	err = ReadWithEnvMapInto(strings.NewReader(file), map[string]string{
+		"APPNAME_SERVER_PUBLIC_URL": "https://example.org",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.InternalURL, check.Equals, "https://example.org")
+	c.Check(cfg.Server.AdminURL, check.Equals, "https://example.org")
+	cfg.Server.Hosts[0] = "z"
+	c.Check(cfg.Client.Hosts, check.DeepEquals, []string{"a", "b"})
+
+	// Streamed subsections are filled in before they are delivered.
+	var replicas []string
+	cfg = config{}
+	err = ReadWithEnvMapInto(strings.NewReader(file), map[string]string{
+		"APPNAME_POOL_z_PRIMARY": "db4",
+	}, "APPNAME", &cfg, WithStreamedSection("pool", func(name string, sub interface{}) error {
+		replicas = append(replicas, name+"="+sub.(*pool).Replica)
+		return nil
+	}))
+	c.Assert(err, check.IsNil)
+	c.Check(replicas, check.DeepEquals, []string{"x=db1", "y=db3", "z=db4"})
+
+	var bad struct {
+		Server struct {
+			Port int
+			Alt  string `gcfgenv:"same-as=Port"`
+			Next string `gcfgenv:"same-as=Missing.Field"`
+		}
+	}
+	err = ReadWithEnvMapInto(strings.NewReader("[server]\nport = 80\n"), nil, "APPNAME", &bad)
+	c.Check(err, check.ErrorMatches, `Server.Alt cannot be the same as Port, which has type int, not string`)
+	err = ReadWithEnvMapInto(strings.NewReader("[server]\nalt = y\n"), nil, "APPNAME", &bad)
+	c.Check(err, check.ErrorMatches, `invalid same-as directive on Server.Next: "Missing.Field"`)
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// A SliceMode says how entries from an environment variable are combined with
+// those a slice field already has, e.g. from the file.
+type SliceMode int
+
+const (
+	// SliceAppend adds the entries after the existing ones, as gcfg does
+	// for repeated variables in the file. This is the default.
+	SliceAppend SliceMode = iota
+	// SliceReplace discards the existing entries.
+	SliceReplace
+	// SlicePrepend adds the entries before the existing ones.
+	SlicePrepend
+)
+
+func (m SliceMode) String() string {
+	for name, mode := range sliceModes {
+		if mode == m {
+			return name
+		}
+	}
+	return fmt.Sprintf("SliceMode(%d)", int(m))
+}
+
+// sliceModes maps the values of the gcfgenv:"slice=..." directive to modes.
+var sliceModes = map[string]SliceMode{
+	"append":  SliceAppend,
+	"replace": SliceReplace,
+	"prepend": SlicePrepend,
+}
+
+// WithSliceMode sets how entries from environment variables are combined
+// with the existing entries of slice fields, for fields without a
+// gcfgenv:"slice=..." directive of their own.
+func WithSliceMode(mode SliceMode) Option {
+	return func(o *options) {
+		o.sliceMode = mode
+	}
+}
+
+// fieldSliceMode returns the SliceMode for the field sf, from its
+// gcfgenv:"slice=..." directive ("append", "replace", or "prepend") or the
+// options.
+func (o *options) fieldSliceMode(sf reflect.StructField) (SliceMode, error) {
+	name, ok := parseFieldTag(sf)["slice"]
+	if !ok {
+		return o.sliceMode, nil
+	}
+	mode, ok := sliceModes[name]
+	if !ok {
+		return 0, fmt.Errorf("invalid slice mode %q for %s: must be \"append\", \"replace\", or \"prepend\"",
+			name, sf.Name)
+	}
+	return mode, nil
+}
+
+// setField stores v, converted from an environment variable, in the field f
+// described by sf, combining it with any existing entries if f is a slice.
+func (st *applyState) setField(sf reflect.StructField, f, v reflect.Value) error {
+	if f.Kind() != reflect.Slice {
+		f.Set(v)
+		return nil
+	}
+	mode, err := st.opts.fieldSliceMode(sf)
+	if err != nil {
+		return err
+	}
+	switch mode {
+	case SliceReplace:
+		f.Set(v)
+	case SlicePrepend:
+		f.Set(reflect.AppendSlice(v, f))
+	default:
+		f.Set(reflect.AppendSlice(f, v))
+	}
+	return nil
+}
+
+// WithSliceDelimiter sets the separator between the entries of slice fields
+// set by environment variables, instead of SliceDelimiter, for fields without
+// a delim struct tag of their own. An empty delimiter disables splitting, so
+// that each variable gives a single entry.
+//
+// Per-field delimiters use a separate struct tag, so that they can contain
+// spaces, e.g. delim:";" or delim:"\n" (or delim:"" to disable splitting).
+func WithSliceDelimiter(delim string) Option {
+	return func(o *options) {
+		o.customDelimiter = true
+		o.sliceDelimiter = delim
+	}
+}
+
+// fieldDelimiter returns the separator between entries for the field sf, from
+// its delim struct tag or the options.
+func (o *options) fieldDelimiter(sf reflect.StructField) string {
+	if delim, ok := sf.Tag.Lookup("delim"); ok {
+		return delim
+	}
+	if o.customDelimiter {
+		return o.sliceDelimiter
+	}
+	return SliceDelimiter
+}
+
+// splitEntries splits val into the entries of a slice, or returns it as a
+// single entry if delim is empty.
+func splitEntries(val, delim string) []string {
+	if delim == "" {
+		return []string{val}
+	}
+	return strings.Split(val, delim)
+}
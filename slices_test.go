@@ -0,0 +1,136 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestSliceModes(c *check.C) {
+	type sec struct {
+		Hosts    []string
+		Replace  []string `gcfgenv:"slice=replace"`
+		Prepend  []*int   `gcfgenv:"slice=prepend"`
+		Fallback []string `gcfgenv:"slice=append"`
+	}
+	type config struct {
+		Sec    sec
+		Subsec map[string]*sec
+	}
+	src := `[sec]
+hosts = a
+replace = a
+prepend = 1
+fallback = a
+
+[subsec "k1"]
+hosts = a
+replace = a
+`
+	env := map[string]string{
+		"APPNAME_SEC_HOSTS":         "b,c",
+		"APPNAME_SEC_REPLACE":       "b,c",
+		"APPNAME_SEC_PREPEND":       "2",
+		"APPNAME_SEC_FALLBACK":      "b",
+		"APPNAME_SUBSEC_k1_HOSTS":   "b",
+		"APPNAME_SUBSEC_k1_REPLACE": "b",
+		"APPNAME_SUBSEC_k2_REPLACE": "b",
+	}
+	one, two := 1, 2
+
+	var err error
+	cfg := config{}
+	err = readWithMapInto(strings.NewReader(src), env, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{
+		Sec: sec{
+			Hosts:    []string{"a", "b", "c"},
+			Replace:  []string{"b", "c"},
+			Prepend:  []*int{&two, &one},
+			Fallback: []string{"a", "b"},
+		},
+		Subsec: map[string]*sec{
+			"k1": {Hosts: []string{"a", "b"}, Replace: []string{"b"}},
+			"k2": {Replace: []string{"b"}},
+		},
+	})
+
+	// The option applies to fields without a directive.
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(src), env, "APPNAME", &cfg, WithSliceMode(SliceReplace))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Hosts, check.DeepEquals, []string{"b", "c"})
+	c.Check(cfg.Sec.Fallback, check.DeepEquals, []string{"a", "b"})
+	c.Check(cfg.Subsec["k1"].Hosts, check.DeepEquals, []string{"b"})
+
+	type badConfig struct {
+		Sec struct {
+			Hosts []string `gcfgenv:"slice=merge"`
+		}
+	}
+	bad := badConfig{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{
+		"APPNAME_SEC_HOSTS": "a",
+	}, "APPNAME", &bad)
+	c.Check(err, check.ErrorMatches, `invalid slice mode "merge" for Hosts: must be "append", "replace", or "prepend"`)
+}
+
+func (s *Suite) TestSliceDelimiter(c *check.C) {
+	type sec struct {
+		Hosts   []string
+		DSNs    []string `delim:";"`
+		Names   []string `delim:"\n"`
+		Whole   []string `delim:""`
+		Flags   []bool   `delim:" "`
+		Weights []*int   `delim:"|"`
+	}
+	type config struct {
+		Sec    sec
+		Subsec map[string]*sec
+	}
+	env := map[string]string{
+		"APPNAME_SEC_HOSTS":      "a,b",
+		"APPNAME_SEC_DSNS":       "host=a,b port=1;host=c",
+		"APPNAME_SEC_NAMES":      "Smith, Jane\nDoe, John",
+		"APPNAME_SEC_WHOLE":      "a,b;c",
+		"APPNAME_SEC_FLAGS":      "true false",
+		"APPNAME_SEC_WEIGHTS":    "1|2",
+		"APPNAME_SUBSEC_k1_DSNS": "a;b",
+	}
+	one, two := 1, 2
+
+	var err error
+	cfg := config{}
+	err = readWithMapInto(strings.NewReader(""), env, "APPNAME", &cfg, WithStrictBooleans())
+	c.Assert(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{
+		Sec: sec{
+			Hosts:   []string{"a", "b"},
+			DSNs:    []string{"host=a,b port=1", "host=c"},
+			Names:   []string{"Smith, Jane", "Doe, John"},
+			Whole:   []string{"a,b;c"},
+			Flags:   []bool{true, false},
+			Weights: []*int{&one, &two},
+		},
+		Subsec: map[string]*sec{
+			"k1": {DSNs: []string{"a", "b"}},
+		},
+	})
+
+	// The option applies to fields without a tag.
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), env, "APPNAME", &cfg, WithSliceDelimiter(";"))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Hosts, check.DeepEquals, []string{"a,b"})
+	c.Check(cfg.Sec.Whole, check.DeepEquals, []string{"a,b;c"})
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{
+		"APPNAME_SEC_HOSTS": "a,b;c",
+	}, "APPNAME", &cfg, WithSliceDelimiter(""))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Hosts, check.DeepEquals, []string{"a,b;c"})
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/gcfg.v1/scanner"
+	"gopkg.in/gcfg.v1/token"
+	"gopkg.in/warnings.v0"
+)
+
+// A SubsectionFunc receives a subsection of a streamed section: its name, and
+// a pointer to the subsection struct (e.g. a *TenantConfig). The struct is
+// not used after the function returns.
+type SubsectionFunc func(name string, subsection interface{}) error
+
+// WithStreamedSection delivers the subsections of section (which must be a
+// map[string]*struct field) to fn one at a time, as they are parsed and
+// overridden from the environment, rather than storing them in the config
+// struct. This bounds memory use for files with many thousands of
+// subsections, such as per-tenant entries.
+//
+// Subsections are delivered in the order they appear in the file, followed by
+// any created by environment variables in sorted order. Each subsection must
+// appear only once in the file. Default values from a Default_ field are
+// applied as usual, and each subsection is validated before it is delivered,
+// so the read fails at the first invalid one. Directives in other sections
+// that look at the whole config struct (such as required_if or ref) do not
+// see streamed subsections, and neither do options such as WithRequired.
+func WithStreamedSection(section string, fn SubsectionFunc) Option {
+	return func(o *options) {
+		o.streamSection = section
+		o.streamFunc = fn
+	}
+}
+
+// streamBlock is the text of a single subsection of a streamed section.
+type streamBlock struct {
+	name string
+	src  []byte
+}
+
+// splitStreamedSection removes the streamed section from src, returning the
+// rest of the file and the text of each subsection.
+func splitStreamedSection(src []byte, sf reflect.StructField) ([]byte, []streamBlock, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	var rest []byte
+	var blocks []streamBlock
+	seen := make(map[string]bool)
+	// start is the offset of the current section header, and in is true if
+	// it belongs to the streamed section.
+	start, in := 0, false
+	flush := func(end int) {
+		if in {
+			blocks[len(blocks)-1].src = src[start:end]
+		} else {
+			rest = append(rest, src[start:end]...)
+		}
+	}
+	pos, tok, lit := s.Scan()
+	for tok != token.EOF {
+		if tok != token.LBRACK {
+			pos, tok, lit = s.Scan()
+			continue
+		}
+		off := file.Offset(pos)
+		flush(off)
+		start, in = off, false
+		pos, tok, lit = s.Scan()
+		if tok != token.IDENT || envName(lit) != fieldToEnvVar(sf) {
+			continue
+		}
+		name := ""
+		pos, tok, lit = s.Scan()
+		if tok == token.STRING {
+			name = unquote(lit)
+		}
+		if seen[name] {
+			return nil, nil, fmt.Errorf("subsection %q of streamed section %q appears more than once",
+				name, fieldSectionName(sf))
+		}
+		seen[name] = true
+		in = true
+		blocks = append(blocks, streamBlock{name: name})
+	}
+	flush(len(src))
+	return rest, blocks, nil
+}
+
+// streamWrapper returns a new config struct holding only the streamed section
+// field and its Default_ field, if any.
+func streamWrapper(ref reflect.Value, i int) reflect.Value {
+	sf := ref.Type().Field(i)
+	fields := []reflect.StructField{{Name: sf.Name, Type: sf.Type, Tag: sf.Tag}}
+	defaults := ref.FieldByName("Default_" + sf.Name)
+	if defaults.IsValid() {
+		fields = append(fields, reflect.StructField{Name: "Default_" + sf.Name, Type: defaults.Type()})
+	}
+	wrapper := reflect.New(reflect.StructOf(fields)).Elem()
+	if defaults.IsValid() {
+		wrapper.Field(1).Set(defaults)
+	}
+	return wrapper
+}
+
+// streamSection reads each block into its own wrapper, applies the
+// environment, and delivers the result to the streaming callback.
+func (st *applyState) streamSection(ref reflect.Value, i int, blocks []streamBlock, prefix string, env map[string]string, upstreamErr error) error {
+	sf := ref.Type().Field(i)
+	secPrefix := prefix + st.opts.naming.field(sf)
+	subsecType := sf.Type.Elem().Elem()
+	defaults := ref.FieldByName("Default_" + sf.Name)
+	for _, b := range blocks {
+		wrapper := streamWrapper(ref, i)
+		err := gcfg.ReadInto(wrapper.Addr().Interface(), bytes.NewReader(b.src))
+		if gcfg.FatalOnly(err) != nil {
+			return err
+		}
+		// Lines within a block are not those of the file, so they are
+		// left out.
+		upstreamErr = appendWarnings(upstreamErr, warnings.WarningsOnly(structureWarnings(err, nil)))
+		// Only pass on the variables for this subsection, so that
+		// none are mistaken for new subsections.
+		subsecEnv := make(map[string]string)
+		key := secPrefix + Separator
+		if b.name != "" {
+			key += b.name + Separator
+		}
+		for _, ef := range st.opts.naming.envFields(subsecType) {
+			for _, tail := range fieldVarTails(st.opts.naming, ef) {
+				if v, found := env[key+tail]; found {
+					subsecEnv[key+tail] = v
+				}
+			}
+		}
+		err = st.finishStreamed(ref, wrapper, secPrefix, defaults, subsecEnv, prefix, env)
+		if err != nil {
+			return err
+		}
+		sub := wrapper.Field(0).MapIndex(reflect.ValueOf(b.name))
+		if err := st.opts.streamFunc(b.name, sub.Interface()); err != nil {
+			return err
+		}
+	}
+	// Finally, deliver any subsections created by the environment.
+	created := make(map[string]string)
+	for _, e := range st.index.withPrefix(secPrefix + "_") {
+		if !st.used[e] {
+			created[e] = env[e]
+		}
+	}
+	wrapper := streamWrapper(ref, i)
+	err := st.finishStreamed(ref, wrapper, secPrefix, defaults, created, prefix, env)
+	if err != nil {
+		return err
+	}
+	subsecs := wrapper.Field(0)
+	for _, k := range sortedKeys(subsecs) {
+		if err := st.opts.streamFunc(k, subsecs.MapIndex(reflect.ValueOf(k)).Interface()); err != nil {
+			return err
+		}
+	}
+	return upstreamErr
+}
+
+// finishStreamed applies the environment to a wrapper from streamWrapper for
+// the config struct ref, fills in generated and default values, and validates
+// the result, as finish does for sections that are not streamed.
+func (st *applyState) finishStreamed(ref, wrapper reflect.Value, secPrefix string, defaults reflect.Value, subsecEnv map[string]string, prefix string, env map[string]string) error {
+	err := setSubsectionsWithEnvMap(st, wrapper.Field(0), secPrefix, defaults, newEnvIndex(subsecEnv, ""))
+	if err != nil {
+		return err
+	}
+	err = st.applyDSNOverrides(wrapper, prefix, env)
+	if err != nil {
+		return err
+	}
+	err = st.opts.generateValues(wrapper)
+	if err != nil {
+		return err
+	}
+	err = fillDefaultPorts(wrapper)
+	if err != nil {
+		return err
+	}
+	err = fillDecimalScales(wrapper)
+	if err != nil {
+		return err
+	}
+	err = fillSameAs(ref, wrapper)
+	if err != nil {
+		return err
+	}
+	return st.validateStreamed(ref, wrapper)
+}
+
+// streamedSectionIndex returns the index of the streamed section field in the
+// config struct type t.
+func streamedSectionIndex(t reflect.Type, section string) (int, error) {
+	i := sectionFieldIndex(t, section)
+	if i < 0 || !isSubsectionMap(t.Field(i).Type) {
+		return -1, fmt.Errorf("streamed section %q must be a map[string]*struct field", section)
+	}
+	return i, nil
+}
@@ -0,0 +1,216 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/warnings.v0"
+)
+
+func (s *Suite) TestStreamedSection(c *check.C) {
+	type tenant struct {
+		Name  string `gcfgenv:"transform=upper"`
+		Quota int
+		Tags  []string
+	}
+	type config struct {
+		Server struct {
+			Listen string
+		}
+		Tenant         map[string]*tenant
+		Default_Tenant tenant
+	}
+
+	var err error
+	var names []string
+	var got []tenant
+	collect := func(name string, sub interface{}) error {
+		names = append(names, name)
+		got = append(got, *sub.(*tenant))
+		return nil
+	}
+	cfg := config{Default_Tenant: tenant{Quota: 10}}
+	r := strings.NewReader(`[tenant "b"]
+name = bravo
+tags = x
+[server]
+listen = :80
+[tenant "a"]
+name = alpha
+quota = 5
+`)
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_TENANT_a_QUOTA": "6",
+		"APPNAME_TENANT_b_TAGS":  "y",
+		"APPNAME_TENANT_c_NAME":  "charlie",
+		"APPNAME_SERVER_LISTEN":  ":81",
+	}, "APPNAME", &cfg, WithStreamedSection("tenant", collect), WithStrict())
+	c.Check(warnings.WarningsOnly(err), check.DeepEquals, []error{
+		&NewSubsectionError{Name: "APPNAME_TENANT_c_NAME", Subsection: "c"},
+	})
+	c.Check(cfg.Server.Listen, check.Equals, ":81")
+	c.Check(cfg.Tenant, check.HasLen, 0)
+	c.Check(names, check.DeepEquals, []string{"b", "a", "c"})
+	c.Check(got, check.DeepEquals, []tenant{
+		{Name: "BRAVO", Quota: 10, Tags: []string{"x", "y"}},
+		{Name: "ALPHA", Quota: 6},
+		{Name: "CHARLIE", Quota: 10},
+	})
+
+	// Errors from the callback stop reading.
+	cfg = config{}
+	r = strings.NewReader("[tenant \"a\"]\n[tenant \"b\"]\n")
+	calls := 0
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithStreamedSection("tenant", func(name string, sub interface{}) error {
+			calls++
+			return fmt.Errorf("no room for %s", name)
+		}))
+	c.Check(err, check.ErrorMatches, "no room for a")
+	c.Check(calls, check.Equals, 1)
+
+	cfg = config{}
+	r = strings.NewReader("[tenant \"a\"]\n[server]\n[tenant \"a\"]\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithStreamedSection("tenant", collect))
+	c.Check(err, check.ErrorMatches,
+		`subsection "a" of streamed section "Tenant" appears more than once`)
+
+	err = readWithMapInto(strings.NewReader(""), map[string]string{}, "APPNAME", &cfg,
+		WithStreamedSection("server", collect))
+	c.Check(err, check.ErrorMatches,
+		`streamed section "server" must be a map\[string\]\*struct field`)
+}
+
+type streamedCommon struct {
+	Region string
+}
+
+func (s *Suite) TestStreamedSectionFields(c *check.C) {
+	type tenant struct {
+		streamedCommon
+		Name   string
+		Limits struct {
+			Quota int
+		}
+	}
+	type config struct {
+		Tenant map[string]*tenant
+	}
+
+	var names []string
+	var got []tenant
+	collect := func(name string, sub interface{}) error {
+		names = append(names, name)
+		got = append(got, *sub.(*tenant))
+		return nil
+	}
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader("[tenant \"a\"]\nname = alpha\n"), map[string]string{
+		"APPNAME_TENANT_a_LIMITS_QUOTA": "5",
+		"APPNAME_TENANT_a_REGION":       "eu",
+	}, "APPNAME", &cfg, WithStreamedSection("tenant", collect), WithStrict())
+	c.Assert(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"a"})
+	c.Check(got[0].Name, check.Equals, "alpha")
+	c.Check(got[0].Limits.Quota, check.Equals, 5)
+	c.Check(got[0].Region, check.Equals, "eu")
+}
+
+func (s *Suite) TestStreamedSectionValidation(c *check.C) {
+	type tenant struct {
+		Owner string   `gcfgenv:"required"`
+		Tags  []string `gcfgenv:"maxitems=1"`
+		Plan  string   `gcfgenv:"required_if=Server.Billing"`
+	}
+	type config struct {
+		Server struct {
+			Billing bool
+		}
+		Tenant map[string]*tenant
+	}
+
+	var names []string
+	collect := func(name string, sub interface{}) error {
+		names = append(names, name)
+		return nil
+	}
+	read := func(src string, env map[string]string) error {
+		names = nil
+		cfg := config{}
+		return readWithMapInto(strings.NewReader(src), env, "APPNAME", &cfg,
+			WithStreamedSection("tenant", collect))
+	}
+
+	err := read("[tenant \"a\"]\nowner = ann\n[tenant \"b\"]\ntags = x\n", map[string]string{})
+	c.Check(err, check.ErrorMatches,
+		"Tenant.b.Owner is required, but is not set in the configuration file or by APPNAME_TENANT_b_OWNER")
+	c.Check(names, check.DeepEquals, []string{"a"})
+
+	err = read("[tenant \"a\"]\nowner = ann\n", map[string]string{"APPNAME_TENANT_c_TAGS": "x"})
+	c.Check(err, check.ErrorMatches, "Tenant.c.Owner is required, .*")
+	c.Check(names, check.DeepEquals, []string{"a"})
+
+	err = read("[tenant \"a\"]\nowner = ann\ntags = x\ntags = y\n", map[string]string{})
+	c.Check(err, check.ErrorMatches, "Tenant.a.Tags must have at most 1 entries, but has 2")
+
+	// References to other sections are resolved in the whole config.
+	err = read("[server]\nbilling = true\n[tenant \"a\"]\nowner = ann\n", map[string]string{})
+	c.Check(err, check.ErrorMatches, "Tenant.a.Plan is required when Server.Billing is set")
+	err = read("[server]\nbilling = true\n[tenant \"a\"]\nowner = ann\nplan = gold\n", map[string]string{})
+	c.Check(err, check.IsNil)
+	c.Check(names, check.DeepEquals, []string{"a"})
+}
+
+func benchmarkTenants(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("[server]\nlisten = :80\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "[tenant \"t%d\"]\nname = tenant %d\nquota = %d\n", i, i, i)
+	}
+	return buf.Bytes()
+}
+
+type benchTenant struct {
+	Name  string
+	Quota int
+}
+
+type benchConfig struct {
+	Server struct {
+		Listen string
+	}
+	Tenant map[string]*benchTenant
+}
+
+func BenchmarkTenantsMap(b *testing.B) {
+	src := benchmarkTenants(5000)
+	env := map[string]string{"APPNAME_TENANT_t42_QUOTA": "1"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cfg := benchConfig{}
+		if err := readWithMapInto(bytes.NewReader(src), env, "APPNAME", &cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTenantsStreamed(b *testing.B) {
+	src := benchmarkTenants(5000)
+	env := map[string]string{"APPNAME_TENANT_t42_QUOTA": "1"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cfg := benchConfig{}
+		err := readWithMapInto(bytes.NewReader(src), env, "APPNAME", &cfg,
+			WithStreamedSection("tenant", func(string, interface{}) error { return nil }))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
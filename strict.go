@@ -0,0 +1,167 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/warnings.v0"
+)
+
+// An UnknownEnvVarError is a warning about an environment variable that looks
+// like an override (because it has the prefix, or names a section) but does
+// not correspond to any field.
+type UnknownEnvVarError struct {
+	// Name is the name of the environment variable.
+	Name string
+	// Section and Subsection identify the existing section or subsection
+	// the variable appears to refer to, if any.
+	Section    string
+	Subsection string
+}
+
+func (e *UnknownEnvVarError) Error() string {
+	msg := "unknown environment variable " + e.Name
+	if e.Section != "" {
+		msg += fmt.Sprintf(" for section %q", e.Section)
+	}
+	if e.Subsection != "" {
+		msg += fmt.Sprintf(", subsection %q", e.Subsection)
+	}
+	return msg
+}
+
+// A NewSubsectionError reports an environment variable that created a
+// subsection that did not already exist. It is a warning when WithStrict is
+// used, and an error when WithStrictSubsections is used.
+type NewSubsectionError struct {
+	// Name is the name of the environment variable.
+	Name string
+	// Subsection is the name of the new subsection.
+	Subsection string
+}
+
+func (e *NewSubsectionError) Error() string {
+	return fmt.Sprintf("environment variable %s creates new subsection %q",
+		e.Name, e.Subsection)
+}
+
+// WithStrict reports environment variables that look like overrides but do
+// not correspond to any field (as *UnknownEnvVarError), those that create new
+// subsections (as *NewSubsectionError), fields whose environment variable
+// names collide (as *NameCollisionError), and configuration files with a byte
+// order mark or an encoding other than UTF-8 (as *EncodingError). Like gcfg's
+// warnings about unknown variables in the configuration file, these are not
+// fatal and are removed by gcfg.FatalOnly.
+//
+// With an empty prefix, only variables that begin with the name of a section
+// are considered, and any that were applied are reported together as an
+// *EmptyPrefixError.
+func WithStrict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithStrictSubsections prevents environment variables from creating new
+// subsections, returning a *NewSubsectionError instead. This guards against
+// typos in subsection names silently creating unused entries.
+func WithStrictSubsections() Option {
+	return func(o *options) {
+		o.strictSubsections = true
+	}
+}
+
+// WithMaxNewSubsections limits the number of new subsections that
+// environment variables may create in one read to n, returning an error
+// instead of creating any more. This protects against pathological
+// environments (or another program's variables sharing the prefix) that
+// would otherwise fill maps with thousands of unwanted entries.
+func WithMaxNewSubsections(n int) Option {
+	return func(o *options) {
+		o.limitSubsections = true
+		o.maxNewSubsections = n
+	}
+}
+
+// warnings returns the warnings for WithStrict, sorted by variable name.
+func (st *applyState) warnings(ref reflect.Value, prefix string) []error {
+	var out []error
+	if warn := st.emptyPrefixWarning(); warn != nil {
+		out = append(out, warn)
+	}
+	for _, e := range st.index.withPrefix(prefix) {
+		if st.used[e] {
+			continue
+		}
+		unknown := classifyEnvVar(ref, prefix, st.opts.naming, e)
+		if unknown.Section == "" && prefix == "" {
+			// Not one of ours.
+			continue
+		}
+		out = append(out, unknown)
+	}
+	for _, created := range st.newSubsections {
+		out = append(out, created)
+	}
+	return append(out, collisions(ref.Type(), st.opts.naming)...)
+}
+
+// classifyEnvVar works out which existing section and subsection of ref the
+// environment variable name appears to refer to.
+func classifyEnvVar(ref reflect.Value, prefix string, n naming, name string) *UnknownEnvVarError {
+	out := &UnknownEnvVarError{Name: name}
+	secPrefix := ""
+	refType := ref.Type()
+	for i := 0; i < refType.NumField(); i++ {
+		sf := refType.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		p := prefix + n.field(sf) + "_"
+		if !strings.HasPrefix(name, p) || len(p) <= len(secPrefix) {
+			continue
+		}
+		secPrefix = p
+		out.Section = fieldSectionName(sf)
+		out.Subsection = ""
+		if !isSubsectionMap(sf.Type) {
+			continue
+		}
+		// Try each possible subsection name, rather than each
+		// subsection, since there may be many of them.
+		sec := ref.Field(i)
+		rest := strings.TrimPrefix(name, p)
+		for j := len(rest) - 1; j > 0; j-- {
+			if rest[j] != '_' {
+				continue
+			}
+			if sec.MapIndex(reflect.ValueOf(rest[:j])).IsValid() {
+				out.Subsection = rest[:j]
+				break
+			}
+		}
+	}
+	return out
+}
+
+// appendWarnings adds warnings to err, which must be nil or the result of a
+// gcfg read.
+func appendWarnings(err error, warns []error) error {
+	if len(warns) == 0 {
+		return err
+	}
+	if err == nil {
+		return warnings.List{Warnings: warns}
+	}
+	list, ok := err.(warnings.List)
+	if !ok {
+		// A fatal error that gcfg did not put in a list.
+		return warnings.List{Warnings: warns, Fatal: err}
+	}
+	list.Warnings = append(list.Warnings, warns...)
+	return list
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/warnings.v0"
+)
+
+func (s *Suite) TestStrict(c *check.C) {
+	type sec struct {
+		Field string
+	}
+	type config struct {
+		Sec1 sec
+		Sec2 map[string]*sec
+	}
+
+	var err error
+	configString := `[sec2 "k1"]
+field = value
+`
+	configEnvVars := map[string]string{
+		"APPNAME_SEC1_FIELD":    "set",
+		"APPNAME_SEC1_FEILD":    "typo",
+		"APPNAME_SEC2_k1_FIELD": "set",
+		"APPNAME_SEC2_k1_OTHER": "typo",
+		"APPNAME_SEC2_k2_FIELD": "set",
+		"APPNAME_SEC2_k3_OTHER": "typo",
+		"APPNAME_SEC3_FIELD":    "typo",
+		"OTHERAPP_SEC1_FIELD":   "unrelated",
+	}
+
+	// Unknown variables are ignored by default.
+	cfg := config{}
+	r := strings.NewReader(configString)
+	err = readWithMapInto(r, configEnvVars, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	cfg = config{}
+	r = strings.NewReader(configString)
+	err = readWithMapInto(r, configEnvVars, "APPNAME", &cfg, WithStrict())
+	c.Check(gcfg.FatalOnly(err), check.IsNil)
+	c.Check(warnings.WarningsOnly(err), check.DeepEquals, []error{
+		&UnknownEnvVarError{Name: "APPNAME_SEC1_FEILD", Section: "Sec1"},
+		&UnknownEnvVarError{Name: "APPNAME_SEC2_k1_OTHER", Section: "Sec2", Subsection: "k1"},
+		&UnknownEnvVarError{Name: "APPNAME_SEC2_k3_OTHER", Section: "Sec2"},
+		&UnknownEnvVarError{Name: "APPNAME_SEC3_FIELD"},
+		&NewSubsectionError{Name: "APPNAME_SEC2_k2_FIELD", Subsection: "k2"},
+	})
+	c.Check(cfg, check.DeepEquals, config{
+		Sec1: sec{"set"},
+		Sec2: map[string]*sec{"k1": {"set"}, "k2": {"set"}},
+	})
+
+	// With an empty prefix, only variables naming a section are reported.
+	cfg = config{}
+	r = strings.NewReader("")
+	err = readWithMapInto(r, map[string]string{
+		"SEC1_FEILD": "typo",
+		"PATH":       "/usr/bin",
+	}, "", &cfg, WithStrict())
+	c.Check(warnings.WarningsOnly(err), check.DeepEquals, []error{
+		&UnknownEnvVarError{Name: "SEC1_FEILD", Section: "Sec1"},
+	})
+
+	// Warnings are added to those from gcfg.
+	cfg = config{}
+	r = strings.NewReader("[sec1]\nother = value\n")
+	err = readWithMapInto(r, map[string]string{
+		"SEC1_FEILD": "typo",
+	}, "", &cfg, WithStrict())
+	c.Check(warnings.WarningsOnly(err), check.HasLen, 2)
+
+	cfg = config{}
+	r = strings.NewReader(configString)
+	err = readWithMapInto(r, configEnvVars, "APPNAME", &cfg,
+		WithStrictSubsections())
+	c.Check(err, check.ErrorMatches,
+		"environment variable APPNAME_SEC2_k2_FIELD creates new subsection \"k2\"")
+	c.Check(cfg.Sec2, check.HasLen, 1)
+}
+
+func (s *Suite) TestMaxNewSubsections(c *check.C) {
+	type sub struct {
+		Field string
+	}
+	type config struct {
+		Sec map[string]*sub
+	}
+	env := map[string]string{
+		"APP_SEC_a_FIELD": "1",
+		"APP_SEC_b_FIELD": "2",
+		"APP_SEC_c_FIELD": "3",
+	}
+	cfg := config{Sec: map[string]*sub{"a": {}}}
+	err := readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithMaxNewSubsections(2))
+	c.Check(err, check.IsNil)
+	c.Check(cfg.Sec, check.HasLen, 3)
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithMaxNewSubsections(2))
+	c.Check(err, check.ErrorMatches,
+		"environment variable APP_SEC_[abc]_FIELD would create more than 2 new subsections")
+	c.Check(ErrorCode(err), check.Equals, CodeTooManySubsections)
+	c.Check(cfg.Sec, check.HasLen, 2)
+
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader(""), env, "APP", &cfg, WithMaxNewSubsections(0))
+	c.Check(err, check.ErrorMatches, ".* would create more than 0 new subsections")
+	c.Check(cfg.Sec, check.HasLen, 0)
+}
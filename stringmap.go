@@ -0,0 +1,117 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/warnings.v0"
+)
+
+// isStringMapSection reports whether t is a map[string]string, which holds a
+// section of free-form variables, such as labels or annotations:
+//
+//	[labels]
+//	team = payments
+//	tier = gold
+//
+// gcfg cannot read these, so they are hidden from it (see shadowNested) and
+// filled by fillStringMaps. Keys from the file are lowercase, as with
+// RawSections.
+func isStringMapSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String &&
+		t.Elem().Kind() == reflect.String
+}
+
+// fillStringMaps populates the map[string]string sections of ref from the
+// variables in src, and removes gcfg's warnings about those sections from
+// upstreamErr, since their data is no longer discarded. If a variable is
+// given more than once, the last value is kept.
+func fillStringMaps(ref reflect.Value, src []byte, upstreamErr error) error {
+	refType := ref.Type()
+	captured := make(map[int]bool)
+	for _, e := range scanGcfg(src) {
+		i := sectionFieldIndex(refType, e.Section)
+		if i < 0 || !isStringMapSection(refType.Field(i).Type) || !ref.Field(i).CanSet() {
+			continue
+		}
+		if e.Subsection != "" {
+			return fmt.Errorf("section %q cannot have subsections", e.Section)
+		}
+		captured[i] = true
+		m := ref.Field(i)
+		if m.IsNil() {
+			m.Set(reflect.MakeMap(m.Type()))
+		}
+		if e.Name != "" {
+			setStringMapValue(m, strings.ToLower(e.Name), e.Value)
+		}
+	}
+	if len(captured) == 0 {
+		return upstreamErr
+	}
+	list, ok := upstreamErr.(warnings.List)
+	if !ok {
+		return upstreamErr
+	}
+	var remaining []error
+	for _, w := range list.Warnings {
+		var u *UnknownNameError
+		if errors.As(w, &u) && captured[sectionFieldIndex(refType, u.Section)] {
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+	if len(remaining) == 0 && list.Fatal == nil {
+		return nil
+	}
+	list.Warnings = remaining
+	return list
+}
+
+// setStringMapWithEnvMap applies overrides from env to the map[string]string
+// section sec. Each variable named secPrefix_NAME sets the key whose
+// environment variable form is NAME, if there is one, and otherwise adds the
+// key NAME in lowercase.
+func setStringMapWithEnvMap(st *applyState, sec reflect.Value, secPrefix string, env *envIndex) error {
+	names := env.withPrefix(secPrefix + Separator)
+	if len(names) == 0 {
+		return nil
+	}
+	// Keys are visited in sorted order so that, should two of them have
+	// the same environment variable form, the first always wins.
+	keys := make(map[string]string)
+	for _, k := range sortedKeys(sec) {
+		name := st.opts.naming.name(k)
+		if _, ok := keys[name]; !ok {
+			keys[name] = k
+		}
+	}
+	if sec.IsNil() {
+		sec.Set(reflect.MakeMap(sec.Type()))
+	}
+	for _, e := range names {
+		name := strings.TrimPrefix(e, secPrefix+Separator)
+		if name == "" {
+			continue
+		}
+		key, ok := keys[name]
+		if !ok {
+			key = strings.ToLower(name)
+		}
+		setStringMapValue(sec, key, env.vars[e])
+		st.used[e] = true
+	}
+	return nil
+}
+
+// setStringMapValue sets key to val in the map m, whose key and element types
+// may be named string types.
+func setStringMapValue(m reflect.Value, key, val string) {
+	t := m.Type()
+	m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), reflect.ValueOf(val).Convert(t.Elem()))
+}
@@ -0,0 +1,71 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"os"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestStringMapSections(c *check.C) {
+	type labels map[string]string
+	type config struct {
+		Server struct {
+			Listen string
+		}
+		Labels      map[string]string
+		Annotations labels
+	}
+	file := `[server]
+listen = :80
+[labels]
+Team = payments
+app-tier = gold
+app-tier = silver
+[labels]
+empty
+`
+	var cfg config
+	err := ReadWithEnvMapInto(strings.NewReader(file), map[string]string{
+		"APPNAME_LABELS_APP_TIER":      "platinum",
+		"APPNAME_LABELS_REGION":        "eu",
+		"APPNAME_ANNOTATIONS_OWNER_ID": "42",
+	}, "APPNAME", &cfg, WithStrict())
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Server.Listen, check.Equals, ":80")
+	c.Check(cfg.Labels, check.DeepEquals, map[string]string{
+		"team":     "payments",
+		"app-tier": "platinum",
+		"empty":    "",
+		"region":   "eu",
+	})
+	c.Check(cfg.Annotations, check.DeepEquals, labels{"owner_id": "42"})
+
+	// Other warnings are kept.
+	cfg = config{}
+	err = ReadWithEnvMapInto(strings.NewReader("[labels]\na = b\n[cache]\nsize = 1\n"), nil, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `(?s).*can't store data at section "cache".*`)
+	c.Check(cfg.Labels, check.DeepEquals, map[string]string{"a": "b"})
+
+	err = ReadWithEnvMapInto(strings.NewReader("[labels \"x\"]\na = b\n"), nil, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `section "labels" cannot have subsections`)
+
+	// Sections are created by the environment alone, with ApplyEnvInto as
+	// well, and lowercase names work too.
+	os.Setenv("appname_labels_team", "search")
+	defer os.Unsetenv("appname_labels_team")
+	cfg = config{}
+	c.Assert(ApplyEnvInto("appname", &cfg, WithLowercaseNames()), check.IsNil)
+	c.Check(cfg.Labels, check.DeepEquals, map[string]string{"team": "search"})
+
+	vars, err := EnvVarsFor(&config{}, "APPNAME")
+	c.Assert(err, check.IsNil)
+	c.Check(vars, check.DeepEquals, []EnvVarSpec{
+		{"APPNAME_SERVER_LISTEN", "string", "Server.Listen", nil},
+		{"APPNAME_LABELS_<name>", "string", "Labels.<name>", nil},
+		{"APPNAME_ANNOTATIONS_<name>", "string", "Annotations.<name>", nil},
+	})
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+const (
+	// Separator joins the prefix, section, subsection, and variable names
+	// that make up an environment variable name, and replaces dashes in
+	// section and variable names.
+	Separator = "_"
+
+	// SliceDelimiter separates the entries of slice fields set by
+	// environment variables.
+	SliceDelimiter = ","
+)
+
+// TrueValues returns the spellings accepted for true by boolean fields, in
+// the file or the environment. They are matched case-insensitively, and a
+// variable given in the file without a value is also true.
+func TrueValues() []string {
+	return []string{"true", "yes", "on", "1"}
+}
+
+// FalseValues returns the spellings accepted for false by boolean fields,
+// matched case-insensitively.
+func FalseValues() []string {
+	return []string{"false", "no", "off", "0"}
+}
+
+// StrictBoolValues returns the spellings accepted by WithStrictBooleans when
+// it is given none of its own.
+func StrictBoolValues() []string {
+	return []string{"true", "false"}
+}
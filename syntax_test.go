@@ -0,0 +1,37 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/gcfg.v1/types"
+)
+
+func (s *Suite) TestSyntaxConstants(c *check.C) {
+	// The exported spellings must match what gcfg actually accepts.
+	c.Check(len(types.BoolValues), check.Equals, len(TrueValues())+len(FalseValues()))
+	for _, v := range TrueValues() {
+		c.Check(types.BoolValues[v], check.Equals, true, check.Commentf(v))
+	}
+	for _, v := range FalseValues() {
+		c.Check(types.BoolValues[v], check.Equals, false, check.Commentf(v))
+	}
+
+	c.Check(EnvVarName("APP", "sec", "sub", "my-field"), check.Equals,
+		strings.Join([]string{"APP", "SEC", "sub", "MY" + Separator + "FIELD"}, Separator))
+
+	cfg := struct {
+		Sec struct {
+			Flags []bool
+		}
+	}{}
+	env := map[string]string{
+		"APP_SEC_FLAGS": strings.Join(append(TrueValues(), FalseValues()...), SliceDelimiter),
+	}
+	err := readWithMapInto(strings.NewReader(""), env, "APP", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Flags, check.DeepEquals, []bool{true, true, true, true, false, false, false, false})
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldTag holds the directives in a field's "gcfgenv" struct tag. Directives
+// are separated by spaces, and are either flags or key=value pairs, e.g.
+//
+//	gcfgenv:"transform=trim,lower required"
+type fieldTag map[string]string
+
+// fieldTags caches parsed tags by their text, since the same fields are
+// examined by several passes on every read.
+var fieldTags sync.Map
+
+// parseFieldTag returns the directives in the field's gcfgenv tag. The result
+// is shared, and must not be modified.
+func parseFieldTag(sf reflect.StructField) fieldTag {
+	text := sf.Tag.Get("gcfgenv")
+	if text == "" {
+		return nil
+	}
+	if t, ok := fieldTags.Load(text); ok {
+		return t.(fieldTag)
+	}
+	t := make(fieldTag)
+	for _, d := range strings.Fields(text) {
+		parts := strings.SplitN(d, "=", 2)
+		if len(parts) == 1 {
+			t[parts[0]] = ""
+			continue
+		}
+		t[parts[0]] = parts[1]
+	}
+	fieldTags.Store(text, t)
+	return t
+}
+
+// fieldRoles returns the roles in the field's gcfgenv:"role=..." directive,
+// e.g. gcfgenv:"role=admin,ops". They are not used by this package, only
+// reported to programs such as admin UIs, which may hide or lock the field
+// from viewers without one of the roles.
+func fieldRoles(sf reflect.StructField) []string {
+	return parseFieldTag(sf).list("role")
+}
+
+// envDisabled reports whether the field (or section) sf is tagged
+// gcfgenv:"-", and so may only be set by the configuration file, never by the
+// environment.
+func envDisabled(sf reflect.StructField) bool {
+	_, ok := parseFieldTag(sf)["-"]
+	return ok
+}
+
+// list returns the comma-separated values of the directive key.
+func (t fieldTag) list(key string) []string {
+	v, ok := t[key]
+	if !ok || v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
@@ -0,0 +1,36 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrInvalidTarget is returned (wrapped, with a description of what was
+// given instead) when the config passed to one of the Read functions is not a
+// non-nil pointer to a struct.
+var ErrInvalidTarget = errors.New("config must be a non-nil pointer to a struct")
+
+// targetStruct returns the struct that config points to, or an error wrapping
+// ErrInvalidTarget.
+func targetStruct(config interface{}) (reflect.Value, error) {
+	ref := reflect.ValueOf(config)
+	switch {
+	case !ref.IsValid():
+		return reflect.Value{}, invalidTarget("not nil")
+	case ref.Kind() != reflect.Ptr:
+		return reflect.Value{}, invalidTarget("not %s", ref.Type())
+	case ref.IsNil():
+		return reflect.Value{}, invalidTarget("not a nil %s", ref.Type())
+	case ref.Elem().Kind() != reflect.Struct:
+		return reflect.Value{}, invalidTarget("not %s", ref.Type())
+	}
+	return ref.Elem(), nil
+}
+
+func invalidTarget(format string, a ...interface{}) error {
+	return withCode(CodeInvalidTarget, fmt.Errorf("%w, "+format, append([]interface{}{ErrInvalidTarget}, a...)...))
+}
@@ -0,0 +1,40 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestInvalidTarget(c *check.C) {
+	type config struct {
+		Sec struct{ Field string }
+	}
+	var nilConfig *config
+	n := 0
+	cases := []struct {
+		config interface{}
+		want   string
+	}{
+		{nil, "not nil"},
+		{config{}, `not gcfgenv\.config`},
+		{nilConfig, `not a nil \*gcfgenv\.config`},
+		{&n, `not \*int`},
+		{&map[string]string{}, `not \*map\[string\]string`},
+	}
+	for _, tc := range cases {
+		r := strings.NewReader("[sec]\nfield = x\n")
+		err := readWithMapInto(r, map[string]string{}, "APP", tc.config)
+		c.Check(err, check.ErrorMatches, "config must be a non-nil pointer to a struct, "+tc.want)
+		c.Check(errors.Is(err, ErrInvalidTarget), check.Equals, true)
+
+		err = applyWithMapInto(map[string]string{}, "APP", tc.config)
+		c.Check(errors.Is(err, ErrInvalidTarget), check.Equals, true)
+		err = reapplyEnvSectionWithMap(tc.config, "sec", map[string]string{}, "APP")
+		c.Check(errors.Is(err, ErrInvalidTarget), check.Equals, true)
+	}
+}
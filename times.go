@@ -0,0 +1,105 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// naiveLayouts are the layouts accepted for timestamps without a time zone.
+var naiveLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// WithTimeZone interprets timestamps without a time zone in time.Time fields
+// (e.g. "2024-05-01 03:00" or "2024-05-01T03:00:00") as times in loc, for
+// fields without a gcfgenv:"tz=..." directive of their own. The directive
+// takes the name of a location, as for time.LoadLocation, e.g.
+// gcfgenv:"tz=Europe/Berlin" or gcfgenv:"tz=UTC".
+//
+// Without either, time.Time fields only accept RFC 3339 timestamps, such as
+// "2024-05-01T03:00:00+02:00", which are always accepted as-is.
+func WithTimeZone(loc *time.Location) Option {
+	return func(o *options) {
+		o.location = loc
+	}
+}
+
+// fieldLocation returns the location for timestamps without a time zone in
+// the field sf, or nil if there is none.
+func (o *options) fieldLocation(sf reflect.StructField) (*time.Location, error) {
+	name, ok := parseFieldTag(sf)["tz"]
+	if !ok {
+		return o.location, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q for %s: %v", name, sf.Name, err)
+	}
+	return loc, nil
+}
+
+// isTimeType returns true for time.Time, and for slices of and pointers to
+// it.
+func isTimeType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return derefType(t) == timeType
+}
+
+// localTime returns val in RFC 3339 format if it is a timestamp without a
+// time zone, interpreted in loc, or val as-is otherwise.
+func localTime(val string, loc *time.Location) string {
+	s := strings.TrimSpace(val)
+	for _, layout := range naiveLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t.Format(time.RFC3339Nano)
+		}
+	}
+	return val
+}
+
+// localTimes applies localTime to each of the entries of val, which is to be
+// converted to the field sf, separated by delim.
+func (o *options) localTimes(sf reflect.StructField, val, delim string) (string, error) {
+	if !isTimeType(sf.Type) {
+		return val, nil
+	}
+	loc, err := o.fieldLocation(sf)
+	if err != nil || loc == nil {
+		return val, err
+	}
+	return rewriteEntries(sf, val, delim, func(s string) string {
+		return localTime(s, loc)
+	}), nil
+}
+
+// localFileTimes rewrites timestamps without a time zone in src, for fields of
+// the config struct type refType that have a location, into RFC 3339 format,
+// which gcfg accepts.
+func (o *options) localFileTimes(refType reflect.Type, src []byte) ([]byte, error) {
+	return rewriteFileValues(refType, src, o.hasLocation, func(sf reflect.StructField, val string) (string, error) {
+		return o.localTimes(sf, val, "")
+	})
+}
+
+// hasLocation returns true if sf is a time.Time field that may have a
+// location.
+func (o *options) hasLocation(sf reflect.StructField) bool {
+	if !isTimeType(sf.Type) {
+		return false
+	}
+	_, ok := parseFieldTag(sf)["tz"]
+	return ok || o.location != nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestTimeZones(c *check.C) {
+	type window struct {
+		Start  time.Time `gcfgenv:"tz=America/New_York"`
+		End    time.Time `gcfgenv:"tz=UTC"`
+		Exact  time.Time
+		Skip   []time.Time `gcfgenv:"tz=UTC"`
+		Notice *time.Time  `gcfgenv:"tz=UTC"`
+	}
+	type config struct {
+		Maintenance window
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	c.Assert(err, check.IsNil)
+
+	configString := `[maintenance]
+start = 2024-05-01 03:00
+end = "2024-05-01T05:30:00"
+exact = 2024-05-01T03:00:00+02:00
+skip = 2024-12-25
+`
+	cfg := config{}
+	err = readWithMapInto(strings.NewReader(configString), map[string]string{
+		"APPNAME_MAINTENANCE_SKIP":   "2025-01-01,2025-01-02T00:00:00Z",
+		"APPNAME_MAINTENANCE_NOTICE": "2024-04-30 12:00:00.5",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Maintenance.Start.Equal(time.Date(2024, 5, 1, 3, 0, 0, 0, newYork)), check.Equals, true)
+	c.Check(cfg.Maintenance.End.Equal(time.Date(2024, 5, 1, 5, 30, 0, 0, time.UTC)), check.Equals, true)
+	c.Check(cfg.Maintenance.Exact.Equal(time.Date(2024, 5, 1, 1, 0, 0, 0, time.UTC)), check.Equals, true)
+	c.Check(cfg.Maintenance.Skip, check.HasLen, 3)
+	for i, want := range []time.Time{
+		time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+	} {
+		c.Check(cfg.Maintenance.Skip[i].Equal(want), check.Equals, true, check.Commentf("entry %d", i))
+	}
+	c.Assert(cfg.Maintenance.Notice, check.NotNil)
+	c.Check(cfg.Maintenance.Notice.Equal(time.Date(2024, 4, 30, 12, 0, 0, 5e8, time.UTC)), check.Equals, true)
+
+	// Fields without a location only accept RFC 3339 timestamps, unless
+	// WithTimeZone is given.
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader("[maintenance]\nexact = 2024-05-01 03:00\n"), nil, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `.*cannot parse.*`)
+	cfg = config{}
+	err = readWithMapInto(strings.NewReader("[maintenance]\nexact = 2024-05-01 03:00\n"), map[string]string{
+		"APPNAME_MAINTENANCE_START": "2024-05-01 04:00",
+	}, "APPNAME", &cfg, WithTimeZone(time.UTC))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Maintenance.Exact.Equal(time.Date(2024, 5, 1, 3, 0, 0, 0, time.UTC)), check.Equals, true)
+	// The field's own directive takes precedence.
+	c.Check(cfg.Maintenance.Start.Equal(time.Date(2024, 5, 1, 4, 0, 0, 0, newYork)), check.Equals, true)
+
+	type badConfig struct {
+		Sec struct {
+			When time.Time `gcfgenv:"tz=Mars/Olympus_Mons"`
+		}
+	}
+	err = readWithMapInto(strings.NewReader("[sec]\nwhen = 2024-05-01 03:00\n"), nil, "APPNAME", &badConfig{})
+	c.Check(err, check.ErrorMatches, `invalid time zone "Mars/Olympus_Mons" for When: .*`)
+}
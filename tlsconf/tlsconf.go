@@ -0,0 +1,134 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package tlsconf provides a reusable configuration section for TLS settings,
+// for use with gcfgenv.
+//
+// For example,
+//
+//	type Config struct {
+//		Server struct {
+//			Listen string
+//		}
+//		TLS tlsconf.Section
+//	}
+//
+// can be read from
+//
+//	[tls]
+//	cert-file = /etc/app/server.pem
+//	key-file = /etc/app/server.key
+//	min-version = 1.2
+//
+// and overridden by variables such as APPNAME_TLS_KEY_FILE.
+package tlsconf
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// Section holds TLS settings. The zero value uses Go's defaults.
+type Section struct {
+	// CertFile and KeyFile are PEM-encoded files holding the certificate
+	// chain and private key. Either both or neither must be set.
+	CertFile string `gcfg:"cert-file"`
+	KeyFile  string `gcfg:"key-file"`
+	// CAFile is a PEM-encoded file of certificate authorities used to
+	// verify peers, instead of the system roots.
+	CAFile string `gcfg:"ca-file"`
+	// MinVersion is the minimum TLS version, one of "1.0", "1.1", "1.2",
+	// or "1.3".
+	MinVersion string `gcfg:"min-version"`
+	// CipherSuites restricts the cipher suites used for TLS 1.2 and
+	// earlier, by their standard names (e.g.
+	// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256").
+	CipherSuites []string `gcfg:"cipher-suites"`
+}
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Validate checks the settings without reading any files. It is called
+// automatically when the configuration is read, as gcfgenv.Validator.
+func (s *Section) Validate() error {
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return fmt.Errorf("tls: cert-file and key-file must be set together")
+	}
+	if _, err := s.minVersion(); err != nil {
+		return err
+	}
+	_, err := s.cipherSuites()
+	return err
+}
+
+// Config returns a *tls.Config for the settings, reading the certificate,
+// key, and CA files.
+func (s *Section) Config() (*tls.Config, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	min, _ := s.minVersion()
+	suites, _ := s.cipherSuites()
+	cfg := &tls.Config{
+		MinVersion:   min,
+		CipherSuites: suites,
+	}
+	if s.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if s.CAFile != "" {
+		pem, err := ioutil.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in %s", s.CAFile)
+		}
+		cfg.RootCAs = pool
+		cfg.ClientCAs = pool
+	}
+	return cfg, nil
+}
+
+func (s *Section) minVersion() (uint16, error) {
+	if s.MinVersion == "" {
+		return 0, nil
+	}
+	v, ok := versions[strings.TrimPrefix(strings.ToLower(s.MinVersion), "tls")]
+	if !ok {
+		return 0, fmt.Errorf("tls: unknown min-version %q", s.MinVersion)
+	}
+	return v, nil
+}
+
+func (s *Section) cipherSuites() ([]uint16, error) {
+	if len(s.CipherSuites) == 0 {
+		return nil, nil
+	}
+	ids := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		ids[suite.Name] = suite.ID
+	}
+	var out []uint16
+	for _, name := range s.CipherSuites {
+		id, ok := ids[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("tls: unknown or insecure cipher suite %q", name)
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
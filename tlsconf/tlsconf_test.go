@@ -0,0 +1,132 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package tlsconf
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rstudio/gcfgenv"
+	"gopkg.in/check.v1"
+)
+
+type Suite struct{}
+
+func Test(t *testing.T) {
+	_ = check.Suite(&Suite{})
+	check.TestingT(t)
+}
+
+// writeKeyPair writes a self-signed certificate and its key to dir.
+func writeKeyPair(c *check.C, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, check.IsNil)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	c.Assert(err, check.IsNil)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	c.Assert(err, check.IsNil)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	c.Assert(err, check.IsNil)
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	c.Assert(err, check.IsNil)
+	return certFile, keyFile
+}
+
+func (s *Suite) TestConfig(c *check.C) {
+	certFile, keyFile := writeKeyPair(c, c.MkDir())
+
+	type config struct {
+		TLS Section
+	}
+	cfg := config{}
+	os.Setenv("APPNAME_TLS_KEY_FILE", keyFile)
+	os.Setenv("APPNAME_TLS_CA_FILE", certFile)
+	defer func() {
+		os.Unsetenv("APPNAME_TLS_KEY_FILE")
+		os.Unsetenv("APPNAME_TLS_CA_FILE")
+	}()
+	r := strings.NewReader(`[tls]
+cert-file = ` + certFile + `
+min-version = 1.2
+cipher-suites = TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+`)
+	err := gcfgenv.ReadWithEnvInto(r, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.TLS.KeyFile, check.Equals, keyFile)
+
+	tlsConfig, err := cfg.TLS.Config()
+	c.Assert(err, check.IsNil)
+	c.Check(tlsConfig.MinVersion, check.Equals, uint16(tls.VersionTLS12))
+	c.Check(tlsConfig.CipherSuites, check.DeepEquals,
+		[]uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256})
+	c.Check(tlsConfig.Certificates, check.HasLen, 1)
+	c.Check(tlsConfig.RootCAs, check.NotNil)
+
+	// The zero value is valid.
+	tlsConfig, err = (&Section{}).Config()
+	c.Assert(err, check.IsNil)
+	c.Check(tlsConfig.Certificates, check.HasLen, 0)
+}
+
+func (s *Suite) TestFileVars(c *check.C) {
+	certFile, keyFile := writeKeyPair(c, c.MkDir())
+
+	// The _FILE variables still set the fields directly.
+	var cfg struct {
+		TLS Section
+	}
+	err := gcfgenv.ReadWithEnvMapInto(strings.NewReader(""), map[string]string{
+		"APPNAME_TLS_CERT_FILE": certFile,
+		"APPNAME_TLS_KEY_FILE":  keyFile,
+		"APPNAME_TLS_CA_FILE":   certFile,
+	}, "APPNAME", &cfg, gcfgenv.WithFileVars())
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.TLS.CertFile, check.Equals, certFile)
+	c.Check(cfg.TLS.KeyFile, check.Equals, keyFile)
+	c.Check(cfg.TLS.CAFile, check.Equals, certFile)
+	_, err = cfg.TLS.Config()
+	c.Check(err, check.IsNil)
+}
+
+func (s *Suite) TestValidate(c *check.C) {
+	c.Check((&Section{CertFile: "cert.pem"}).Validate(), check.ErrorMatches,
+		"tls: cert-file and key-file must be set together")
+	c.Check((&Section{MinVersion: "TLS1.3"}).Validate(), check.IsNil)
+	c.Check((&Section{MinVersion: "1.4"}).Validate(), check.ErrorMatches,
+		`tls: unknown min-version "1.4"`)
+	c.Check((&Section{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}).Validate(),
+		check.ErrorMatches, `tls: unknown or insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA"`)
+
+	dir := c.MkDir()
+	_, err := (&Section{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: "key.pem"}).Config()
+	c.Check(err, check.ErrorMatches, "tls: open .*missing.pem: no such file or directory")
+	empty := filepath.Join(dir, "empty.pem")
+	c.Assert(ioutil.WriteFile(empty, nil, 0600), check.IsNil)
+	_, err = (&Section{CAFile: empty}).Config()
+	c.Check(err, check.ErrorMatches, "tls: no certificates found in .*empty.pem")
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A TransformFunc normalises a raw configuration value before it is
+// converted to the field's type.
+type TransformFunc func(string) (string, error)
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]TransformFunc{
+		"trim": func(s string) (string, error) {
+			return strings.TrimSpace(s), nil
+		},
+		"lower": func(s string) (string, error) {
+			return strings.ToLower(s), nil
+		},
+		"upper": func(s string) (string, error) {
+			return strings.ToUpper(s), nil
+		},
+	}
+)
+
+// expandEnvTransform is the name of the built-in transform that expands
+// ${VAR} or $VAR references to environment variables. It is handled
+// separately, since it uses the same environment as the overrides.
+const expandEnvTransform = "expandenv"
+
+// RegisterTransform makes fn available to the "transform" directive of the
+// gcfgenv struct tag under name. The built-in transforms are "trim", "lower",
+// "upper", and "expandenv". Like http.Handle, RegisterTransform is intended
+// to be called during initialisation and panics if name is already in use.
+func RegisterTransform(name string, fn TransformFunc) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	if _, found := transforms[name]; found || name == expandEnvTransform {
+		panic(fmt.Sprintf("gcfgenv: transform %q registered twice", name))
+	}
+	transforms[name] = fn
+}
+
+// applyTransforms applies the transforms named in the field's
+// gcfgenv:"transform=..." tag to val, in order.
+func applyTransforms(sf reflect.StructField, val string, env map[string]string) (string, error) {
+	names := parseFieldTag(sf).list("transform")
+	for _, name := range names {
+		if name == expandEnvTransform {
+			val = os.Expand(val, func(k string) string { return env[k] })
+			continue
+		}
+		transformsMu.RLock()
+		fn, found := transforms[name]
+		transformsMu.RUnlock()
+		if !found {
+			return val, fmt.Errorf("unknown transform %q", name)
+		}
+		var err error
+		val, err = fn(val)
+		if err != nil {
+			return val, err
+		}
+	}
+	return val, nil
+}
+
+// transformFileValues applies transforms to the values in src of the fields
+// of the config struct type refType that have them. This is done before gcfg
+// reads src, so that values are transformed whatever the type of the field,
+// as they are for environment variables.
+func transformFileValues(refType reflect.Type, src []byte, env map[string]string) ([]byte, error) {
+	match := func(sf reflect.StructField) bool {
+		_, ok := parseFieldTag(sf)["transform"]
+		return ok
+	}
+	return rewriteFileValues(refType, src, match, func(sf reflect.StructField, val string) (string, error) {
+		return applyTransforms(sf, val, env)
+	})
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func init() {
+	RegisterTransform("strip-slash", func(s string) (string, error) {
+		if s == "/" {
+			return "", fmt.Errorf("refusing to strip root path")
+		}
+		return strings.TrimSuffix(s, "/"), nil
+	})
+	RegisterTransform("digits", func(s string) (string, error) {
+		return strings.Replace(s, "_", "", -1), nil
+	})
+}
+
+type transformCommon struct {
+	Region string `gcfgenv:"transform=lower"`
+}
+
+func (s *Suite) TestTransforms(c *check.C) {
+	type sec struct {
+		Mode  string   `gcfgenv:"transform=trim,lower"`
+		Dir   string   `gcfgenv:"transform=expandenv,strip-slash"`
+		Tags  []string `gcfgenv:"transform=upper"`
+		Level *string  `gcfgenv:"transform=upper"`
+		Count int      `gcfgenv:"transform=trim"`
+	}
+	type config struct {
+		Sec    sec
+		Subsec map[string]*sec
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader(`[sec]
+mode = "  Debug "
+dir = ${HOME}/data/
+tags = a
+tags = b
+level = info
+
+[subsec "k1"]
+mode = FAST
+`)
+	err = readWithMapInto(r, map[string]string{
+		"HOME":                   "/home/user",
+		"APPNAME_SEC_COUNT":      " 3 ",
+		"APPNAME_SUBSEC_k1_DIR":  "$HOME/k1/",
+		"APPNAME_SUBSEC_k2_MODE": "Slow",
+	}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	level := "INFO"
+	c.Check(cfg, check.DeepEquals, config{
+		Sec: sec{
+			Mode:  "debug",
+			Dir:   "/home/user/data",
+			Tags:  []string{"A", "B"},
+			Level: &level,
+			Count: 3,
+		},
+		Subsec: map[string]*sec{
+			"k1": {Mode: "fast", Dir: "/home/user/k1"},
+			"k2": {Mode: "slow"},
+		},
+	})
+
+	// Errors from transforms are returned.
+	cfg = config{}
+	r = strings.NewReader("")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SEC_DIR": "/",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `cannot set Sec\.Dir from APPNAME_SEC_DIR="/": refusing to strip root path`)
+
+	// Values from the file are transformed before they are converted, so
+	// transforms apply to fields of any type.
+	type typed struct {
+		Sec struct {
+			Count int      `gcfgenv:"transform=digits"`
+			Price Decimal  `gcfgenv:"transform=digits"`
+			Ports []uint16 `gcfgenv:"transform=digits"`
+		}
+	}
+	tc := typed{}
+	r = strings.NewReader("[sec]\ncount = 1_000\nprice = 1_250.50\nports = 8_080\nports = 443\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &tc)
+	c.Assert(err, check.IsNil)
+	c.Check(tc.Sec.Count, check.Equals, 1000)
+	c.Check(tc.Sec.Price.String(), check.Equals, "1250.50")
+	c.Check(tc.Sec.Ports, check.DeepEquals, []uint16{8080, 443})
+
+	// Transforms on promoted fields apply to file values too, and those on
+	// grouped fields to their variables.
+	type nested struct {
+		Sec struct {
+			transformCommon
+			Limits struct {
+				Mode string `gcfgenv:"transform=upper"`
+			}
+		}
+		Subsec map[string]*struct {
+			transformCommon
+		}
+	}
+	nc := nested{}
+	r = strings.NewReader("[sec]\nregion = EU-West\n[subsec \"a\"]\nregion = US\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SEC_LIMITS_MODE": "strict",
+	}, "APPNAME", &nc)
+	c.Assert(err, check.IsNil)
+	c.Check(nc.Sec.Region, check.Equals, "eu-west")
+	c.Check(nc.Sec.Limits.Mode, check.Equals, "STRICT")
+	c.Check(nc.Subsec["a"].Region, check.Equals, "us")
+
+	type badConfig struct {
+		Sec struct {
+			Field string `gcfgenv:"transform=reverse"`
+		}
+	}
+	bad := badConfig{}
+	r = strings.NewReader("[sec]\nfield = value\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &bad)
+	c.Check(err, check.ErrorMatches, `unknown transform "reverse"`)
+
+	c.Check(func() {
+		RegisterTransform("trim", nil)
+	}, check.PanicMatches, `gcfgenv: transform "trim" registered twice`)
+}
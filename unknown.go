@@ -0,0 +1,126 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/warnings.v0"
+)
+
+// An UnknownNameError is a warning about a section or variable in the
+// configuration file that does not correspond to any field, which gcfg
+// skips. It replaces gcfg's own warning, and has the same message.
+type UnknownNameError struct {
+	// Section is the name of the section, as written in the file.
+	Section string
+	// Subsection is the name of the subsection, if the section has
+	// subsections.
+	Subsection string
+	// Variable is the name of the variable, or "" if the whole section is
+	// unknown.
+	Variable string
+	// Line is the line of the section header or variable in the file (or,
+	// for ReadFilesWithEnvInto, in the files taken together), or 0 if it
+	// is not known, as for sections read by WithStreamedSection.
+	Line int
+	err  error
+}
+
+func (e *UnknownNameError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns gcfg's original warning.
+func (e *UnknownNameError) Unwrap() error {
+	return e.err
+}
+
+// structureWarnings replaces gcfg's warnings in err, the result of reading
+// src, with *UnknownNameError warnings. gcfg reads the file twice, and warns
+// about an unknown section once for each variable in it, so repeated warnings
+// are dropped.
+func structureWarnings(err error, src []byte) error {
+	list, ok := err.(warnings.List)
+	if !ok || len(list.Warnings) == 0 {
+		return err
+	}
+	var doc *Document
+	seen := make(map[UnknownNameError]bool)
+	out := make([]error, 0, len(list.Warnings))
+	for _, w := range list.Warnings {
+		u, ok := unknownName(w)
+		if !ok {
+			out = append(out, w)
+			continue
+		}
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		if doc == nil {
+			doc = &Document{}
+			doc.parse(string(src))
+		}
+		u.Line = doc.line(u.Section, u.Subsection, u.Variable)
+		u.err = w
+		out = append(out, &u)
+	}
+	list.Warnings = out
+	return list
+}
+
+// unknownName returns the location in gcfg's "extra data" warning w, which is
+// not exported, so it is read with reflection. It returns false for any other
+// error, or if the warning does not have the expected fields.
+func unknownName(w error) (out UnknownNameError, ok bool) {
+	v := reflect.ValueOf(w)
+	t := v.Type()
+	if t.PkgPath() != "gopkg.in/gcfg.v1" || t.Name() != "extraData" || t.Kind() != reflect.Struct {
+		return out, false
+	}
+	loc := v.FieldByName("loc")
+	if loc.Kind() != reflect.Struct {
+		return out, false
+	}
+	section := loc.FieldByName("section")
+	subsection := loc.FieldByName("subsection")
+	variable := loc.FieldByName("variable")
+	if section.Kind() != reflect.String || subsection.Kind() != reflect.Ptr || variable.Kind() != reflect.Ptr {
+		return out, false
+	}
+	out.Section = section.String()
+	if !subsection.IsNil() {
+		out.Subsection = subsection.Elem().String()
+	}
+	if !variable.IsNil() {
+		out.Variable = variable.Elem().String()
+	}
+	return out, true
+}
+
+// line returns the line of the first variable with the given name in the
+// given section and subsection of d, or of the first header of the section if
+// name is "", or 0 if there is none. Sections are matched by name alone for
+// unknown sections, since gcfg does not report their subsections.
+func (d *Document) line(section, subsection, name string) int {
+	for _, s := range d.Sections {
+		if !strings.EqualFold(s.Name, section) {
+			continue
+		}
+		if name == "" {
+			return s.Line
+		}
+		if s.Subsection != subsection {
+			continue
+		}
+		for _, v := range s.Variables {
+			if strings.EqualFold(v.Name, name) {
+				return v.Line
+			}
+		}
+	}
+	return 0
+}
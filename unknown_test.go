@@ -0,0 +1,65 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"strings"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/gcfg.v1"
+	"gopkg.in/warnings.v0"
+)
+
+func (s *Suite) TestUnknownName(c *check.C) {
+	type sec struct {
+		Field string
+	}
+	type config struct {
+		Sec  sec
+		Subs map[string]*sec
+	}
+
+	configString := `[sec]
+field = a
+feild = b
+
+[cache]
+size = 10
+ttl = 5
+
+[subs "one"]
+field = c
+other = d
+`
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(configString), nil, "APPNAME", &cfg)
+	c.Assert(gcfg.FatalOnly(err), check.IsNil)
+	warns := warnings.WarningsOnly(err)
+	c.Assert(warns, check.HasLen, 3)
+	var got []UnknownNameError
+	for _, w := range warns {
+		var u *UnknownNameError
+		c.Assert(errors.As(w, &u), check.Equals, true)
+		c.Check(ErrorCode(w), check.Equals, CodeUnknownName)
+		got = append(got, UnknownNameError{Section: u.Section, Subsection: u.Subsection, Variable: u.Variable, Line: u.Line})
+	}
+	c.Check(got, check.DeepEquals, []UnknownNameError{
+		{Section: "sec", Variable: "feild", Line: 3},
+		{Section: "cache", Line: 5},
+		{Section: "subs", Subsection: "one", Variable: "other", Line: 11},
+	})
+	// The messages are gcfg's own.
+	c.Check(warns[0], check.ErrorMatches, `can't store data at section "sec", variable "feild"`)
+	c.Check(warns[1], check.ErrorMatches, `can't store data at section "cache"`)
+	c.Check(warns[2], check.ErrorMatches, `can't store data at section "subs", subsection "one", variable "other"`)
+	c.Check(cfg, check.DeepEquals, config{
+		Sec:  sec{"a"},
+		Subs: map[string]*sec{"one": {"c"}},
+	})
+
+	// Other errors are left alone.
+	c.Check(structureWarnings(errors.New("x"), nil), check.ErrorMatches, "x")
+	c.Check(structureWarnings(nil, nil), check.IsNil)
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+)
+
+// wholeValue reports whether the field's environment variable should be
+// converted as a single value, rather than split on SliceDelimiter, according
+// to its gcfgenv:"unmarshal=..." directive:
+//
+//   - "each" (the default) splits the value, and converts each entry on its
+//     own: with a slice type's own UnmarshalText method, or as an element of
+//     a plain slice.
+//   - "whole" passes the entire value to the slice type's UnmarshalText
+//     method, or converts it to a single element, as gcfg does for each line
+//     of the file.
+func wholeValue(sf reflect.StructField) (bool, error) {
+	mode, ok := parseFieldTag(sf)["unmarshal"]
+	if !ok {
+		return false, nil
+	}
+	switch mode {
+	case "each":
+		return false, nil
+	case "whole":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid unmarshal mode %q for %s: must be \"each\" or \"whole\"",
+		mode, sf.Name)
+}
+
+// valFromWholeEnvVar converts env to t without splitting it into entries.
+func valFromWholeEnvVar(t reflect.Type, env string) (reflect.Value, error) {
+	elem := t
+	if t.Kind() == reflect.Ptr {
+		elem = t.Elem()
+	}
+	ptr := reflect.New(elem)
+	if u, ok := ptr.Interface().(encoding.TextUnmarshaler); ok {
+		err := u.UnmarshalText([]byte(env))
+		if t.Kind() == reflect.Ptr {
+			return ptr, err
+		}
+		return ptr.Elem(), err
+	}
+	if t.Kind() != reflect.Slice {
+		return valFromEnvVar(t, env)
+	}
+	v, err := valFromEnvVar(t.Elem(), env)
+	if err != nil {
+		return reflect.Zero(t), err
+	}
+	return reflect.Append(reflect.MakeSlice(t, 0, 1), v), nil
+}
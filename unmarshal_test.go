@@ -0,0 +1,63 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+// pairList parses a whole "k=v;k=v" value at once.
+type pairList []string
+
+func (p *pairList) UnmarshalText(text []byte) error {
+	*p = append(*p, strings.Split(string(text), ";")...)
+	return nil
+}
+
+func (s *Suite) TestUnmarshalMode(c *check.C) {
+	type config struct {
+		Sec struct {
+			Each      StringSliceType
+			Whole     StringSliceType `gcfgenv:"unmarshal=whole"`
+			Pairs     *pairList       `gcfgenv:"unmarshal=whole"`
+			Lower     []lowerString
+			LowerOne  []lowerString `gcfgenv:"unmarshal=whole"`
+			Plain     []string      `gcfgenv:"unmarshal=each"`
+			WithComma []string      `gcfgenv:"unmarshal=whole"`
+		}
+	}
+	env := map[string]string{
+		"APP_SEC_EACH":      "a,b",
+		"APP_SEC_WHOLE":     "a,b",
+		"APP_SEC_PAIRS":     "x=1,2;y=3",
+		"APP_SEC_LOWER":     "A,B",
+		"APP_SEC_LOWERONE":  "A,B",
+		"APP_SEC_PLAIN":     "a,b",
+		"APP_SEC_WITHCOMMA": "a,b",
+	}
+	src := `
+[sec]
+withcomma = file
+`
+	cfg := config{}
+	err := readWithMapInto(strings.NewReader(src), env, "APP", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Sec.Each, check.DeepEquals, StringSliceType{"a", "b"})
+	c.Check(cfg.Sec.Whole, check.DeepEquals, StringSliceType{"a,b"})
+	c.Check(*cfg.Sec.Pairs, check.DeepEquals, pairList{"x=1,2", "y=3"})
+	c.Check(cfg.Sec.Lower, check.DeepEquals, []lowerString{"a", "b"})
+	c.Check(cfg.Sec.LowerOne, check.DeepEquals, []lowerString{"a,b"})
+	c.Check(cfg.Sec.Plain, check.DeepEquals, []string{"a", "b"})
+	c.Check(cfg.Sec.WithComma, check.DeepEquals, []string{"file", "a,b"})
+
+	bad := struct {
+		Sec struct {
+			Field []string `gcfgenv:"unmarshal=some"`
+		}
+	}{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{"APP_SEC_FIELD": "x"}, "APP", &bad)
+	c.Check(err, check.ErrorMatches, `cannot set Sec\.Field from APP_SEC_FIELD="x": invalid unmarshal mode "some" for Field: .*`)
+}
@@ -0,0 +1,439 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WithRequired declares that the given fields must be set, like the
+// gcfgenv:"required" directive. Fields are named as "Section.Field", and must
+// belong to sections that are not subsections.
+func WithRequired(fields ...string) Option {
+	return func(o *options) {
+		for _, name := range fields {
+			o.required = append(o.required, strings.SplitN(name, ".", 2))
+		}
+	}
+}
+
+// WithExclusive declares that at most one of the given fields may be set,
+// like the gcfgenv:"exclusive=..." directive. Fields are named as
+// "Section.Field", and must belong to sections that are not subsections.
+func WithExclusive(fields ...string) Option {
+	return func(o *options) {
+		o.exclusive = append(o.exclusive, fields)
+	}
+}
+
+// WithReference declares that the string (or []string) field, named as
+// "Section.Field", must hold names of existing subsections of section, like
+// the gcfgenv:"ref=..." directive.
+func WithReference(field, section string) Option {
+	return func(o *options) {
+		o.references = append(o.references, [2]string{field, section})
+	}
+}
+
+// validate checks the constraints declared by directives and options once the
+// file and environment have both been applied.
+func (st *applyState) validate(ref reflect.Value) error {
+	return st.validateSections(ref, ref, true)
+}
+
+// validateStreamed checks the subsections in wrapper, from streamWrapper, like
+// validate. Fields given with options such as WithRequired belong to sections
+// that are not streamed, so they are left to validate, but directives that
+// refer to other sections are resolved against the config struct ref.
+func (st *applyState) validateStreamed(ref, wrapper reflect.Value) error {
+	return st.validateSections(ref, wrapper, false)
+}
+
+// validateSections checks the directives of the sections in secs, which is
+// either the config struct ref or a wrapper from streamWrapper, along with
+// any fields given with options if withOptions is true.
+func (st *applyState) validateSections(ref, secs reflect.Value, withOptions bool) error {
+	err := st.checkMissing(secs, withOptions)
+	if err != nil {
+		return withCode(CodeRequired, err)
+	}
+	err = checkRequired(ref, secs)
+	if err != nil {
+		return withCode(CodeRequired, err)
+	}
+	err = st.checkExclusive(secs, withOptions)
+	if err != nil {
+		return withCode(CodeExclusive, err)
+	}
+	err = checkItems(secs)
+	if err != nil {
+		return withCode(CodeItems, err)
+	}
+	err = st.checkReferences(ref, secs, withOptions)
+	if err != nil {
+		return withCode(CodeReference, err)
+	}
+	return withCode(CodeValidate, runValidators(secs))
+}
+
+// A Validator checks its own values, e.g. constraints between fields that the
+// directives cannot express. If the config struct, or any section or
+// subsection struct, implements Validator (with either a value or a pointer
+// receiver), its Validate method is called once the file and environment
+// have both been applied, and after the directives have been checked.
+type Validator interface {
+	Validate() error
+}
+
+// runValidators calls the Validate methods of every section and subsection of
+// ref, then that of ref itself, so that it can rely on its sections being
+// valid. Errors from sections are prefixed with their path, and all of them
+// are returned.
+func runValidators(ref reflect.Value) error {
+	var errs Errors
+	err := walkSections(ref, func(path []string, sec reflect.Value) error {
+		name := strings.Join(path, ".")
+		err := callValidator(sec, name)
+		if _, ok := err.(*PanicError); err != nil && !ok {
+			err = fmt.Errorf("%s: %w", name, err)
+		}
+		errs = errs.appendError(err)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if errs != nil {
+		return errs.err()
+	}
+	return callValidator(ref, "")
+}
+
+// callValidator calls the Validate method of v, if it has one, converting any
+// panic into a *PanicError for path.
+func callValidator(v reflect.Value, path string) (err error) {
+	defer recoverPanic(&err, path)
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	if val, ok := v.Interface().(Validator); ok {
+		return val.Validate()
+	}
+	return nil
+}
+
+// checkMissing enforces gcfgenv:"required" directives, and any fields given
+// with WithRequired if withOptions is true, once the file and environment
+// have both been applied. A field is missing if it is not set (see isSet),
+// and every missing field is reported, rather than only the first.
+func (st *applyState) checkMissing(ref reflect.Value, withOptions bool) error {
+	var errs Errors
+	// A field may be both tagged and given with WithRequired.
+	reported := make(map[string]bool)
+	err := walkFields(ref, func(path []string, sf reflect.StructField, f reflect.Value) error {
+		if _, ok := parseFieldTag(sf)["required"]; ok && !isSet(f) {
+			errs = append(errs, st.missing(ref, path, sf))
+			reported[strings.Join(path, ".")] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !withOptions {
+		return errs.err()
+	}
+	for _, path := range st.opts.required {
+		f, err := lookupFieldPath(ref, path)
+		if err != nil {
+			return err
+		}
+		if isSet(f) || reported[strings.Join(path, ".")] {
+			continue
+		}
+		secType := ref.Field(sectionFieldIndex(ref.Type(), path[0])).Type()
+		sf := secType.Field(sectionFieldIndex(secType, path[1]))
+		errs = append(errs, st.missing(ref, path, sf))
+		reported[strings.Join(path, ".")] = true
+	}
+	return errs.err()
+}
+
+// missing returns the error for the required field sf at path, which names
+// the environment variable that could have set it.
+func (st *applyState) missing(ref reflect.Value, path []string, sf reflect.StructField) error {
+	name := strings.Join(path, ".")
+	sec := ref.Type().Field(sectionFieldIndex(ref.Type(), path[0]))
+	if envDisabled(sec) || envDisabled(sf) {
+		return fmt.Errorf("%s is required, but is not set in the configuration file", name)
+	}
+	return fmt.Errorf("%s is required, but is not set in the configuration file or by %s", name, st.fieldEnvVar(path))
+}
+
+// checkRequired enforces gcfgenv:"required_if=..." directives once the file
+// and environment have both been applied. The reference is either a field of
+// the same section (or subsection), or "Section.Field" for a field of another
+// section. The sections in secs are checked, and references to other
+// sections are resolved in the config struct ref.
+func checkRequired(ref, secs reflect.Value) error {
+	return walkSections(secs, func(path []string, sec reflect.Value) error {
+		secType := sec.Type()
+		for j := 0; j < secType.NumField(); j++ {
+			sf := secType.Field(j)
+			if !sf.IsExported() {
+				continue
+			}
+			cond, ok := parseFieldTag(sf)["required_if"]
+			if !ok {
+				continue
+			}
+			other, err := lookupFieldRef(ref, sec, cond)
+			if err != nil {
+				return err
+			}
+			if isSet(other) && !isSet(sec.Field(j)) {
+				name := strings.Join(append(path[:len(path):len(path)], fieldSectionName(sf)), ".")
+				return fmt.Errorf("%s is required when %s is set", name, cond)
+			}
+		}
+		return nil
+	})
+}
+
+// lookupFieldRef finds the field named by a required_if reference.
+func lookupFieldRef(ref, sec reflect.Value, name string) (reflect.Value, error) {
+	if strings.Contains(name, ".") {
+		return lookupFieldPath(ref, strings.SplitN(name, ".", 2))
+	}
+	i := sectionFieldIndex(sec.Type(), name)
+	if i < 0 {
+		return reflect.Value{}, fmt.Errorf("invalid field reference %q", name)
+	}
+	return sec.Field(i), nil
+}
+
+// checkItems enforces the gcfgenv:"minitems=n", "maxitems=n", and "unique"
+// directives on slice fields.
+func checkItems(ref reflect.Value) error {
+	return walkFields(ref, func(path []string, sf reflect.StructField, f reflect.Value) error {
+		tag := parseFieldTag(sf)
+		name := strings.Join(path, ".")
+		if f.Kind() != reflect.Slice {
+			for _, d := range []string{"minitems", "maxitems", "unique"} {
+				if _, ok := tag[d]; ok {
+					return fmt.Errorf("%s directive on non-slice field %s", d, name)
+				}
+			}
+			return nil
+		}
+		if v, ok := tag["minitems"]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid minitems directive on %s: %q", name, v)
+			}
+			if f.Len() < n {
+				return fmt.Errorf("%s must have at least %d entries, but has %d", name, n, f.Len())
+			}
+		}
+		if v, ok := tag["maxitems"]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid maxitems directive on %s: %q", name, v)
+			}
+			if f.Len() > n {
+				return fmt.Errorf("%s must have at most %d entries, but has %d", name, n, f.Len())
+			}
+		}
+		if _, ok := tag["unique"]; ok {
+			seen := make(map[string]bool)
+			for i := 0; i < f.Len(); i++ {
+				// Compare pointers by the values they point to.
+				k := fmt.Sprintf("%v", reflect.Indirect(f.Index(i)))
+				if seen[k] {
+					return fmt.Errorf("%s has duplicate entry %q", name, k)
+				}
+				seen[k] = true
+			}
+		}
+		return nil
+	})
+}
+
+// checkReferences enforces gcfgenv:"ref=..." directives on the fields in secs,
+// and any references given with WithReference if withOptions is true. The
+// subsections referred to are looked up in the config struct ref.
+func (st *applyState) checkReferences(ref, secs reflect.Value, withOptions bool) error {
+	err := walkFields(secs, func(path []string, sf reflect.StructField, f reflect.Value) error {
+		section, ok := parseFieldTag(sf)["ref"]
+		if !ok {
+			return nil
+		}
+		return st.checkReference(ref, path, f, section)
+	})
+	if err != nil || !withOptions {
+		return err
+	}
+	for _, r := range st.opts.references {
+		path := strings.SplitN(r[0], ".", 2)
+		f, err := lookupFieldPath(ref, path)
+		if err != nil {
+			return err
+		}
+		if err := st.checkReference(ref, path, f, r[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *applyState) checkReference(ref reflect.Value, path []string, f reflect.Value, section string) error {
+	name := strings.Join(path, ".")
+	i := sectionFieldIndex(ref.Type(), section)
+	if i < 0 || ref.Field(i).Kind() != reflect.Map {
+		return fmt.Errorf("%s refers to %q, which is not a section with subsections", name, section)
+	}
+	var keys []string
+	switch {
+	case f.Kind() == reflect.String:
+		keys = []string{f.String()}
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		for j := 0; j < f.Len(); j++ {
+			keys = append(keys, f.Index(j).String())
+		}
+	default:
+		return fmt.Errorf("ref directive on %s, which is not a string or []string", name)
+	}
+	for _, k := range keys {
+		// Leave empty values to required_if.
+		if k == "" || ref.Field(i).MapIndex(reflect.ValueOf(k)).IsValid() {
+			continue
+		}
+		return fmt.Errorf("%s (from %s) refers to unknown %s subsection %q",
+			name, st.source(path), fieldSectionName(ref.Type().Field(i)), k)
+	}
+	return nil
+}
+
+// isSet reports whether f has a value: true for booleans, non-empty for
+// slices, and non-zero for everything else.
+func isSet(f reflect.Value) bool {
+	switch f.Kind() {
+	case reflect.Slice, reflect.Map:
+		return f.Len() > 0
+	}
+	return !f.IsZero()
+}
+
+// checkExclusive enforces gcfgenv:"exclusive=..." directives, which allow at
+// most one of the fields in a section sharing the same group name to be set,
+// as well as any groups given with WithExclusive if withOptions is true.
+func (st *applyState) checkExclusive(ref reflect.Value, withOptions bool) error {
+	err := walkSections(ref, func(path []string, sec reflect.Value) error {
+		groups := make(map[string][]string)
+		secType := sec.Type()
+		for j := 0; j < secType.NumField(); j++ {
+			sf := secType.Field(j)
+			group, ok := parseFieldTag(sf)["exclusive"]
+			if !sf.IsExported() || !ok {
+				continue
+			}
+			groups[group] = append(groups[group], fieldSectionName(sf))
+		}
+		names := make([]string, 0, len(groups))
+		for group := range groups {
+			names = append(names, group)
+		}
+		sort.Strings(names)
+		for _, group := range names {
+			var paths [][]string
+			for _, name := range groups[group] {
+				paths = append(paths, append(path[:len(path):len(path)], name))
+			}
+			if err := st.checkExclusiveGroup(ref, paths); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil || !withOptions {
+		return err
+	}
+	for _, fields := range st.opts.exclusive {
+		var paths [][]string
+		for _, name := range fields {
+			paths = append(paths, strings.SplitN(name, ".", 2))
+		}
+		if err := st.checkExclusiveGroup(ref, paths); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *applyState) checkExclusiveGroup(ref reflect.Value, paths [][]string) error {
+	var names, set []string
+	for _, path := range paths {
+		f, err := lookupFieldPath(ref, path)
+		if err != nil {
+			return err
+		}
+		name := strings.Join(path, ".")
+		names = append(names, name)
+		if !isSet(f) {
+			continue
+		}
+		set = append(set, fmt.Sprintf("%s (from %s)", name, st.source(path)))
+	}
+	if len(set) > 1 {
+		return fmt.Errorf("only one of %s may be set, but found %s",
+			strings.Join(names, ", "), strings.Join(set, " and "))
+	}
+	return nil
+}
+
+// source describes where the value of the field at path came from.
+func (st *applyState) source(path []string) string {
+	if e := st.fieldEnvVar(path); st.used[e] {
+		return e
+	}
+	return "the configuration file"
+}
+
+// lookupFieldPath finds the field at path, which holds a section name, any
+// subsection names, and a field name.
+func lookupFieldPath(ref reflect.Value, path []string) (reflect.Value, error) {
+	invalid := fmt.Errorf("invalid field reference %q", strings.Join(path, "."))
+	if len(path) < 2 {
+		return reflect.Value{}, invalid
+	}
+	i := sectionFieldIndex(ref.Type(), path[0])
+	if i < 0 {
+		return reflect.Value{}, invalid
+	}
+	sec := ref.Field(i)
+	for _, k := range path[1 : len(path)-1] {
+		if sec.Kind() != reflect.Map {
+			return reflect.Value{}, invalid
+		}
+		sec = sec.MapIndex(reflect.ValueOf(k))
+		if !sec.IsValid() {
+			return reflect.Value{}, invalid
+		}
+		if sec.Kind() == reflect.Ptr {
+			sec = sec.Elem()
+		}
+	}
+	if sec.Kind() != reflect.Struct {
+		return reflect.Value{}, invalid
+	}
+	j := sectionFieldIndex(sec.Type(), path[len(path)-1])
+	if j < 0 {
+		return reflect.Value{}, invalid
+	}
+	return sec.Field(j), nil
+}
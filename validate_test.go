@@ -0,0 +1,388 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestRequiredIf(c *check.C) {
+	type server struct {
+		Enabled bool
+		Key     string `gcfgenv:"required_if=Tls.Enabled"`
+	}
+	type tls struct {
+		Enabled bool
+		Cert    string   `gcfgenv:"required_if=Enabled"`
+		Ciphers []string `gcfgenv:"required_if=Enabled"`
+	}
+	type config struct {
+		Tls    tls
+		Server map[string]*server
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader("[server \"a\"]\nenabled = true\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	// Requirements are checked after environment variables are applied.
+	cfg = config{}
+	r = strings.NewReader("[tls]\nenabled = true\ncert = cert.pem\nciphers = a\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_TLS_CERT": "",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Tls.Cert is required when Enabled is set`)
+
+	cfg = config{}
+	r = strings.NewReader("[tls]\ncert = cert.pem\nciphers = a\n[server \"a\"]\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_TLS_ENABLED": "true",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches,
+		`Server.a.Key is required when Tls.Enabled is set`)
+
+	cfg = config{}
+	r = strings.NewReader("[tls]\nenabled = true\ncert = cert.pem\nciphers = a\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SERVER_a_KEY": "key.pem",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	type badConfig struct {
+		Sec struct {
+			Field string `gcfgenv:"required_if=Other.Enabled"`
+		}
+	}
+	bad := badConfig{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{}, "APPNAME", &bad)
+	c.Check(err, check.ErrorMatches, `invalid field reference "Other.Enabled"`)
+}
+
+func (s *Suite) TestRequired(c *check.C) {
+	type backend struct {
+		Address string `gcfgenv:"required"`
+		Weight  int
+	}
+	type config struct {
+		Server struct {
+			Listen string `gcfgenv:"required"`
+			Peers  []string
+		}
+		Database struct {
+			URL      string `gcfgenv:"required -"`
+			Replicas int
+		}
+		Backend map[string]*backend
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader("[server]\nlisten = :80\n[database]\nurl = postgres://db\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	// Every missing field is listed, including those in subsections.
+	cfg = config{}
+	r = strings.NewReader("[backend \"a\"]\nweight = 2\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_BACKEND_b_ADDRESS": "b.example.com",
+	}, "APPNAME", &cfg)
+	c.Check(ErrorCode(err), check.Equals, CodeRequired)
+	c.Check(err, check.ErrorMatches, `Server.Listen is required, but is not set in the configuration file or by APPNAME_SERVER_LISTEN
+Database.URL is required, but is not set in the configuration file
+Backend.a.Address is required, but is not set in the configuration file or by APPNAME_BACKEND_a_ADDRESS`)
+
+	// Requirements are checked after environment variables are applied.
+	cfg = config{}
+	r = strings.NewReader("[server]\nlisten = :80\n[database]\nurl = postgres://db\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SERVER_LISTEN": "",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Server.Listen is required, .*`)
+
+	// Fields can also be declared programmatically.
+	cfg = config{}
+	r = strings.NewReader("[server]\nlisten = :80\n[database]\nurl = postgres://db\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithRequired("Server.Peers", "Server.Listen", "Database.Replicas"))
+	c.Check(err, check.ErrorMatches, `Server.Peers is required, but is not set in the configuration file or by APPNAME_SERVER_PEERS
+Database.Replicas is required, but is not set in the configuration file or by APPNAME_DATABASE_REPLICAS`)
+
+	cfg = config{}
+	r = strings.NewReader("")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithRequired("Server.Port"))
+	c.Check(err, check.ErrorMatches, `invalid field reference "Server.Port"`)
+}
+
+func (s *Suite) TestExclusive(c *check.C) {
+	type auth struct {
+		Password     string `gcfgenv:"exclusive=password"`
+		PasswordFile string `gcfgenv:"exclusive=password"`
+		VaultPath    string `gcfgenv:"exclusive=password"`
+	}
+	type config struct {
+		Auth    auth
+		Backend map[string]*auth
+		Cache   struct {
+			Dir    string
+			Memory bool
+		}
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader("[auth]\npassword = secret\n[backend \"b\"]\nvaultpath = x\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	cfg = config{}
+	r = strings.NewReader("[auth]\npassword = secret\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_AUTH_VAULTPATH": "secret/app",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `only one of Auth.Password, Auth.PasswordFile, `+
+		`Auth.VaultPath may be set, but found Auth.Password \(from the configuration file\) `+
+		`and Auth.VaultPath \(from APPNAME_AUTH_VAULTPATH\)`)
+
+	cfg = config{}
+	r = strings.NewReader("[backend \"b\"]\npasswordfile = /run/secret\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_BACKEND_b_PASSWORD": "secret",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `only one of Backend.b.Password, .*`+
+		`Backend.b.Password \(from APPNAME_BACKEND_b_PASSWORD\) `+
+		`and Backend.b.PasswordFile \(from the configuration file\)`)
+
+	// Groups can also be declared programmatically.
+	cfg = config{}
+	r = strings.NewReader("[cache]\ndir = /tmp\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_CACHE_MEMORY": "true",
+	}, "APPNAME", &cfg, WithExclusive("Cache.Dir", "Cache.Memory"))
+	c.Check(err, check.ErrorMatches, `only one of Cache.Dir, Cache.Memory may be set, .*`)
+
+	cfg = config{}
+	r = strings.NewReader("")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithExclusive("Cache.Dir", "Cache.Disk"))
+	c.Check(err, check.ErrorMatches, `invalid field reference "Cache.Disk"`)
+}
+
+func (s *Suite) TestItems(c *check.C) {
+	type cluster struct {
+		Seeds []string `gcfgenv:"minitems=1 maxitems=3 unique"`
+		Ports []*int   `gcfgenv:"unique"`
+	}
+	type config struct {
+		Cluster cluster
+		Region  map[string]*cluster
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader("[cluster]\nseeds = a\nseeds = b\nports = 1\nports = 2\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	cfg = config{}
+	r = strings.NewReader("")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Cluster.Seeds must have at least 1 entries, but has 0`)
+
+	cfg = config{}
+	r = strings.NewReader("[cluster]\nseeds = a\nseeds = b\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_CLUSTER_SEEDS": "c,d",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Cluster.Seeds must have at most 3 entries, but has 4`)
+
+	cfg = config{}
+	r = strings.NewReader("[cluster]\nseeds = a\n[region \"eu\"]\nseeds = b\nseeds = b\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Region.eu.Seeds has duplicate entry "b"`)
+
+	cfg = config{}
+	r = strings.NewReader("[cluster]\nseeds = a\nports = 80\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_CLUSTER_PORTS": "80",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Cluster.Ports has duplicate entry "80"`)
+
+	type badConfig struct {
+		Sec struct {
+			Field string `gcfgenv:"maxitems=1"`
+		}
+	}
+	bad := badConfig{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{}, "APPNAME", &bad)
+	c.Check(err, check.ErrorMatches, `maxitems directive on non-slice field Sec.Field`)
+
+	type badConfig2 struct {
+		Sec struct {
+			Field []int `gcfgenv:"minitems=one"`
+		}
+	}
+	bad2 := badConfig2{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{}, "APPNAME", &bad2)
+	c.Check(err, check.ErrorMatches, `invalid minitems directive on Sec.Field: "one"`)
+}
+
+func (s *Suite) TestReferences(c *check.C) {
+	type pool struct {
+		Size int
+	}
+	type config struct {
+		Server struct {
+			DefaultPool string   `gcfg:"default-pool" gcfgenv:"ref=pool"`
+			Fallbacks   []string `gcfgenv:"ref=pool"`
+			Backup      string
+		}
+		Pool map[string]*pool
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader(`[server]
+default-pool = main
+fallbacks = spare
+[pool "main"]
+[pool "spare"]
+`)
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	// Pools created by the environment count too.
+	cfg = config{}
+	r = strings.NewReader("[server]\ndefault-pool = main\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_POOL_main_SIZE": "1",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	cfg = config{}
+	r = strings.NewReader("[server]\ndefault-pool = main\n[pool \"main\"]\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SERVER_FALLBACKS": "main,mian",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Server.Fallbacks \(from APPNAME_SERVER_FALLBACKS\) `+
+		`refers to unknown Pool subsection "mian"`)
+
+	cfg = config{}
+	r = strings.NewReader("[server]\ndefault-pool = main\nbackup = spare\n[pool \"main\"]\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg,
+		WithReference("Server.Backup", "Pool"))
+	c.Check(err, check.ErrorMatches, `Server.Backup \(from the configuration file\) `+
+		`refers to unknown Pool subsection "spare"`)
+
+	type badConfig struct {
+		Server struct {
+			Pool int `gcfgenv:"ref=pool"`
+		}
+		Pool map[string]*pool
+	}
+	bad := badConfig{}
+	err = readWithMapInto(strings.NewReader(""), map[string]string{}, "APPNAME", &bad)
+	c.Check(err, check.ErrorMatches, `ref directive on Server.Pool, which is not a string or \[\]string`)
+}
+
+type validatedPool struct {
+	Min, Max int
+}
+
+func (p validatedPool) Validate() error {
+	if p.Min > p.Max {
+		return fmt.Errorf("min (%d) is greater than max (%d)", p.Min, p.Max)
+	}
+	return nil
+}
+
+type validatedConfig struct {
+	Pool    validatedPool
+	Replica map[string]*validatedPool
+	Server  struct {
+		Listen string
+		Public bool
+	}
+}
+
+var errPublicLocalhost = errors.New("a public server cannot listen on localhost")
+
+func (c *validatedConfig) Validate() error {
+	if c.Server.Public && strings.HasPrefix(c.Server.Listen, "localhost:") {
+		return errPublicLocalhost
+	}
+	return nil
+}
+
+func (s *Suite) TestValidator(c *check.C) {
+	var err error
+	cfg := validatedConfig{}
+	r := strings.NewReader("[pool]\nmin = 1\nmax = 2\n[server]\nlisten = localhost:80\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.IsNil)
+
+	// Validators see values from the environment, and errors from every
+	// section are returned.
+	cfg = validatedConfig{}
+	r = strings.NewReader("[pool]\nmin = 1\nmax = 2\n[replica \"a\"]\nmin = 3\n")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_POOL_MIN":      "5",
+		"APPNAME_SERVER_PUBLIC": "true",
+		"APPNAME_SERVER_LISTEN": "localhost:80",
+		"APPNAME_REPLICA_a_MAX": "4",
+		"APPNAME_REPLICA_b_MAX": "1",
+	}, "APPNAME", &cfg)
+	c.Check(ErrorCode(err), check.Equals, CodeValidate)
+	c.Check(err, check.ErrorMatches, `Pool: min \(5\) is greater than max \(2\)`)
+
+	// The config struct's own Validate is only called once its sections
+	// are valid.
+	cfg = validatedConfig{}
+	r = strings.NewReader("")
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SERVER_PUBLIC": "true",
+		"APPNAME_SERVER_LISTEN": "localhost:80",
+		"APPNAME_REPLICA_a_MIN": "2",
+		"APPNAME_REPLICA_b_MIN": "3",
+	}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Replica.a: min \(2\) is greater than max \(0\)
+Replica.b: min \(3\) is greater than max \(0\)`)
+
+	cfg = validatedConfig{}
+	r = strings.NewReader("[server]\npublic\nlisten = localhost:80\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(errors.Is(err, errPublicLocalhost), check.Equals, true)
+	c.Check(ErrorCode(err), check.Equals, CodeValidate)
+}
+
+func (s *Suite) TestValidateSkipsSubsectionDefaults(c *check.C) {
+	type pool struct {
+		Name  string   `gcfgenv:"required"`
+		Hosts []string `gcfgenv:"minitems=1"`
+		Port  int
+	}
+	type config struct {
+		Pool         map[string]*pool
+		Default_Pool pool
+	}
+	// The defaults struct is not a section of its own, so its fields do
+	// not need to be set, but the subsections that use it are checked.
+	cfg := config{Default_Pool: pool{Port: 5432}}
+	r := strings.NewReader("[pool \"a\"]\nname = main\nhosts = db1\n")
+	err := readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Pool["a"].Port, check.Equals, 5432)
+
+	cfg = config{}
+	r = strings.NewReader("[pool \"a\"]\nhosts = db1\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `Pool.a.Name is required, .*`)
+}
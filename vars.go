@@ -0,0 +1,227 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/gcfg.v1/scanner"
+	"gopkg.in/gcfg.v1/token"
+)
+
+// WithVars allows the configuration file to define reusable values in the
+// section with the given name (e.g. "vars"), which values elsewhere in the
+// file can refer to as "${vars.name}":
+//
+//	[vars]
+//	base-url = https://api.example.com
+//
+//	[billing]
+//	endpoint = ${vars.base-url}/billing
+//
+// Names are case-insensitive, as in gcfg, and values in the section may
+// refer to each other. The section itself is removed before the file is
+// applied to the config struct, which does not need a field for it.
+// References to names that are not defined are errors. Values from
+// environment variables are not expanded.
+func WithVars(section string) Option {
+	return func(o *options) {
+		o.varsSection = section
+	}
+}
+
+// WithEnvInterpolation expands references to environment variables, written
+// as "${VAR}", in the values of the configuration file before they are
+// converted to the types of their fields, e.g.
+//
+//	[database]
+//	url = postgres://${DB_HOST}:5432/app
+//	pool-size = ${DB_POOL_SIZE}
+//
+// "$$" stands for a single "$", so "$${VAR}" is left as "${VAR}". Other uses
+// of "$" (such as "$VAR") are left alone. References to variables that are
+// not set are errors. The environment is the one the overrides come from.
+func WithEnvInterpolation() Option {
+	return func(o *options) {
+		o.interpolate = true
+	}
+}
+
+// valueRef matches a reference in a value: "$$", "${VAR}", or
+// "${section.name}".
+var valueRef = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$\{([A-Za-z][A-Za-z0-9-]*)\.([A-Za-z][A-Za-z0-9-]*)\}`)
+
+// expandValues expands the references allowed by WithVars and
+// WithEnvInterpolation in the values of src, taking environment variables
+// from env.
+func (o *options) expandValues(src []byte, env map[string]string) ([]byte, error) {
+	if o.varsSection == "" && !o.interpolate {
+		return src, nil
+	}
+	exp := &valueExpander{section: o.varsSection, done: make(map[string]bool)}
+	if o.interpolate {
+		exp.env = env
+	}
+	return exp.expandFile(src)
+}
+
+// expandFile removes the vars section (if any) from src, and expands
+// references in the other values. The section is replaced with whitespace,
+// and each replaced value takes up the same number of lines, so that
+// positions in any errors reported by gcfg still refer to the original input.
+func (exp *valueExpander) expandFile(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, nil, 0)
+	type value struct {
+		pos token.Pos
+		lit string
+	}
+	section := exp.section
+	vars := make(map[string]string)
+	var values []value
+	// removed holds the start and end offsets of each vars section.
+	var removed [][2]int
+	inVars := false
+	pos, tok, lit := s.Scan()
+	for tok != token.EOF {
+		switch tok {
+		case token.LBRACK:
+			if inVars {
+				removed[len(removed)-1][1] = file.Offset(pos)
+			}
+			start := file.Offset(pos)
+			name, subsection := "", false
+			for tok != token.RBRACK && tok != token.EOF {
+				_, tok, lit = s.Scan()
+				switch tok {
+				case token.IDENT:
+					name = lit
+				case token.STRING:
+					subsection = true
+				}
+			}
+			// Subsections of the vars section are left alone.
+			inVars = section != "" && strings.EqualFold(name, section) && !subsection
+			if inVars {
+				removed = append(removed, [2]int{start, len(src)})
+			}
+		case token.IDENT:
+			if !inVars {
+				break
+			}
+			name := strings.ToLower(lit)
+			vars[name] = ""
+			for tok != token.EOL && tok != token.EOF {
+				_, tok, lit = s.Scan()
+				if tok == token.STRING {
+					vars[name] = unquote(lit)
+				}
+			}
+			continue
+		case token.STRING:
+			if !inVars {
+				values = append(values, value{pos, lit})
+			}
+		}
+		pos, tok, lit = s.Scan()
+	}
+	if len(removed) == 0 && len(values) == 0 {
+		return src, nil
+	}
+
+	exp.vars = vars
+	out := append([]byte(nil), src...)
+	for _, r := range removed {
+		for i := r[0]; i < r[1]; i++ {
+			if out[i] != '\n' && out[i] != '\r' {
+				out[i] = ' '
+			}
+		}
+	}
+	var buf []byte
+	last := 0
+	for _, v := range values {
+		val := unquote(v.lit)
+		if !strings.Contains(val, "$") {
+			continue
+		}
+		expanded, err := exp.expand(val)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", file.Position(v.pos).Line, err)
+		}
+		off := file.Offset(v.pos)
+		buf = append(buf, out[last:off]...)
+		buf = append(buf, quoteGcfgValue(expanded)...)
+		buf = append(buf, strings.Repeat("\n", strings.Count(v.lit, "\n"))...)
+		last = off + len(v.lit)
+	}
+	return append(buf, out[last:]...), nil
+}
+
+// valueExpander replaces references in values with values from a vars
+// section or the environment.
+type valueExpander struct {
+	// section is the name of the vars section, or "" if WithVars was not
+	// given.
+	section string
+	vars    map[string]string
+	// done is true for the vars whose own references have been expanded,
+	// and false for those being expanded, to detect cycles.
+	done map[string]bool
+	// env is nil if WithEnvInterpolation was not given.
+	env map[string]string
+}
+
+func (e *valueExpander) expand(val string) (string, error) {
+	var err error
+	out := valueRef.ReplaceAllStringFunc(val, func(ref string) string {
+		if err != nil {
+			return ref
+		}
+		m := valueRef.FindStringSubmatch(ref)
+		switch {
+		case e.env == nil && (ref == "$$" || m[1] != ""):
+			return ref
+		case ref == "$$":
+			return "$"
+		case m[1] != "":
+			v, ok := e.env[m[1]]
+			if !ok {
+				err = fmt.Errorf("environment variable %s is not set", m[1])
+			}
+			return v
+		case e.section == "" || !strings.EqualFold(m[2], e.section):
+			return ref
+		}
+		var v string
+		v, err = e.lookup(strings.ToLower(m[3]))
+		return v
+	})
+	return out, err
+}
+
+func (e *valueExpander) lookup(name string) (string, error) {
+	v, ok := e.vars[name]
+	if !ok {
+		return "", fmt.Errorf("undefined reference ${%s.%s}", e.section, name)
+	}
+	done, seen := e.done[name]
+	if done {
+		return v, nil
+	}
+	if seen {
+		return "", fmt.Errorf("${%s.%s} refers to itself", e.section, name)
+	}
+	e.done[name] = false
+	v, err := e.expand(v)
+	if err != nil {
+		return "", err
+	}
+	e.vars[name], e.done[name] = v, true
+	return v, nil
+}
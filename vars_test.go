@@ -0,0 +1,125 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *Suite) TestVars(c *check.C) {
+	type endpoint struct {
+		URL     string
+		Timeout int
+	}
+	type config struct {
+		Billing  endpoint
+		Search   endpoint
+		Mirror   map[string]*endpoint
+		Comments []string
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader(`[vars]
+base-url = https://api.example.com
+Billing-URL = "${vars.base-url}/billing ; v2"
+timeout = 30
+
+[billing]
+url = ${VARS.billing-url}
+timeout = ${vars.timeout}
+
+[search]
+url = ${vars.base-url}/search ; keep this comment
+url = ${HOME}/${other.x}/${vars.base-url}
+
+[mirror "eu"]
+url = "${vars.base-url}"\
+/eu
+`)
+	err = readWithMapInto(r, map[string]string{
+		"APPNAME_SEARCH_TIMEOUT": "5",
+		"APPNAME_MIRROR_us_URL":  "${vars.base-url}/us",
+	}, "APPNAME", &cfg, WithVars("vars"))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg, check.DeepEquals, config{
+		Billing: endpoint{URL: "https://api.example.com/billing ; v2", Timeout: 30},
+		Search:  endpoint{URL: "${HOME}/${other.x}/https://api.example.com", Timeout: 5},
+		Mirror: map[string]*endpoint{
+			"eu": {URL: "https://api.example.com/eu"},
+			// Values from the environment are not expanded.
+			"us": {URL: "${vars.base-url}/us"},
+		},
+	})
+
+	// Without WithVars, the section is unknown.
+	cfg = config{}
+	r = strings.NewReader("[vars]\nname = x\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg)
+	c.Check(err, check.ErrorMatches, `(?s).*section "vars".*`)
+
+	cfg = config{}
+	r = strings.NewReader("[vars]\na = x\n\n[billing]\nurl = ${vars.b}\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg, WithVars("vars"))
+	c.Check(err, check.ErrorMatches, `line 5: undefined reference \${vars\.b}`)
+
+	cfg = config{}
+	r = strings.NewReader("[billing]\nurl = ${vars.a}\n[vars]\na = ${vars.b}\nb = x${vars.a}\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg, WithVars("vars"))
+	c.Check(err, check.ErrorMatches, `line 2: \${vars\.a} refers to itself`)
+
+	// Line numbers in errors from gcfg refer to the original file.
+	cfg = config{}
+	r = strings.NewReader("[vars]\nn = \"a\\\nb\"\n[billing]\nurl = ${vars.n}\n[bad\n")
+	err = readWithMapInto(r, map[string]string{}, "APPNAME", &cfg, WithVars("vars"))
+	c.Check(err, check.ErrorMatches, `6:5: .*`)
+}
+
+func (s *Suite) TestEnvInterpolation(c *check.C) {
+	type config struct {
+		Database struct {
+			URL      string
+			PoolSize int `gcfg:"pool-size"`
+			Password string
+			Hosts    []string
+		}
+	}
+	env := map[string]string{
+		"DB_HOST":      "db.internal",
+		"DB_POOL_SIZE": "8",
+		"EMPTY":        "",
+	}
+
+	var err error
+	cfg := config{}
+	r := strings.NewReader(`[vars]
+port = 5432
+
+[database]
+url = postgres://${DB_HOST}:${vars.port}/app${EMPTY}
+pool-size = ${DB_POOL_SIZE}
+password = "pa$$word$${DB_HOST}$HOME ; not a comment"
+hosts = ${DB_HOST}
+`)
+	err = readWithMapInto(r, env, "APPNAME", &cfg, WithEnvInterpolation(), WithVars("vars"))
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Database.URL, check.Equals, "postgres://db.internal:5432/app")
+	c.Check(cfg.Database.PoolSize, check.Equals, 8)
+	c.Check(cfg.Database.Password, check.Equals, "pa$word${DB_HOST}$HOME ; not a comment")
+	c.Check(cfg.Database.Hosts, check.DeepEquals, []string{"db.internal"})
+
+	// Without the option, values are left alone.
+	cfg = config{}
+	r = strings.NewReader("[database]\nurl = ${DB_HOST}$$\n")
+	err = readWithMapInto(r, env, "APPNAME", &cfg)
+	c.Assert(err, check.IsNil)
+	c.Check(cfg.Database.URL, check.Equals, "${DB_HOST}$$")
+
+	cfg = config{}
+	r = strings.NewReader("[database]\nurl = ${DB_USER}@${DB_HOST}\n")
+	err = readWithMapInto(r, env, "APPNAME", &cfg, WithEnvInterpolation())
+	c.Check(err, check.ErrorMatches, `line 2: environment variable DB_USER is not set`)
+}
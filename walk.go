@@ -0,0 +1,157 @@
+// Copyright 2024 Posit Software, PBC
+// SPDX-License-Identifier: Apache-2.0
+
+package gcfgenv
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// walkFunc is called by walkFields for each settable field of every section
+// and subsection. The path holds the section name, any subsection names, and
+// the field name, using names as they appear in the configuration file. It is
+// reused between calls, and must be copied to be retained.
+type walkFunc func(path []string, sf reflect.StructField, f reflect.Value) error
+
+// walkSectionFunc is called by walkSections for each section and subsection.
+// The path holds the section name and any subsection names. It is reused
+// between calls, and must be copied to be retained.
+type walkSectionFunc func(path []string, sec reflect.Value) error
+
+// walkFields calls fn for each field of every section in the config struct
+// ref, visiting subsections in sorted order. It stops at the first error.
+func walkFields(ref reflect.Value, fn walkFunc) error {
+	return walkSections(ref, func(path []string, sec reflect.Value) error {
+		return walkSection(path, sec, fn)
+	})
+}
+
+// walkSections calls fn for each section and subsection struct in the config
+// struct ref, visiting subsections in sorted order. It stops at the first
+// error.
+func walkSections(ref reflect.Value, fn walkSectionFunc) error {
+	refType := ref.Type()
+	for i := 0; i < refType.NumField(); i++ {
+		sf := refType.Field(i)
+		sec := ref.Field(i)
+		if !sf.IsExported() || sf.Name == rawSectionsField || isSubsectionDefaults(refType, sf) {
+			continue
+		}
+		name := fieldSectionName(sf)
+		var err error
+		switch {
+		case sec.Kind() == reflect.Struct:
+			err = fn([]string{name}, sec)
+		case isSubsectionMap(sec.Type()):
+			err = walkSubsections([]string{name}, sec, fn)
+		case isNestedSubsectionMap(sec.Type()):
+			for _, k := range sortedKeys(sec) {
+				err = walkSubsections([]string{name, k}, sec.MapIndex(reflect.ValueOf(k)), fn)
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSubsectionDefaults reports whether sf is a Default_<Sec> field of the config
+// struct type t, which holds the defaults for the subsections in its <Sec>
+// field rather than a section of its own.
+func isSubsectionDefaults(t reflect.Type, sf reflect.StructField) bool {
+	if !strings.HasPrefix(sf.Name, "Default_") {
+		return false
+	}
+	sec, ok := t.FieldByName(strings.TrimPrefix(sf.Name, "Default_"))
+	return ok && (isSubsectionMap(sec.Type) || isNestedSubsectionMap(sec.Type))
+}
+
+func walkSubsections(path []string, sec reflect.Value, fn walkSectionFunc) error {
+	keys := getStringSlice()
+	defer putStringSlice(keys)
+	appendSortedKeys(keys, sec)
+	subPath := append(path[:len(path):len(path)], "")
+	for _, k := range *keys {
+		subsec := sec.MapIndex(reflect.ValueOf(k))
+		if subsec.IsNil() {
+			continue
+		}
+		subPath[len(path)] = k
+		err := fn(subPath, subsec.Elem())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func walkSection(path []string, sec reflect.Value, fn walkFunc) error {
+	secType := sec.Type()
+	fieldPath := append(path[:len(path):len(path)], "")
+	for j := 0; j < secType.NumField(); j++ {
+		sf := secType.Field(j)
+		f := sec.Field(j)
+		if isEmbedded(sf) {
+			// Promoted fields are walked as fields of the section.
+			if err := walkSection(path, f, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if !sf.IsExported() || !f.CanSet() {
+			continue
+		}
+		fieldPath[len(path)] = fieldSectionName(sf)
+		err := callWalkFunc(fn, fieldPath, sf, f)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callWalkFunc calls fn, converting any panic into a *PanicError for the
+// field.
+func callWalkFunc(fn walkFunc, path []string, sf reflect.StructField, f reflect.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Path: strings.Join(path, "."), Value: r}
+		}
+	}()
+	return fn(path, sf, f)
+}
+
+func sortedKeys(m reflect.Value) []string {
+	keys := make([]string, 0, m.Len())
+	appendSortedKeys(&keys, m)
+	return keys
+}
+
+// appendSortedKeys appends the sorted keys of the map m to keys, which must
+// be empty.
+func appendSortedKeys(keys *[]string, m reflect.Value) {
+	iter := m.MapRange()
+	for iter.Next() {
+		*keys = append(*keys, iter.Key().String())
+	}
+	sort.Strings(*keys)
+}
+
+// sectionStructType returns the struct type holding the variables of a
+// section field of type t, or nil if t is not a section type.
+func sectionStructType(t reflect.Type) reflect.Type {
+	switch {
+	case t.Kind() == reflect.Struct:
+		return t
+	case isSubsectionMap(t):
+		return t.Elem().Elem()
+	case isNestedSubsectionMap(t):
+		return t.Elem().Elem().Elem()
+	}
+	return nil
+}